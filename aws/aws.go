@@ -3,15 +3,15 @@ package aws
 import (
 	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
 	"github.com/Stanly1995/golibs/params_validator"
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/labstack/gommon/log"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/vincent-petithory/dataurl"
+	"io"
+	"net/http"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -26,30 +26,66 @@ type AWSInfo struct {
 
 // struct for work with AWSInfo service
 type AWSConnector struct {
-	timeout   time.Duration
-	AWSInfo   AWSInfo
-	svc       s3Client
-	generator dataGenerate
+	timeout     time.Duration
+	AWSInfo     AWSInfo
+	svc         s3Client
+	generator   dataGenerate
+	multipart   MultipartConfig
+	keyStrategy KeyStrategy
+	policy      UploadPolicy
+	retryPolicy RetryPolicy
+	dedup       *DedupConfig
+	batch       BatchConfig
+	limiter     *BandwidthLimiter
+	uploadStore UploadStateStore
+
+	encryption         Encryption
+	encryptionRequired bool
 }
 
+// Option configures optional settings of AWSConnector
+type Option func(awsConn *AWSConnector)
+
 // NewAWSConnector is constructor, receives aws session, bucket and aws url,
-func NewAWSConnector(awsInfo AWSInfo, timeout time.Duration, svc s3Client, g dataGenerate) (*AWSConnector, error) {
+// opts are applied after the required params
+func NewAWSConnector(awsInfo AWSInfo, timeout time.Duration, svc s3Client, g dataGenerate, opts ...Option) (*AWSConnector, error) {
 	params_validator.ValidateParamsWithPanic(svc, g)
 	if awsInfo.Bucket == "" {
-		return nil, errors.New("bucket is empty")
+		return nil, ErrEmptyBucket
 	}
 	if awsInfo.URL == "" {
-		return nil, errors.New("aws url is empty")
+		return nil, ErrEmptyURL
 	}
 	if timeout <= 5*time.Second {
-		return nil, errors.New("timeout is negative")
+		return nil, ErrInvalidTimeout
 	}
-	return &AWSConnector{
+	awsConn := &AWSConnector{
 		timeout:   timeout,
 		AWSInfo:   awsInfo,
 		svc:       svc,
 		generator: g,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(awsConn)
+	}
+	if err := awsConn.multipart.validate(); err != nil {
+		return nil, err
+	}
+	if err := awsConn.encryption.validate(); err != nil {
+		return nil, err
+	}
+	if err := awsConn.retryPolicy.validate(); err != nil {
+		return nil, err
+	}
+	if err := awsConn.batch.validate(); err != nil {
+		return nil, err
+	}
+	if awsConn.dedup != nil {
+		if err := awsConn.dedup.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return awsConn, nil
 }
 
 type File struct {
@@ -57,12 +93,22 @@ type File struct {
 	fileName string
 }
 
+// hasMediaType reports whether dataUrl of the file declares media type,
+// dataurl package uses text/plain when it's not declared
+func (f *File) hasMediaType() bool {
+	mediaType := strings.TrimPrefix(f.content, "data:")
+	if i := strings.IndexAny(mediaType, ";,"); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+	return strings.TrimSpace(mediaType) != ""
+}
+
 func NewFile(fileObj *string) (*File, error) {
 	reg := regexp.MustCompile(`^name:{(.+)},dataUrl:{(.+)}$`)
 
 	fileParams := reg.FindStringSubmatch(*fileObj)
 	if len(fileParams) != 3 {
-		return nil, errors.New("invalid file object")
+		return nil, ErrInvalidFileObject
 	}
 	return &File{
 		fileName: fileParams[1],
@@ -70,70 +116,212 @@ func NewFile(fileObj *string) (*File, error) {
 	}, nil
 }
 
-// PutFile puts input file to aws and return url for to download this file
+// PutFile puts input file to aws and returns key of the object, use PutFileURL to get url for download this file
 // returns error if PutObject returns error
-// Where is name - filename with extension, dataUrl - file body in dataURL format
-func (awsConn *AWSConnector) PutFile(ctx context.Context, fileObj *string) (string, error) {
+// Where is name - filename with extension, dataUrl - file body in dataURL format.
+// Content type of the object is taken from dataUrl or detected by file body when dataUrl has no media type.
+// Body is sent with Content-MD5, so aws rejects it when it's corrupted on the way,
+// SHA-256 of the body is stored in metadata by ChecksumMetadataKey
+func (awsConn *AWSConnector) PutFile(ctx context.Context, fileObj *string, opts ...PutOption) (string, error) {
+	result, err := awsConn.putFile(ctx, fileObj, opts)
+	return result.Key, err
+}
+
+// putFile puts input file to aws and returns key and checksum of the object
+func (awsConn *AWSConnector) putFile(ctx context.Context, fileObj *string, opts []PutOption) (PutResult, error) {
 	file, err := NewFile(fileObj)
 	if err != nil {
-		return "", err
+		return PutResult{}, err
 	}
-
-	dataURLDec, err := dataurl.DecodeString(file.content)
+	putOpts := newPutOptions(opts)
+	enc, err := awsConn.writeEncryption(putOpts)
 	if err != nil {
-		return "", err
+		return PutResult{}, err
+	}
+	data, contentType, err := awsConn.decodeFile(file)
+	if err != nil {
+		return PutResult{}, err
 	}
+	checksum := newChecksum(data)
 
-	var cancelFn func()
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	ctx, cancelFn = context.WithTimeout(ctx, awsConn.timeout)
-
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
 	defer cancelFn()
 
-	select {
-	case <-time.After(10 * time.Millisecond):
-		log.Info("file upload started after waiting ten milliseconds")
-	case <-ctx.Done():
-		log.Error(ctx.Err()) // prints "context deadline exceeded"
+	if awsConn.dedup != nil && putOpts.key == "" {
+		return awsConn.putDeduplicated(ctx, file.fileName, data, contentType, checksum, putOpts, enc)
 	}
 
-	uniqueFileName := fmt.Sprintf("%s_%s_%s", awsConn.generator.GenerateTime(), awsConn.generator.GenerateUUID(), file.fileName)
+	uniqueFileName, err := awsConn.objectKey(ctx, file.fileName, data, putOpts)
+	if err != nil {
+		return PutResult{}, err
+	}
+	return awsConn.putObject(ctx, uniqueFileName, file.fileName, data, contentType, checksum, putOpts, enc)
+}
 
-	err = awsConn.svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
-		Body:   bytes.NewReader(dataURLDec.Data),
+// putObject puts data by key sending its checksum
+func (awsConn *AWSConnector) putObject(ctx context.Context, key, fileName string, data []byte, contentType string, checksum Checksum, putOpts putOptions, enc Encryption) (PutResult, error) {
+	ctx = withTransfer(ctx, awsConn.newTransfer(key, int64(len(data)), putOpts.progress, putOpts.limiter))
+	input := &s3.PutObjectInput{
 		Bucket: &awsConn.AWSInfo.Bucket,
-		Key:    &uniqueFileName,
+		Key:    &key,
+	}
+	putOpts.headers(contentType, fileName).applyToPutObject(input)
+	enc.headers().applyToPutObject(input)
+	input.ContentMD5 = checksum.contentMD5()
+	input.Metadata = withChecksumMetadata(input.Metadata, checksum)
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		// every attempt sends the body from the start
+		input.Body = bytes.NewReader(data)
+		return awsConn.svc.PutObjectWithContext(ctx, input)
 	})
 
 	if err != nil {
-		return "", errors.New("AWS returned error, saving file failed")
+		return PutResult{}, newS3Error("PutObject", awsConn.AWSInfo.Bucket, key, err)
 	}
 
+	return PutResult{Key: key, Checksum: checksum}, nil
+}
+
+// decodeFile decodes dataUrl of the file checking it by upload policy,
+// returns file body and its content type
+func (awsConn *AWSConnector) decodeFile(file *File) ([]byte, string, error) {
+	if err := awsConn.policy.checkName(file.fileName); err != nil {
+		return nil, "", err
+	}
+	if err := awsConn.policy.checkSize(estimateDecodedSize(file.content)); err != nil {
+		return nil, "", err
+	}
+
+	dataURLDec, err := dataurl.DecodeString(file.content)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = awsConn.policy.checkSize(int64(len(dataURLDec.Data))); err != nil {
+		return nil, "", err
+	}
+
+	contentType := dataURLDec.MediaType.String()
+	if file.hasMediaType() {
+		err = awsConn.policy.checkContent(contentType, dataURLDec.Data)
+	} else {
+		contentType = http.DetectContentType(dataURLDec.Data)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err = awsConn.policy.checkType(contentType); err != nil {
+		return nil, "", err
+	}
+	return dataURLDec.Data, contentType, nil
+}
+
+// PutReader streams r to aws under a key built by key strategy of AWSConnector
+// and returns this key. The body is never loaded into memory as a whole.
+// size is the number of bytes to read from r, negative size means that r is read until EOF.
+// The upload fails with io.ErrUnexpectedEOF when r ends before size bytes.
+// Empty contentType is detected by the first bytes of r
+func (awsConn *AWSConnector) PutReader(ctx context.Context, name, contentType string, r io.Reader, size int64, opts ...PutOption) (string, error) {
+	if name == "" {
+		return "", cerr.ErrFuncArg{}.Invalidate("name")
+	}
+	if r == nil {
+		return "", cerr.ErrFuncArg{}.Invalidate("r")
+	}
+	putOpts := newPutOptions(opts)
+	enc, err := awsConn.writeEncryption(putOpts)
+	if err != nil {
+		return "", err
+	}
+	sized := &sizedReader{r: r, left: size}
+	if size >= 0 {
+		if err := awsConn.policy.checkSize(size); err != nil {
+			return "", err
+		}
+		r = sized
+	}
+	contentType, body, err := awsConn.policy.checkStream(name, contentType, r)
+	if err != nil {
+		return "", err
+	}
+
+	uniqueFileName, err := awsConn.uploadStream(ctx, name, contentType, body, size, putOpts, enc)
+	if body.err != nil {
+		return "", body.err
+	}
+	if sized.err != nil {
+		return "", sized.err
+	}
 	return uniqueFileName, err
 }
 
-func (awsConn *AWSConnector) SetBucketReadOnlyPolicy() error {
-	readOnlyAnonUserPolicy := map[string]interface{}{
-		"Statement": []map[string]interface{}{
-			{
-				"Sid":       "AddPerm",
-				"Effect":    "Allow",
-				"Principal": "*",
-				"Action":    []string{"s3:GetObject"},
-				"Resource":  []string{fmt.Sprintf("arn:aws:s3:::%s/*", awsConn.AWSInfo.Bucket)},
-			},
-		},
-	}
-	policy, err := json.Marshal(readOnlyAnonUserPolicy)
+// sizedReader reads left bytes of r like io.LimitReader, but fails with io.ErrUnexpectedEOF
+// when r ends earlier, so a short body is not stored as complete object. The error is also kept in the reader
+type sizedReader struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func (sr *sizedReader) Read(p []byte) (int, error) {
+	if sr.err != nil {
+		return 0, sr.err
+	}
+	if sr.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > sr.left {
+		p = p[:sr.left]
+	}
+	n, err := sr.r.Read(p)
+	sr.left -= int64(n)
+	if err == io.EOF && sr.left > 0 {
+		sr.err = io.ErrUnexpectedEOF
+		return n, sr.err
+	}
+	return n, err
+}
+
+// uploadStream uploads body which is already checked by policy under key built by key strategy.
+// The upload is not retried as a whole, because body can't be read twice, failed chunks are retried by aws sdk
+// Negative size means that size of body is unknown
+func (awsConn *AWSConnector) uploadStream(ctx context.Context, name, contentType string, body io.Reader, size int64, putOpts putOptions, enc Encryption) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	uniqueFileName, err := awsConn.objectKey(ctx, name, nil, putOpts)
 	if err != nil {
-		return err
+		return "", err
 	}
-	err = awsConn.svc.PutBucketPolicy(&s3.PutBucketPolicyInput{
-		Bucket: aws.String(awsConn.AWSInfo.Bucket),
-		Policy: aws.String(string(policy)),
-	})
+	if size < 0 {
+		size = -1
+	}
+	ctx = withTransfer(ctx, awsConn.newTransfer(uniqueFileName, size, putOpts.progress, putOpts.limiter))
 
-	return err
+	input := &s3manager.UploadInput{
+		Body:   body,
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &uniqueFileName,
+	}
+	putOpts.headers(contentType, name).applyToUpload(input)
+	enc.headers().applyToUpload(input)
+
+	err = awsConn.svc.UploadWithContext(ctx, input)
+	if err != nil {
+		return "", newS3Error("Upload", awsConn.AWSInfo.Bucket, uniqueFileName, err)
+	}
+
+	return uniqueFileName, nil
+}
+
+// SetBucketReadOnlyPolicy allows anyone to get objects of the bucket,
+// other statements of the bucket policy are kept
+func (awsConn *AWSConnector) SetBucketReadOnlyPolicy() error {
+	return awsConn.AddPolicyStatements(context.Background(), ReadOnlyStatement(awsConn.AWSInfo.Bucket))
 }
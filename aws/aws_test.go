@@ -3,8 +3,15 @@ package aws
 import (
 	"context"
 	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,7 +30,7 @@ func TestClientStatusUpdater_NewFile(t *testing.T) {
 			desc:        "Should returns error when fileParams != 3",
 			stubFileObj: &stubFileObjBad,
 			wantFile:    File{},
-			wantErr:     errors.New("invalid file object"),
+			wantErr:     ErrInvalidFileObject,
 		},
 		{
 			desc:        "Should returns no error and file",
@@ -70,7 +77,7 @@ func TestClientStatusUpdater_NewAWSConnector(t *testing.T) {
 			svc:              NewMockiS3Client(ctrl),
 			generator:        NewMockiGenerate(ctrl),
 			wantAWSConnector: nil,
-			wantErr:          errors.New("bucket is empty"),
+			wantErr:          ErrEmptyBucket,
 		},
 		{
 			desc: "Should returns error when aws url is empty",
@@ -80,10 +87,10 @@ func TestClientStatusUpdater_NewAWSConnector(t *testing.T) {
 			svc:              NewMockiS3Client(ctrl),
 			generator:        NewMockiGenerate(ctrl),
 			wantAWSConnector: nil,
-			wantErr:          errors.New("aws url is empty"),
+			wantErr:          ErrEmptyURL,
 		},
 		{
-			desc: "Should returns error when timeout is negative",
+			desc: "Should returns error when timeout is too short",
 			awsInfo: AWSInfo{
 				Bucket: "test",
 				URL:    "test.com",
@@ -91,7 +98,7 @@ func TestClientStatusUpdater_NewAWSConnector(t *testing.T) {
 			svc:              NewMockiS3Client(ctrl),
 			generator:        NewMockiGenerate(ctrl),
 			wantAWSConnector: nil,
-			wantErr:          errors.New("timeout is negative"),
+			wantErr:          ErrInvalidTimeout,
 		},
 		{
 			desc: "Should returns no error and returns *AWSConnector",
@@ -151,7 +158,7 @@ func TestClientStatusUpdater_PutFile(t *testing.T) {
 			svc:                NewMockiS3Client(ctrl),
 			generator:          NewMockiGenerate(ctrl),
 			wantUniqueFileName: "",
-			wantErr:            errors.New("invalid file object"),
+			wantErr:            ErrInvalidFileObject,
 		},
 		{
 			desc:               "Should returns error when dataurl.DecodeString failed",
@@ -171,11 +178,17 @@ func TestClientStatusUpdater_PutFile(t *testing.T) {
 			}(NewMockiGenerate(ctrl)),
 			svc: func(m *MockiS3Client) *MockiS3Client {
 				m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
-					Return(errors.New("AWS returned error, saving file failed"))
+					Return(errors.New("test error"))
 				return m
 			}(NewMockiS3Client(ctrl)),
 			wantUniqueFileName: "",
-			wantErr:            errors.New("AWS returned error, saving file failed"),
+			wantErr: S3Error{
+				Operation: "PutObject",
+				Bucket:    "test bucket",
+				Key:       "time_111_img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
 		},
 		{
 			desc:    "Should returns no error",
@@ -217,6 +230,126 @@ func TestClientStatusUpdater_PutFile(t *testing.T) {
 	}
 }
 
+func TestClientStatusUpdater_PutReader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc               string
+		name               string
+		r                  io.Reader
+		size               int64
+		svc                *MockiS3Client
+		generator          *MockiGenerate
+		wantUniqueFileName string
+		wantErr            error
+	}{
+		{
+			desc:               "Should returns error when name is empty",
+			r:                  strings.NewReader("body"),
+			svc:                NewMockiS3Client(ctrl),
+			generator:          NewMockiGenerate(ctrl),
+			wantUniqueFileName: "",
+			wantErr:            cerr.NewErrFuncArgMock("name", "PutReader"),
+		},
+		{
+			desc:               "Should returns error when reader is nil",
+			name:               "img.png",
+			svc:                NewMockiS3Client(ctrl),
+			generator:          NewMockiGenerate(ctrl),
+			wantUniqueFileName: "",
+			wantErr:            cerr.NewErrFuncArgMock("r", "PutReader"),
+		},
+		{
+			desc: "Should returns error when UploadWithContext failed",
+			name: "img.png",
+			r:    strings.NewReader("body"),
+			size: -1,
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			}(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().UploadWithContext(gomock.Any(), gomock.Any()).
+					Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "",
+			wantErr: S3Error{
+				Operation: "Upload",
+				Bucket:    "test bucket",
+				Key:       "time_111_img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc: "Should returns no error and reads only size bytes",
+			name: "img.png",
+			r:    strings.NewReader("body with tail"),
+			size: 4,
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			}(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().UploadWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3manager.UploadInput) error {
+						body, err := ioutil.ReadAll(input.Body)
+						assert.NoError(t, err)
+						assert.Equal(t, "body", string(body))
+						assert.Equal(t, "time_111_img.png", *input.Key)
+						assert.Equal(t, "image/png", *input.ContentType)
+						return nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "time_111_img.png",
+			wantErr:            nil,
+		},
+		{
+			desc: "Should returns error when reader is shorter than size",
+			name: "img.png",
+			r:    strings.NewReader("abc"),
+			size: 10,
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			}(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().UploadWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3manager.UploadInput) error {
+						_, err := ioutil.ReadAll(input.Body)
+						return err
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "",
+			wantErr:            io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, c.generator)
+			got, gotErr := aws.PutReader(context.Background(), c.name, "image/png", c.r, c.size)
+
+			// assert
+			assert.Equal(t, c.wantUniqueFileName, got)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
 func TestClientStatusUpdater_SetBucketReadOnlyPolicy(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -228,19 +361,74 @@ func TestClientStatusUpdater_SetBucketReadOnlyPolicy(t *testing.T) {
 		generator *MockiGenerate
 		wantErr   error
 	}{
+		{
+			desc: "Should returns error when GetBucketPolicy failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			generator: NewMockiGenerate(ctrl),
+			wantErr: S3Error{
+				Operation: "GetBucketPolicy",
+				Bucket:    "test bucket",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
 		{
 			desc: "Should returns error when PutBucketPolicy failed",
 			svc: func(m *MockiS3Client) *MockiS3Client {
-				m.EXPECT().PutBucketPolicy(gomock.Any()).Return(errors.New("test error"))
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil, errNoSuchBucketPolicy)
+				m.EXPECT().PutBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			generator: NewMockiGenerate(ctrl),
+			wantErr: S3Error{
+				Operation: "PutBucketPolicy",
+				Bucket:    "test bucket",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc: "Should add statement to existing policy",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(&s3.GetBucketPolicyOutput{
+					Policy: aws.String(`{"Version":"2012-10-17","Statement":[{"Sid":"Other","Effect":"Deny","Principal":"*","Action":"s3:DeleteObject","Resource":"arn:aws:s3:::test bucket/*"}]}`),
+				}, nil)
+				m.EXPECT().PutBucketPolicyWithContext(gomock.Any(), &s3.PutBucketPolicyInput{
+					Bucket: aws.String("test bucket"),
+					Policy: aws.String(`{"Version":"2012-10-17","Statement":[` +
+						`{"Sid":"Other","Effect":"Deny","Principal":"*","Action":["s3:DeleteObject"],"Resource":["arn:aws:s3:::test bucket/*"]},` +
+						`{"Sid":"AddPerm","Effect":"Allow","Principal":"*","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::test bucket/*"]}]}`),
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   nil,
+		},
+		{
+			desc: "Should keep existing statements without sid or with NotAction",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(&s3.GetBucketPolicyOutput{
+					Policy: aws.String(`{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","NotAction":"s3:GetObject","Resource":"arn:aws:s3:::test bucket/*"}]}`),
+				}, nil)
+				m.EXPECT().PutBucketPolicyWithContext(gomock.Any(), &s3.PutBucketPolicyInput{
+					Bucket: aws.String("test bucket"),
+					Policy: aws.String(`{"Version":"2012-10-17","Statement":[` +
+						`{"Effect":"Deny","NotAction":"s3:GetObject","Principal":"*","Resource":["arn:aws:s3:::test bucket/*"]},` +
+						`{"Sid":"AddPerm","Effect":"Allow","Principal":"*","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::test bucket/*"]}]}`),
+				}).Return(nil)
 				return m
 			}(NewMockiS3Client(ctrl)),
 			generator: NewMockiGenerate(ctrl),
-			wantErr:   errors.New("test error"),
+			wantErr:   nil,
 		},
 		{
 			desc: "Should returns no error",
 			svc: func(m *MockiS3Client) *MockiS3Client {
-				m.EXPECT().PutBucketPolicy(gomock.Any()).Return(nil)
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil, errNoSuchBucketPolicy)
+				m.EXPECT().PutBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil)
 				return m
 			}(NewMockiS3Client(ctrl)),
 			generator: NewMockiGenerate(ctrl),
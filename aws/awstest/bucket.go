@@ -0,0 +1,96 @@
+package awstest
+
+import (
+	"sort"
+)
+
+// nullVersion is version id of objects written while versioning isn't enabled
+const nullVersion = "null"
+
+// bucket keeps versions of every key, the latest version is the last one
+type bucket struct {
+	versions   map[string][]Object
+	policy     string
+	versioning string
+	// cors and lifecycle are xml configurations as they were put
+	cors      []byte
+	lifecycle []byte
+}
+
+func newBucket() *bucket {
+	return &bucket{versions: make(map[string][]Object)}
+}
+
+// latest returns the latest version of the key, it may be delete marker
+func (b *bucket) latest(key string) (Object, bool) {
+	versions := b.versions[key]
+	if len(versions) == 0 {
+		return Object{}, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// current returns the latest version of the key, which isn't deleted
+func (b *bucket) current(key string) (Object, bool) {
+	obj, ok := b.latest(key)
+	if !ok || obj.DeleteMarker {
+		return Object{}, false
+	}
+	return obj, true
+}
+
+func (b *bucket) version(key, versionID string) (Object, bool) {
+	for _, obj := range b.versions[key] {
+		if obj.VersionID == versionID {
+			return obj, true
+		}
+	}
+	return Object{}, false
+}
+
+// put adds obj as the latest version, null version is replaced
+func (b *bucket) put(key string, obj Object) {
+	if obj.VersionID == nullVersion {
+		b.removeVersion(key, nullVersion)
+	}
+	b.versions[key] = append(b.versions[key], obj)
+}
+
+// removeVersion permanently removes the version
+func (b *bucket) removeVersion(key, versionID string) (Object, bool) {
+	versions := b.versions[key]
+	for i, obj := range versions {
+		if obj.VersionID == versionID {
+			versions = append(versions[:i:i], versions[i+1:]...)
+			if len(versions) == 0 {
+				delete(b.versions, key)
+			} else {
+				b.versions[key] = versions
+			}
+			return obj, true
+		}
+	}
+	return Object{}, false
+}
+
+// keys returns sorted keys which have current version
+func (b *bucket) keys() []string {
+	keys := make([]string, 0, len(b.versions))
+	for key := range b.versions {
+		if _, ok := b.current(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// versionedKeys returns sorted keys which have any version
+func (b *bucket) versionedKeys() []string {
+	keys := make([]string, 0, len(b.versions))
+	for key := range b.versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
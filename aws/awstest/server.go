@@ -0,0 +1,914 @@
+// Package awstest runs in-process fake of S3 REST api, so aws.S3Client can be tested
+// with real request signing, headers and error xml but without network access.
+//
+// Server supports path style requests of put, get, head, copy and delete of objects and their versions,
+// ListObjectsV2, ListObjectVersions, DeleteObjects, multipart uploads, creation of buckets, bucket policy,
+// versioning, CORS and lifecycle configurations. Bodies are checked by Content-MD5 when requests have it
+package awstest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/Stanly1995/golibs/aws"
+	"github.com/Stanly1995/golibs/data_generator"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRegion is region of the server
+	DefaultRegion = "us-east-1"
+	// DefaultID is access key id accepted by the server
+	DefaultID = "AKIAAWSTEST"
+	// DefaultSecret is secret access key accepted by the server
+	DefaultSecret = "awstest-secret"
+
+	// minPartSize is minimal size of every part except the last one
+	minPartSize = 5 * 1024 * 1024
+	// defaultMaxKeys is page size of ListObjectsV2
+	defaultMaxKeys = 1000
+	metaPrefix     = "X-Amz-Meta-"
+)
+
+// storedHeaders are headers of PutObject and CreateMultipartUpload returned by GetObject and HeadObject
+var storedHeaders = []string{
+	"Content-Type",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Cache-Control",
+	"X-Amz-Server-Side-Encryption",
+	"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id",
+}
+
+// Object is version of object stored by the server
+type Object struct {
+	Data         []byte
+	Header       http.Header
+	ETag         string
+	LastModified time.Time
+	// VersionID is "null" when versioning of the bucket isn't enabled
+	VersionID    string
+	DeleteMarker bool
+}
+
+type part struct {
+	data []byte
+	etag string
+}
+
+type multipartUpload struct {
+	bucket string
+	key    string
+	header http.Header
+	parts  map[int]part
+}
+
+// Server is fake of S3 which keeps buckets in memory
+type Server struct {
+	// URL is endpoint of the server
+	URL    string
+	Region string
+	ID     string
+	Secret string
+
+	srv     *httptest.Server
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	uploads map[string]*multipartUpload
+	seq     int
+	// clock is time of the latest change, it grows with every change
+	clock time.Time
+}
+
+// NewServer starts the server with empty buckets, it must be closed by Close
+func NewServer(buckets ...string) *Server {
+	s := &Server{
+		Region:  DefaultRegion,
+		ID:      DefaultID,
+		Secret:  DefaultSecret,
+		buckets: make(map[string]*bucket),
+		uploads: make(map[string]*multipartUpload),
+	}
+	for _, name := range buckets {
+		s.CreateBucket(name)
+	}
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// CreateBucket creates empty bucket, existing bucket isn't changed
+func (s *Server) CreateBucket(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[name]; !ok {
+		s.buckets[name] = newBucket()
+	}
+}
+
+// AWSInfo returns AWSInfo of the bucket with credentials accepted by the server
+func (s *Server) AWSInfo(bucket string) aws.AWSInfo {
+	return aws.AWSInfo{
+		Bucket: bucket,
+		URL:    s.URL + "/{bucket}",
+		Region: s.Region,
+		ID:     s.ID,
+		Secret: s.Secret,
+	}
+}
+
+// SessionConfig returns config which points sessions to the server
+func (s *Server) SessionConfig() aws.SessionConfig {
+	return aws.SessionConfig{
+		Credentials: aws.CredentialsStatic,
+		Endpoint:    s.URL,
+		PathStyle:   true,
+	}
+}
+
+// Client returns S3Client of the server
+func (s *Server) Client() *aws.S3Client {
+	client, err := aws.NewS3ClientFromInfo(s.AWSInfo(""), s.SessionConfig())
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// Connector returns AWSConnector of the bucket, the bucket is created when it doesn't exist
+func (s *Server) Connector(bucket string, timeout time.Duration, opts ...aws.Option) (*aws.AWSConnector, error) {
+	s.CreateBucket(bucket)
+	return aws.NewAWSConnector(s.AWSInfo(bucket), timeout, s.Client(), &data_generator.DataGenerators{}, opts...)
+}
+
+// Object returns copy of current version of stored object
+func (s *Server) Object(bucket, key string) (Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return Object{}, false
+	}
+	obj, ok := b.current(key)
+	if !ok {
+		return Object{}, false
+	}
+	return obj.clone(), true
+}
+
+// Corrupt replaces body of the current version of the key by data keeping its ETag and headers,
+// so clients can be tested on reading of corrupted objects. Returns false when there is no such key
+func (s *Server) Corrupt(bucket, key string, data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return false
+	}
+	obj, ok := b.current(key)
+	if !ok {
+		return false
+	}
+	versions := b.versions[key]
+	obj.Data = append([]byte(nil), data...)
+	versions[len(versions)-1] = obj
+	return true
+}
+
+// Versions returns copies of versions and delete markers of the key, the oldest first
+func (s *Server) Versions(bucket, key string) []Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	versions := make([]Object, 0, len(b.versions[key]))
+	for _, obj := range b.versions[key] {
+		versions = append(versions, obj.clone())
+	}
+	return versions
+}
+
+func (obj Object) clone() Object {
+	obj.Data = append([]byte(nil), obj.Data...)
+	obj.Header = obj.Header.Clone()
+	return obj
+}
+
+// Keys returns sorted keys of the bucket
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	return b.keys()
+}
+
+// BucketPolicy returns policy of the bucket, empty string means that policy isn't set
+func (s *Server) BucketPolicy(bucket string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[bucket]; ok {
+		return b.policy
+	}
+	return ""
+}
+
+// Versioning returns versioning status of the bucket, empty string means that versioning was never enabled
+func (s *Server) Versioning(bucket string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[bucket]; ok {
+		return b.versioning
+	}
+	return ""
+}
+
+// Uploads returns count of not completed multipart uploads
+func (s *Server) Uploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
+
+// s3Error is error response of the server
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *s3Error) Error() string {
+	return e.code + ": " + e.message
+}
+
+func errorf(status int, code, format string, args ...interface{}) *s3Error {
+	return &s3Error{status: status, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// ServeHTTP routes path style requests: /bucket and /bucket/key
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, readErr := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	requestID := fmt.Sprintf("%016X", s.seq)
+	w.Header().Set("X-Amz-Request-Id", requestID)
+
+	if readErr != nil {
+		s.writeError(w, r, requestID, errorf(http.StatusBadRequest, "IncompleteBody", "%v", readErr))
+		return
+	}
+	if err := s.verifySignature(r, body); err != nil {
+		s.writeError(w, r, requestID, errorf(http.StatusForbidden, "SignatureDoesNotMatch", "%v", err))
+		return
+	}
+	if err := verifyContentMD5(r, body); err != nil {
+		s.writeError(w, r, requestID, err)
+		return
+	}
+
+	name, key := splitPath(r.URL.Path)
+	var s3err *s3Error
+	if key == "" {
+		s3err = s.serveBucket(w, r, name, body)
+	} else {
+		s3err = s.serveObject(w, r, name, key, body)
+	}
+	if s3err != nil {
+		s.writeError(w, r, requestID, s3err)
+	}
+}
+
+func splitPath(p string) (bucket, key string) {
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, requestID string, e *s3Error) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(e.status)
+		return
+	}
+	writeXML(w, e.status, errorResponse{Code: e.code, Message: e.message, Resource: r.URL.Path, RequestID: requestID})
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+func (s *Server) bucket(name string) (*bucket, *s3Error) {
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "NoSuchBucket", "bucket %s doesn't exist", name)
+	}
+	return b, nil
+}
+
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request, name string, body []byte) *s3Error {
+	query := operationQuery(r.URL.Query())
+	if r.Method == http.MethodPut && len(query) == 0 {
+		if _, ok := s.buckets[name]; ok {
+			return errorf(http.StatusConflict, "BucketAlreadyOwnedByYou", "bucket %s already exists", name)
+		}
+		s.buckets[name] = newBucket()
+		w.Header().Set("Location", "/"+name)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	b, err := s.bucket(name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case has(query, "policy") && r.Method == http.MethodPut:
+		if !json.Valid(body) {
+			return errorf(http.StatusBadRequest, "MalformedPolicy", "policy isn't valid json")
+		}
+		b.policy = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	case has(query, "policy") && r.Method == http.MethodGet:
+		if b.policy == "" {
+			return errorf(http.StatusNotFound, "NoSuchBucketPolicy", "bucket %s has no policy", name)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(b.policy))
+	case has(query, "policy") && r.Method == http.MethodDelete:
+		b.policy = ""
+		w.WriteHeader(http.StatusNoContent)
+	case has(query, "versioning") && r.Method == http.MethodPut:
+		var cfg versioningConfiguration
+		if err := xml.Unmarshal(body, &cfg); err != nil || (cfg.Status != "Enabled" && cfg.Status != "Suspended") {
+			return errorf(http.StatusBadRequest, "MalformedXML", "invalid versioning configuration")
+		}
+		b.versioning = cfg.Status
+		w.WriteHeader(http.StatusOK)
+	case has(query, "versioning") && r.Method == http.MethodGet:
+		writeXML(w, http.StatusOK, versioningConfiguration{Xmlns: s3Namespace, Status: b.versioning})
+	case has(query, "cors"):
+		return serveConfiguration(w, r, &b.cors, body, "NoSuchCORSConfiguration")
+	case has(query, "lifecycle"):
+		return serveConfiguration(w, r, &b.lifecycle, body, "NoSuchLifecycleConfiguration")
+	case has(query, "versions") && r.Method == http.MethodGet:
+		return s.listObjectVersions(w, name, b, query)
+	case has(query, "delete") && r.Method == http.MethodPost:
+		return s.deleteObjects(w, b, body)
+	case query.Get("list-type") == "2" && r.Method == http.MethodGet:
+		return s.listObjectsV2(w, name, b, query)
+	case len(query) == 0 && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	default:
+		return errorf(http.StatusNotImplemented, "NotImplemented", "%s %s isn't supported", r.Method, r.URL.RequestURI())
+	}
+	return nil
+}
+
+// serveConfiguration puts, gets and deletes xml configuration of bucket
+func serveConfiguration(w http.ResponseWriter, r *http.Request, cfg *[]byte, body []byte, missingCode string) *s3Error {
+	switch r.Method {
+	case http.MethodPut:
+		var v struct{}
+		if err := xml.Unmarshal(body, &v); err != nil {
+			return errorf(http.StatusBadRequest, "MalformedXML", "%v", err)
+		}
+		*cfg = body
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if *cfg == nil {
+			return errorf(http.StatusNotFound, missingCode, "configuration doesn't exist")
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(*cfg)
+	case http.MethodDelete:
+		*cfg = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%s isn't allowed", r.Method)
+	}
+	return nil
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, name, key string, body []byte) *s3Error {
+	b, err := s.bucket(name)
+	if err != nil {
+		return err
+	}
+	query := operationQuery(r.URL.Query())
+	switch {
+	case has(query, "uploads") && r.Method == http.MethodPost:
+		return s.createMultipartUpload(w, r, name, key)
+	case has(query, "uploadId") && r.Method == http.MethodPut:
+		return s.uploadPart(w, query, name, key, body)
+	case has(query, "uploadId") && r.Method == http.MethodPost:
+		return s.completeMultipartUpload(w, query, b, name, key, body)
+	case has(query, "uploadId") && r.Method == http.MethodDelete:
+		if _, err := s.upload(query.Get("uploadId"), name, key); err != nil {
+			return err
+		}
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case has(query, "versionId") && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		obj, ok := b.version(key, query.Get("versionId"))
+		if !ok {
+			return errorf(http.StatusNotFound, "NoSuchVersion", "version %s doesn't exist", query.Get("versionId"))
+		}
+		if obj.DeleteMarker {
+			writeVersionHeaders(w, b, obj)
+			return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "version %s is delete marker", obj.VersionID)
+		}
+		return writeObject(w, r, b, obj)
+	case has(query, "versionId") && r.Method == http.MethodDelete:
+		if obj, ok := b.removeVersion(key, query.Get("versionId")); ok {
+			writeVersionHeaders(w, b, obj)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(query) > 0:
+		return errorf(http.StatusNotImplemented, "NotImplemented", "%s %s isn't supported", r.Method, r.URL.RequestURI())
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		return s.copyObject(w, r, b, key)
+	case r.Method == http.MethodPut:
+		obj := s.putObject(b, key, Object{Data: body, Header: objectHeader(r.Header), ETag: etag(body)})
+		writeVersionHeaders(w, b, obj)
+		w.Header().Set("ETag", obj.ETag)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := b.latest(key)
+		if !ok {
+			return errorf(http.StatusNotFound, "NoSuchKey", "key %s doesn't exist", key)
+		}
+		if obj.DeleteMarker {
+			writeVersionHeaders(w, b, obj)
+			return errorf(http.StatusNotFound, "NoSuchKey", "key %s is deleted", key)
+		}
+		return writeObject(w, r, b, obj)
+	case r.Method == http.MethodDelete:
+		if obj, ok := s.deleteObject(b, key); ok {
+			writeVersionHeaders(w, b, obj)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%s isn't allowed", r.Method)
+	}
+	return nil
+}
+
+// putObject stores obj as new version of the key
+func (s *Server) putObject(b *bucket, key string, obj Object) Object {
+	obj.VersionID = s.versionID(b)
+	obj.LastModified = s.now()
+	b.put(key, obj)
+	return obj
+}
+
+// deleteObject removes the key from unversioned bucket or adds delete marker to versioned one
+func (s *Server) deleteObject(b *bucket, key string) (Object, bool) {
+	if b.versioning == "" {
+		delete(b.versions, key)
+		return Object{}, false
+	}
+	return s.putObject(b, key, Object{DeleteMarker: true}), true
+}
+
+// versionID returns id of new version, it's "null" unless versioning is enabled
+func (s *Server) versionID(b *bucket) string {
+	if b.versioning != "Enabled" {
+		return nullVersion
+	}
+	s.seq++
+	return fmt.Sprintf("v%08d", s.seq)
+}
+
+// writeVersionHeaders sets version headers in buckets which ever had versioning enabled
+func writeVersionHeaders(w http.ResponseWriter, b *bucket, obj Object) {
+	if b.versioning == "" {
+		return
+	}
+	w.Header().Set("X-Amz-Version-Id", obj.VersionID)
+	if obj.DeleteMarker {
+		w.Header().Set("X-Amz-Delete-Marker", "true")
+	}
+}
+
+func writeObject(w http.ResponseWriter, r *http.Request, b *bucket, obj Object) *s3Error {
+	writeVersionHeaders(w, b, obj)
+	h := w.Header()
+	for k, v := range obj.Header {
+		h[k] = v
+	}
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "binary/octet-stream")
+	}
+	h.Set("ETag", obj.ETag)
+	h.Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	h.Set("Accept-Ranges", "bytes")
+
+	data, status := obj.Data, http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		start, end, ok := parseRange(rng, int64(len(obj.Data)))
+		if !ok {
+			return errorf(http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "range %s isn't satisfiable", rng)
+		}
+		data, status = obj.Data[start:end+1], http.StatusPartialContent
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(obj.Data)))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+	return nil
+}
+
+// parseRange parses single range bytes=start-end, bytes=start- or bytes=-suffix
+func parseRange(rng string, size int64) (start, end int64, ok bool) {
+	spec := strings.TrimPrefix(rng, "bytes=")
+	if spec == rng || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	bounds := strings.SplitN(spec, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+	var err error
+	switch {
+	case bounds[0] == "":
+		suffix, err := strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, false
+		}
+		if suffix > size {
+			suffix = size
+		}
+		start, end = size-suffix, size-1
+	default:
+		if start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+			return 0, 0, false
+		}
+		end = size - 1
+		if bounds[1] != "" {
+			if end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil || end < start {
+				return 0, 0, false
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+	}
+	return start, end, start < size
+}
+
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) *s3Error {
+	parts := strings.SplitN(r.Header.Get("X-Amz-Copy-Source"), "?", 2)
+	source, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "invalid copy source")
+	}
+	var sourceQuery url.Values
+	if len(parts) == 2 {
+		if sourceQuery, err = url.ParseQuery(parts[1]); err != nil {
+			return errorf(http.StatusBadRequest, "InvalidArgument", "invalid copy source")
+		}
+	}
+	srcName, srcKey := splitPath("/" + strings.TrimPrefix(source, "/"))
+	src, s3err := s.bucket(srcName)
+	if s3err != nil {
+		return s3err
+	}
+	obj, ok := src.current(srcKey)
+	if versionID := sourceQuery.Get("versionId"); versionID != "" {
+		obj, ok = src.version(srcKey, versionID)
+		if ok && obj.DeleteMarker {
+			return errorf(http.StatusBadRequest, "InvalidRequest", "version %s is delete marker", versionID)
+		}
+	}
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchKey", "key %s doesn't exist", srcKey)
+	}
+	obj = obj.clone()
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		obj.Header = objectHeader(r.Header)
+	}
+	obj = s.putObject(b, key, obj)
+	writeVersionHeaders(w, b, obj)
+	writeXML(w, http.StatusOK, copyObjectResult{Xmlns: s3Namespace, LastModified: obj.LastModified.Format(xmlTimeFormat), ETag: obj.ETag})
+	return nil
+}
+
+func (s *Server) deleteObjects(w http.ResponseWriter, b *bucket, body []byte) *s3Error {
+	var req deleteRequest
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return errorf(http.StatusBadRequest, "MalformedXML", "%v", err)
+	}
+	res := deleteResult{Xmlns: s3Namespace}
+	for _, o := range req.Objects {
+		deleted := deletedObject{Key: o.Key, VersionID: o.VersionID}
+		if o.VersionID != "" {
+			if obj, ok := b.removeVersion(o.Key, o.VersionID); ok && obj.DeleteMarker {
+				deleted.DeleteMarker, deleted.DeleteMarkerVersionID = true, obj.VersionID
+			}
+		} else if marker, ok := s.deleteObject(b, o.Key); ok {
+			deleted.DeleteMarker, deleted.DeleteMarkerVersionID = true, marker.VersionID
+		}
+		if !req.Quiet {
+			res.Deleted = append(res.Deleted, deleted)
+		}
+	}
+	writeXML(w, http.StatusOK, res)
+	return nil
+}
+
+func (s *Server) listObjectsV2(w http.ResponseWriter, name string, b *bucket, query url.Values) *s3Error {
+	res := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              name,
+		Prefix:            query.Get("prefix"),
+		Delimiter:         query.Get("delimiter"),
+		MaxKeys:           defaultMaxKeys,
+		ContinuationToken: query.Get("continuation-token"),
+		StartAfter:        query.Get("start-after"),
+	}
+	if v := query.Get("max-keys"); v != "" {
+		maxKeys, err := strconv.Atoi(v)
+		if err != nil || maxKeys < 0 {
+			return errorf(http.StatusBadRequest, "InvalidArgument", "invalid max-keys %s", v)
+		}
+		res.MaxKeys = maxKeys
+	}
+	after := res.StartAfter
+	if res.ContinuationToken != "" {
+		token, err := base64.StdEncoding.DecodeString(res.ContinuationToken)
+		if err != nil {
+			return errorf(http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+		}
+		after = string(token)
+	}
+
+	var last string
+	for _, key := range b.keys() {
+		if key <= after || !strings.HasPrefix(key, res.Prefix) {
+			continue
+		}
+		if res.KeyCount == res.MaxKeys {
+			res.IsTruncated = true
+			res.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+			break
+		}
+		if res.Delimiter != "" {
+			if i := strings.Index(key[len(res.Prefix):], res.Delimiter); i >= 0 {
+				common := key[:len(res.Prefix)+i+len(res.Delimiter)]
+				res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: common})
+				res.KeyCount++
+				// keys under the common prefix are greater than it and less than it followed by 0xff
+				last, after = common+"\xff", common+"\xff"
+				continue
+			}
+		}
+		obj, _ := b.current(key)
+		res.Contents = append(res.Contents, listObject{
+			Key:          key,
+			LastModified: obj.LastModified.Format(xmlTimeFormat),
+			ETag:         obj.ETag,
+			Size:         int64(len(obj.Data)),
+			StorageClass: "STANDARD",
+		})
+		res.KeyCount++
+		last = key
+	}
+	writeXML(w, http.StatusOK, res)
+	return nil
+}
+
+// listObjectVersions lists versions of keys in ascending order, versions of one key the newest first
+func (s *Server) listObjectVersions(w http.ResponseWriter, name string, b *bucket, query url.Values) *s3Error {
+	res := listVersionsResult{
+		Xmlns:           s3Namespace,
+		Name:            name,
+		Prefix:          query.Get("prefix"),
+		KeyMarker:       query.Get("key-marker"),
+		VersionIDMarker: query.Get("version-id-marker"),
+		MaxKeys:         defaultMaxKeys,
+	}
+	if v := query.Get("max-keys"); v != "" {
+		maxKeys, err := strconv.Atoi(v)
+		if err != nil || maxKeys < 0 {
+			return errorf(http.StatusBadRequest, "InvalidArgument", "invalid max-keys %s", v)
+		}
+		res.MaxKeys = maxKeys
+	}
+
+	count := 0
+	var lastKey, lastVersion string
+	for _, key := range b.versionedKeys() {
+		if !strings.HasPrefix(key, res.Prefix) || key < res.KeyMarker {
+			continue
+		}
+		versions := b.versions[key]
+		// without version marker the key marker itself is skipped
+		skipping := key == res.KeyMarker
+		for i := len(versions) - 1; i >= 0; i-- {
+			obj := versions[i]
+			if skipping {
+				skipping = res.VersionIDMarker == "" || obj.VersionID != res.VersionIDMarker
+				continue
+			}
+			if count == res.MaxKeys {
+				res.IsTruncated = true
+				res.NextKeyMarker, res.NextVersionIDMarker = lastKey, lastVersion
+				writeXML(w, http.StatusOK, res)
+				return nil
+			}
+			latest := i == len(versions)-1
+			modified := obj.LastModified.Format(xmlTimeFormat)
+			if obj.DeleteMarker {
+				res.DeleteMarkers = append(res.DeleteMarkers, deleteMarkerEntry{Key: key, VersionID: obj.VersionID, IsLatest: latest, LastModified: modified})
+			} else {
+				res.Versions = append(res.Versions, objectVersion{
+					Key:          key,
+					VersionID:    obj.VersionID,
+					IsLatest:     latest,
+					LastModified: modified,
+					ETag:         obj.ETag,
+					Size:         int64(len(obj.Data)),
+					StorageClass: "STANDARD",
+				})
+			}
+			count++
+			lastKey, lastVersion = key, obj.VersionID
+		}
+	}
+	writeXML(w, http.StatusOK, res)
+	return nil
+}
+
+func (s *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, name, key string) *s3Error {
+	s.seq++
+	id := fmt.Sprintf("upload-%d", s.seq)
+	s.uploads[id] = &multipartUpload{bucket: name, key: key, header: objectHeader(r.Header), parts: make(map[int]part)}
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{Xmlns: s3Namespace, Bucket: name, Key: key, UploadID: id})
+	return nil
+}
+
+func (s *Server) upload(id, name, key string) (*multipartUpload, *s3Error) {
+	u, ok := s.uploads[id]
+	if !ok || u.bucket != name || u.key != key {
+		return nil, errorf(http.StatusNotFound, "NoSuchUpload", "upload %s doesn't exist", id)
+	}
+	return u, nil
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, query url.Values, name, key string, body []byte) *s3Error {
+	u, err := s.upload(query.Get("uploadId"), name, key)
+	if err != nil {
+		return err
+	}
+	number, convErr := strconv.Atoi(query.Get("partNumber"))
+	if convErr != nil || number < 1 || number > 10000 {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "invalid part number %s", query.Get("partNumber"))
+	}
+	p := part{data: body, etag: etag(body)}
+	u.parts[number] = p
+	w.Header().Set("ETag", p.etag)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, query url.Values, b *bucket, name, key string, body []byte) *s3Error {
+	id := query.Get("uploadId")
+	u, err := s.upload(id, name, key)
+	if err != nil {
+		return err
+	}
+	var req completeMultipartUpload
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		return errorf(http.StatusBadRequest, "MalformedXML", "invalid list of parts")
+	}
+
+	var data bytes.Buffer
+	var sums []byte
+	for i, rp := range req.Parts {
+		if i > 0 && rp.PartNumber <= req.Parts[i-1].PartNumber {
+			return errorf(http.StatusBadRequest, "InvalidPartOrder", "parts must be in ascending order")
+		}
+		p, ok := u.parts[rp.PartNumber]
+		if !ok || p.etag != rp.ETag {
+			return errorf(http.StatusBadRequest, "InvalidPart", "part %d isn't uploaded", rp.PartNumber)
+		}
+		if i < len(req.Parts)-1 && len(p.data) < minPartSize {
+			return errorf(http.StatusBadRequest, "EntityTooSmall", "part %d is smaller than 5MB", rp.PartNumber)
+		}
+		data.Write(p.data)
+		sum := md5.Sum(p.data)
+		sums = append(sums, sum[:]...)
+	}
+	sum := md5.Sum(sums)
+	obj := s.putObject(b, key, Object{
+		Data:   data.Bytes(),
+		Header: u.header,
+		ETag:   fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(req.Parts)),
+	})
+	delete(s.uploads, id)
+	writeVersionHeaders(w, b, obj)
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: s.URL + "/" + name + "/" + key,
+		Bucket:   name,
+		Key:      key,
+		ETag:     obj.ETag,
+	})
+	return nil
+}
+
+// objectHeader takes headers, which are stored with object, from request
+func objectHeader(h http.Header) http.Header {
+	stored := make(http.Header)
+	for _, name := range storedHeaders {
+		if v := h.Get(name); v != "" {
+			stored.Set(name, v)
+		}
+	}
+	for name, v := range h {
+		if strings.HasPrefix(name, metaPrefix) {
+			stored[name] = v
+		}
+	}
+	return stored
+}
+
+// operationQuery drops authorization of presigned urls from query
+func operationQuery(query url.Values) url.Values {
+	for k := range query {
+		if strings.HasPrefix(k, "X-Amz-") {
+			delete(query, k)
+		}
+	}
+	return query
+}
+
+func has(query url.Values, name string) bool {
+	_, ok := query[name]
+	return ok
+}
+
+// verifyContentMD5 compares body with Content-MD5 header when request has it
+func verifyContentMD5(r *http.Request, body []byte) *s3Error {
+	contentMD5 := r.Header.Get("Content-MD5")
+	if contentMD5 == "" {
+		return nil
+	}
+	expected, err := base64.StdEncoding.DecodeString(contentMD5)
+	if err != nil || len(expected) != md5.Size {
+		return errorf(http.StatusBadRequest, "InvalidDigest", "invalid Content-MD5 %s", contentMD5)
+	}
+	if sum := md5.Sum(body); !bytes.Equal(sum[:], expected) {
+		return errorf(http.StatusBadRequest, "BadDigest", "Content-MD5 doesn't match body")
+	}
+	return nil
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// now returns time in milliseconds of xml responses, every change gets later time
+// so versions are ordered by time
+func (s *Server) now() time.Time {
+	t := time.Now().UTC().Truncate(time.Millisecond)
+	if !t.After(s.clock) {
+		t = s.clock.Add(time.Millisecond)
+	}
+	s.clock = t
+	return t
+}
@@ -0,0 +1,603 @@
+package awstest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/Stanly1995/golibs/aws"
+	"github.com/Stanly1995/golibs/aws/awstest"
+	"github.com/Stanly1995/golibs/cerr"
+	sdkaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const bucket = "test-bucket"
+
+func newConnector(t *testing.T, opts ...aws.Option) (*awstest.Server, *aws.AWSConnector) {
+	srv := awstest.NewServer()
+	t.Cleanup(srv.Close)
+	conn, err := srv.Connector(bucket, time.Minute, opts...)
+	require.NoError(t, err)
+	return srv, conn
+}
+
+func readAll(t *testing.T, body io.ReadCloser) string {
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	return string(data)
+}
+
+func TestServer_PutGet(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t)
+
+	// actual
+	key, putErr := conn.PutReader(context.Background(), "file.txt", "text/plain", strings.NewReader("hello world"), 11,
+		aws.WithObjectKey("dir/file +1.txt"),
+		aws.WithMetadata(map[string]string{"owner": "me"}),
+		aws.WithCacheControl("no-cache"))
+	body, info, getErr := conn.GetFile(context.Background(), key)
+
+	// assert
+	require.NoError(t, putErr)
+	require.NoError(t, getErr)
+	assert.Equal(t, "dir/file +1.txt", key)
+	assert.Equal(t, "hello world", readAll(t, body))
+	assert.Equal(t, int64(11), info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, "me", info.Metadata["Owner"])
+	obj, ok := srv.Object(bucket, key)
+	require.True(t, ok)
+	assert.Equal(t, "no-cache", obj.Header.Get("Cache-Control"))
+	assert.Equal(t, info.ETag, obj.ETag)
+}
+
+func TestServer_PutReaderShortBody(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t)
+
+	// actual
+	key, err := conn.PutReader(context.Background(), "a.txt", "text/plain", strings.NewReader("abc"), 10,
+		aws.WithObjectKey("a.txt"))
+
+	// assert
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, "", key)
+	_, ok := srv.Object(bucket, "a.txt")
+	assert.False(t, ok)
+}
+
+// flakyTransport answers the first failures requests with 503
+type flakyTransport struct {
+	failures int32
+}
+
+func (f *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if atomic.AddInt32(&f.failures, -1) >= 0 {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{},
+			Body: ioutil.NopCloser(strings.NewReader("")), Request: r}, nil
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestServer_PutReaderRetriesChunks(t *testing.T) {
+	// arrange
+	srv := awstest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.CreateBucket(bucket)
+	client := srv.Client()
+	client.Svc.Config.HTTPClient = &http.Client{Transport: &flakyTransport{failures: 1}}
+	conn, err := aws.NewAWSConnector(srv.AWSInfo(bucket), time.Minute, client, generator{})
+	require.NoError(t, err)
+
+	// actual
+	key, putErr := conn.PutReader(context.Background(), "a.txt", "text/plain", strings.NewReader("hello"), -1)
+
+	// assert
+	require.NoError(t, putErr)
+	obj, ok := srv.Object(bucket, key)
+	require.True(t, ok)
+	assert.Equal(t, "hello", string(obj.Data))
+}
+
+func TestServer_GetRange(t *testing.T) {
+	// arrange
+	_, conn := newConnector(t)
+	key, err := conn.PutReader(context.Background(), "file.txt", "text/plain", strings.NewReader("0123456789"), 10, aws.WithObjectKey("file.txt"))
+	require.NoError(t, err)
+
+	// actual
+	body, _, err := conn.GetFileRange(context.Background(), key, 2, 3)
+
+	// assert
+	require.NoError(t, err)
+	assert.Equal(t, "234", readAll(t, body))
+}
+
+func TestServer_NotFound(t *testing.T) {
+	// arrange
+	_, conn := newConnector(t)
+
+	// actual
+	_, _, getErr := conn.GetFile(context.Background(), "missing")
+	_, statErr := conn.Stat(context.Background(), "missing")
+
+	// assert
+	var s3Err aws.S3Error
+	require.True(t, errors.As(getErr, &s3Err), getErr)
+	assert.Equal(t, "NoSuchKey", s3Err.Code)
+	assert.Equal(t, http.StatusNotFound, s3Err.StatusCode)
+	assert.NotEmpty(t, s3Err.RequestID)
+	assert.True(t, errors.Is(getErr, cerr.ErrNotFound), getErr)
+	assert.True(t, errors.Is(statErr, cerr.ErrNotFound), statErr)
+}
+
+func TestServer_List(t *testing.T) {
+	// arrange
+	_, conn := newConnector(t)
+	for _, key := range []string{"b/1", "a/2", "a.txt", "a/1", "a/sub/3"} {
+		_, err := conn.PutReader(context.Background(), key, "", strings.NewReader(key), -1, aws.WithObjectKey(key))
+		require.NoError(t, err)
+	}
+
+	cases := []struct {
+		desc      string
+		prefix    string
+		delimiter string
+		wantKeys  []string
+	}{
+		{desc: "Should list all keys", wantKeys: []string{"a.txt", "a/1", "a/2", "a/sub/3", "b/1"}},
+		{desc: "Should list keys by prefix", prefix: "a/", wantKeys: []string{"a/1", "a/2", "a/sub/3"}},
+		{desc: "Should group keys by delimiter", delimiter: "/", wantKeys: []string{"a.txt", "a/", "b/"}},
+		{desc: "Should group keys under prefix by delimiter", prefix: "a/", delimiter: "/", wantKeys: []string{"a/1", "a/2", "a/sub/"}},
+	}
+
+	for _, c := range cases {
+		// actual
+		var gotKeys []string
+		it := conn.ListDir(context.Background(), c.prefix, c.delimiter)
+		for it.Next() {
+			gotKeys = append(gotKeys, it.Object().Key)
+		}
+
+		// assert
+		assert.NoError(t, it.Err(), c.desc)
+		assert.Equal(t, c.wantKeys, gotKeys, c.desc)
+	}
+}
+
+func TestServer_ListPages(t *testing.T) {
+	// arrange
+	srv := awstest.NewServer(bucket)
+	defer srv.Close()
+	svc := srv.Client().Svc
+	for _, key := range []string{"a/1", "a/2", "b/1", "c", "d/1"} {
+		_, err := svc.PutObject(&s3.PutObjectInput{Bucket: sdkaws.String(bucket), Key: sdkaws.String(key), Body: strings.NewReader(key)})
+		require.NoError(t, err)
+	}
+
+	// actual
+	var pages [][]string
+	err := svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:    sdkaws.String(bucket),
+		Delimiter: sdkaws.String("/"),
+		MaxKeys:   sdkaws.Int64(2),
+	}, func(out *s3.ListObjectsV2Output, last bool) bool {
+		var page []string
+		for _, p := range out.CommonPrefixes {
+			page = append(page, *p.Prefix)
+		}
+		for _, obj := range out.Contents {
+			page = append(page, *obj.Key)
+		}
+		pages = append(pages, page)
+		return true
+	})
+
+	// assert
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a/", "b/"}, {"d/", "c"}}, pages)
+}
+
+func TestServer_DeleteAndCopy(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t)
+	for _, key := range []string{"1", "2", "3"} {
+		_, err := conn.PutReader(context.Background(), key, "", strings.NewReader(key), -1, aws.WithObjectKey(key))
+		require.NoError(t, err)
+	}
+
+	// actual
+	copyErr := conn.Copy(context.Background(), aws.ObjectRef{Key: "1"}, aws.ObjectRef{Key: "copy/1"})
+	deleteErr := conn.Delete(context.Background(), "2")
+	deleteManyErr := conn.DeleteMany(context.Background(), []string{"1", "3", "missing"})
+
+	// assert
+	assert.NoError(t, copyErr)
+	assert.NoError(t, deleteErr)
+	assert.NoError(t, deleteManyErr)
+	assert.Equal(t, []string{"copy/1"}, srv.Keys(bucket))
+}
+
+func TestServer_Multipart(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t, aws.WithMultipartConfig(aws.MultipartConfig{PartSize: aws.MinPartSize, Concurrency: 2}))
+	data := bytes.Repeat([]byte("0123456789"), int(aws.MinPartSize)/10*2+1)
+
+	// actual
+	key, err := conn.PutMultipart(context.Background(), "big.bin", "application/octet-stream", bytes.NewReader(data), aws.WithObjectKey("big.bin"))
+
+	// assert
+	require.NoError(t, err)
+	obj, ok := srv.Object(bucket, key)
+	require.True(t, ok)
+	assert.Equal(t, data, obj.Data)
+	assert.True(t, strings.HasSuffix(obj.ETag, `-3"`), obj.ETag)
+	assert.Equal(t, "application/octet-stream", obj.Header.Get("Content-Type"))
+	assert.Equal(t, 0, srv.Uploads())
+}
+
+func TestServer_BucketPolicy(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t)
+
+	deny := aws.NewStatement("DenyDelete", aws.EffectDeny).
+		WithPrincipal(aws.AnyPrincipal()).
+		WithActions("s3:DeleteObject").
+		WithPrefixes(bucket, "")
+
+	// actual
+	setErr := conn.SetBucketReadOnlyPolicy()
+	addErr := conn.AddPolicyStatements(context.Background(), deny)
+	policy, getErr := conn.GetBucketPolicy(context.Background())
+	removeErr := conn.RemovePolicyStatements(context.Background(), aws.ReadOnlySid, "DenyDelete")
+	_, missingErr := conn.GetBucketPolicy(context.Background())
+
+	// assert
+	require.NoError(t, setErr)
+	require.NoError(t, addErr)
+	require.NoError(t, getErr)
+	require.NoError(t, removeErr)
+	assert.Equal(t, aws.NewBucketPolicy(aws.ReadOnlyStatement(bucket), deny), policy)
+	assert.True(t, errors.Is(missingErr, cerr.ErrNotFound), missingErr)
+	assert.Empty(t, srv.BucketPolicy(bucket))
+}
+
+func TestServer_BucketAdmin(t *testing.T) {
+	// arrange
+	srv := awstest.NewServer()
+	defer srv.Close()
+	conn, err := aws.NewAWSConnector(srv.AWSInfo("new-bucket"), time.Minute, srv.Client(), generator{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// actual
+	for i := 0; i < 2; i++ {
+		require.NoError(t, conn.EnsureBucket(ctx))
+		require.NoError(t, conn.EnableVersioning(ctx))
+		require.NoError(t, conn.SetCORSRules(ctx, aws.BrowserUploadCORSRule("https://example.com")))
+		require.NoError(t, conn.AddLifecycleRules(ctx,
+			aws.ExpireRule("tmp", "tmp/", 1),
+			aws.AbortIncompleteUploadsRule("abort", 7)))
+	}
+	require.NoError(t, conn.RemoveLifecycleRules(ctx, "tmp"))
+	lifecycle, lifecycleErr := srv.Client().Svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{Bucket: sdkaws.String("new-bucket")})
+	cors, corsErr := srv.Client().Svc.GetBucketCors(&s3.GetBucketCorsInput{Bucket: sdkaws.String("new-bucket")})
+
+	// assert
+	require.NoError(t, lifecycleErr)
+	require.NoError(t, corsErr)
+	assert.Equal(t, "Enabled", srv.Versioning("new-bucket"))
+	require.Len(t, lifecycle.Rules, 1)
+	assert.Equal(t, "abort", *lifecycle.Rules[0].ID)
+	assert.Equal(t, int64(7), *lifecycle.Rules[0].AbortIncompleteMultipartUpload.DaysAfterInitiation)
+	require.Len(t, cors.CORSRules, 1)
+	assert.Equal(t, []*string{sdkaws.String("https://example.com")}, cors.CORSRules[0].AllowedOrigins)
+}
+
+func TestServer_Versions(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t)
+	ctx := context.Background()
+	require.NoError(t, conn.EnableVersioning(ctx))
+	put := func(body string) {
+		_, err := conn.PutReader(ctx, "file.txt", "text/plain", strings.NewReader(body), -1, aws.WithObjectKey("file.txt"))
+		require.NoError(t, err)
+	}
+	put("first")
+	put("second")
+	require.NoError(t, conn.Delete(ctx, "file.txt"))
+
+	// actual
+	versions, listErr := conn.ListVersions(ctx, "file.txt")
+	require.NoError(t, listErr)
+	require.Len(t, versions, 3)
+	_, _, deletedErr := conn.GetFile(ctx, "file.txt")
+	old, _, oldErr := conn.GetFile(ctx, "file.txt", aws.WithVersionID(versions[2].VersionID))
+	require.NoError(t, oldErr)
+	restoreErr := conn.RestoreVersion(ctx, "file.txt", versions[2].VersionID)
+	restored, _, restoredErr := conn.GetFile(ctx, "file.txt")
+	require.NoError(t, restoredErr)
+	deleteVersionErr := conn.DeleteVersion(ctx, "file.txt", versions[1].VersionID)
+	afterDelete := srv.Versions(bucket, "file.txt")
+	purgeErr := conn.PurgeVersions(ctx, "file.txt")
+
+	// assert
+	assert.True(t, versions[0].IsLatest)
+	assert.True(t, versions[0].IsDeleteMarker)
+	assert.Equal(t, int64(len("second")), versions[1].Size)
+	assert.Equal(t, int64(len("first")), versions[2].Size)
+	assert.True(t, errors.Is(deletedErr, cerr.ErrNotFound), deletedErr)
+	assert.Equal(t, "first", readAll(t, old))
+	assert.NoError(t, restoreErr)
+	assert.Equal(t, "first", readAll(t, restored))
+	assert.NoError(t, deleteVersionErr)
+	assert.Len(t, afterDelete, 3)
+	assert.NoError(t, purgeErr)
+	assert.Empty(t, srv.Versions(bucket, "file.txt"))
+}
+
+func TestServer_PresignGet(t *testing.T) {
+	// arrange
+	_, conn := newConnector(t)
+	_, err := conn.PutReader(context.Background(), "file.txt", "text/plain", strings.NewReader("body"), -1, aws.WithObjectKey("dir/file.txt"))
+	require.NoError(t, err)
+
+	// actual
+	u, presignErr := conn.PresignGetURL("dir/file.txt", time.Minute)
+	require.NoError(t, presignErr)
+	resp, getErr := http.Get(u)
+	require.NoError(t, getErr)
+	tampered, tamperedErr := http.Get(strings.Replace(u, "dir/file.txt", "dir/other.txt", 1))
+	require.NoError(t, tamperedErr)
+	tampered.Body.Close()
+
+	// assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "body", readAll(t, resp.Body))
+	assert.Equal(t, http.StatusForbidden, tampered.StatusCode)
+}
+
+func TestServer_InvalidSignature(t *testing.T) {
+	// arrange
+	srv := awstest.NewServer(bucket)
+	defer srv.Close()
+	info := srv.AWSInfo(bucket)
+	info.Secret = "wrong secret"
+	client, err := aws.NewS3ClientFromInfo(info, srv.SessionConfig())
+	require.NoError(t, err)
+	conn, err := aws.NewAWSConnector(info, time.Minute, client, generator{},
+		aws.WithRetryPolicy(aws.RetryPolicy{MaxAttempts: 1}))
+	require.NoError(t, err)
+
+	// actual
+	_, err = conn.PutReader(context.Background(), "file.txt", "", strings.NewReader("body"), -1)
+
+	// assert
+	var s3Err aws.S3Error
+	require.True(t, errors.As(err, &s3Err), err)
+	assert.Equal(t, "SignatureDoesNotMatch", s3Err.Code)
+	assert.Equal(t, http.StatusForbidden, s3Err.StatusCode)
+	assert.Empty(t, srv.Keys(bucket))
+}
+
+func TestServer_Checksum(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t)
+	fileObj := "name:{hello.txt},dataUrl:{data:text/plain,hello}"
+
+	// actual
+	result, putErr := conn.PutFileURL(context.Background(), &fileObj, aws.WithObjectKey("hello.txt"))
+	body, _, getErr := conn.GetFile(context.Background(), result.Key)
+
+	// assert
+	require.NoError(t, putErr)
+	require.NoError(t, getErr)
+	assert.Equal(t, "hello", readAll(t, body))
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", result.Checksum.MD5)
+	obj, ok := srv.Object(bucket, result.Key)
+	require.True(t, ok)
+	assert.Equal(t, result.Checksum.SHA256, obj.Header.Get("X-Amz-Meta-Sha256"))
+
+	// actual
+	require.True(t, srv.Corrupt(bucket, result.Key, []byte("hellO")))
+	body, _, getErr = conn.GetFile(context.Background(), result.Key)
+	require.NoError(t, getErr)
+	_, readErr := ioutil.ReadAll(body)
+	body.Close()
+
+	// assert
+	assert.Equal(t, aws.ErrChecksumMismatch, readErr)
+}
+
+func TestServer_Dedup(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t, aws.WithDedup(aws.DedupConfig{}))
+	fileObj := "name:{hello.txt},dataUrl:{data:text/plain,hello}"
+	first, err := conn.PutFile(context.Background(), &fileObj)
+	require.NoError(t, err)
+
+	// actual
+	second, err := conn.PutFile(context.Background(), &fileObj)
+	require.NoError(t, err)
+	refs, refsErr := conn.References(context.Background(), first)
+
+	// assert
+	require.NoError(t, refsErr)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 2, refs)
+	assert.Len(t, srv.Versions(bucket, first), 1)
+
+	// actual
+	require.NoError(t, conn.Delete(context.Background(), first))
+	_, stored := srv.Object(bucket, first)
+
+	// assert
+	assert.True(t, stored)
+
+	// actual
+	require.NoError(t, conn.Delete(context.Background(), second))
+
+	// assert
+	assert.Empty(t, srv.Keys(bucket))
+}
+
+func TestServer_Progress(t *testing.T) {
+	// arrange
+	_, conn := newConnector(t, aws.WithMultipartConfig(aws.MultipartConfig{PartSize: aws.MinPartSize, Concurrency: 2}))
+	data := bytes.Repeat([]byte("0123456789"), int(aws.MinPartSize)/10+1)
+	var uploaded, downloaded []aws.Progress
+
+	// actual
+	key, putErr := conn.PutMultipart(context.Background(), "big.bin", "", bytes.NewReader(data),
+		aws.WithUploadProgress(func(p aws.Progress) { uploaded = append(uploaded, p) }))
+	require.NoError(t, putErr)
+	body, _, getErr := conn.GetFile(context.Background(), key,
+		aws.WithDownloadProgress(func(p aws.Progress) { downloaded = append(downloaded, p) }))
+	require.NoError(t, getErr)
+	readAll(t, body)
+
+	// assert
+	require.NotEmpty(t, uploaded)
+	last := uploaded[len(uploaded)-1]
+	assert.Equal(t, int64(len(data)), last.Transferred)
+	assert.Equal(t, int64(-1), last.Total)
+	parts := map[int64]int64{}
+	for _, p := range uploaded {
+		parts[p.PartNumber] = p.PartTotal
+	}
+	assert.Equal(t, map[int64]int64{1: aws.MinPartSize, 2: 10}, parts)
+	require.NotEmpty(t, downloaded)
+	assert.Equal(t, aws.Progress{
+		Key:             key,
+		Transferred:     int64(len(data)),
+		Total:           int64(len(data)),
+		PartTransferred: int64(len(data)),
+		PartTotal:       int64(len(data)),
+	}, downloaded[len(downloaded)-1])
+}
+
+func TestServer_BandwidthLimit(t *testing.T) {
+	// arrange
+	limiter, err := aws.NewBandwidthLimiter(100 * 1024)
+	require.NoError(t, err)
+	_, conn := newConnector(t, aws.WithBandwidthLimit(limiter))
+	fileObj := "name:{file.bin},dataUrl:{data:application/octet-stream;base64," +
+		base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("a"), 120*1024)) + "}"
+	start := time.Now()
+
+	// actual
+	_, err = conn.PutFile(context.Background(), &fileObj)
+
+	// assert
+	require.NoError(t, err)
+	// the first 100KB are the burst, the rest is sent at 100KB per second
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(150*time.Millisecond))
+}
+
+func TestServer_BadDigest(t *testing.T) {
+	// arrange
+	srv := awstest.NewServer(bucket)
+	defer srv.Close()
+
+	// actual
+	_, err := srv.Client().Svc.PutObject(&s3.PutObjectInput{
+		Bucket:     sdkaws.String(bucket),
+		Key:        sdkaws.String("file.txt"),
+		Body:       strings.NewReader("body"),
+		ContentMD5: sdkaws.String("XUFAKrxLKna5cZ2REBfFkg=="),
+	})
+
+	// assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "BadDigest")
+	assert.Empty(t, srv.Keys(bucket))
+}
+
+// failingReader returns error after n bytes, like a dropped connection of a client
+type failingReader struct {
+	r io.Reader
+	n int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errors.New("connection reset")
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= n
+	return n, err
+}
+
+func TestServer_ResumableUpload(t *testing.T) {
+	// arrange
+	store, err := aws.NewFileUploadStore(t.TempDir())
+	require.NoError(t, err)
+	srv, conn := newConnector(t, aws.WithUploadStateStore(store))
+	data := bytes.Repeat([]byte("0123456789"), aws.MinPartSize/5+1)
+
+	// actual
+	session, startErr := conn.StartUpload(context.Background(), "big.bin", "", int64(len(data)))
+	require.NoError(t, startErr)
+	_, failedErr := conn.ResumeUpload(context.Background(), session.ID,
+		&failingReader{r: bytes.NewReader(data), n: aws.MinPartSize * 3 / 2})
+	resumed, getErr := conn.GetUploadSession(context.Background(), session.ID)
+	require.NoError(t, getErr)
+	_, truncatedErr := conn.ResumeUpload(context.Background(), session.ID, bytes.NewReader(data[resumed.Offset():len(data)-1]))
+	truncated, getErr := conn.GetUploadSession(context.Background(), session.ID)
+	require.NoError(t, getErr)
+	key, resumeErr := conn.ResumeUpload(context.Background(), session.ID, bytes.NewReader(data[truncated.Offset():]))
+	_, removedErr := conn.GetUploadSession(context.Background(), session.ID)
+
+	// assert
+	assert.Error(t, failedErr)
+	assert.Equal(t, int64(aws.MinPartSize), resumed.Offset())
+	assert.Equal(t, aws.ErrIncompleteUpload, truncatedErr)
+	assert.Equal(t, int64(2*aws.MinPartSize), truncated.Offset())
+	require.NoError(t, resumeErr)
+	assert.Equal(t, session.Key, key)
+	obj, ok := srv.Object(bucket, key)
+	require.True(t, ok)
+	assert.Equal(t, data, obj.Data)
+	assert.True(t, strings.HasSuffix(obj.ETag, `-3"`), obj.ETag)
+	assert.True(t, errors.Is(removedErr, cerr.ErrNotFound))
+	assert.Equal(t, 0, srv.Uploads())
+}
+
+func TestServer_CleanupUploads(t *testing.T) {
+	// arrange
+	srv, conn := newConnector(t, aws.WithUploadStateStore(aws.NewMemoryUploadStore()))
+	session, err := conn.StartUpload(context.Background(), "big.bin", "application/octet-stream", aws.MinPartSize+1)
+	require.NoError(t, err)
+	_, _ = conn.ResumeUpload(context.Background(), session.ID, &failingReader{r: bytes.NewReader(make([]byte, aws.MinPartSize+1)), n: aws.MinPartSize})
+	require.Equal(t, 1, srv.Uploads())
+
+	// actual
+	cleaned, cleanErr := conn.CleanupUploads(context.Background(), 0)
+	_, getErr := conn.GetUploadSession(context.Background(), session.ID)
+
+	// assert
+	assert.NoError(t, cleanErr)
+	assert.Equal(t, 1, cleaned)
+	assert.True(t, errors.Is(getErr, cerr.ErrNotFound))
+	assert.Equal(t, 0, srv.Uploads())
+}
+
+type generator struct{}
+
+func (generator) GenerateTime() string { return "time" }
+func (generator) GenerateUUID() string { return "uuid" }
@@ -0,0 +1,211 @@
+package awstest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm    = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// signedRequest is signature of the request taken from Authorization header or presigned query
+type signedRequest struct {
+	accessKey     string
+	date          string
+	region        string
+	signedHeaders []string
+	signature     string
+	amzDate       string
+	payloadHash   string
+	presigned     bool
+	expires       string
+}
+
+// verifySignature checks signature version 4 of the request with header or query authorization
+func (s *Server) verifySignature(r *http.Request, body []byte) error {
+	sr, err := parseSignature(r)
+	if err != nil {
+		return err
+	}
+	if sr.accessKey != s.ID {
+		return fmt.Errorf("unknown access key %s", sr.accessKey)
+	}
+	if sr.presigned {
+		if err := sr.checkExpiration(); err != nil {
+			return err
+		}
+	}
+	if !sr.presigned && sr.payloadHash != unsignedPayload {
+		if sr.payloadHash == streamingPayload {
+			return fmt.Errorf("chunked payload is not supported")
+		}
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != sr.payloadHash {
+			return fmt.Errorf("body doesn't match x-amz-content-sha256")
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		strings.SplitN(r.RequestURI, "?", 2)[0],
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, sr.signedHeaders),
+		strings.Join(sr.signedHeaders, ";"),
+		sr.payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.Join([]string{sr.date, sr.region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{signAlgorithm, sr.amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.Secret), sr.date)
+	key = hmacSHA256(key, sr.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	if !hmac.Equal([]byte(hex.EncodeToString(hmacSHA256(key, stringToSign))), []byte(sr.signature)) {
+		return fmt.Errorf("signature doesn't match")
+	}
+	return nil
+}
+
+func parseSignature(r *http.Request) (signedRequest, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != "" {
+		return parseQuerySignature(query)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, signAlgorithm+" ") {
+		return signedRequest{}, fmt.Errorf("request is not signed by signature version 4")
+	}
+	sr := signedRequest{
+		amzDate:     r.Header.Get("X-Amz-Date"),
+		payloadHash: r.Header.Get("X-Amz-Content-Sha256"),
+	}
+	var credential string
+	for _, part := range strings.Split(strings.TrimPrefix(auth, signAlgorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return signedRequest{}, fmt.Errorf("malformed authorization header")
+		}
+		switch kv[0] {
+		case "Credential":
+			credential = kv[1]
+		case "SignedHeaders":
+			sr.signedHeaders = strings.Split(kv[1], ";")
+		case "Signature":
+			sr.signature = kv[1]
+		}
+	}
+	if sr.payloadHash == "" {
+		return signedRequest{}, fmt.Errorf("x-amz-content-sha256 header is required")
+	}
+	return sr, sr.parseCredential(credential)
+}
+
+func parseQuerySignature(query url.Values) (signedRequest, error) {
+	if query.Get("X-Amz-Algorithm") != signAlgorithm {
+		return signedRequest{}, fmt.Errorf("unknown signature algorithm")
+	}
+	sr := signedRequest{
+		amzDate:       query.Get("X-Amz-Date"),
+		signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		signature:     query.Get("X-Amz-Signature"),
+		payloadHash:   unsignedPayload,
+		presigned:     true,
+		expires:       query.Get("X-Amz-Expires"),
+	}
+	return sr, sr.parseCredential(query.Get("X-Amz-Credential"))
+}
+
+func (sr signedRequest) checkExpiration() error {
+	signed, err := time.Parse(amzDateFormat, sr.amzDate)
+	if err != nil {
+		return fmt.Errorf("invalid x-amz-date")
+	}
+	expires, err := strconv.Atoi(sr.expires)
+	if err != nil {
+		return fmt.Errorf("invalid x-amz-expires")
+	}
+	if time.Now().After(signed.Add(time.Duration(expires) * time.Second)) {
+		return fmt.Errorf("request has expired")
+	}
+	return nil
+}
+
+// parseCredential parses <access key>/<date>/<region>/s3/aws4_request
+func (sr *signedRequest) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return fmt.Errorf("malformed credential scope")
+	}
+	sr.accessKey, sr.date, sr.region = parts[0], parts[1], parts[2]
+	return nil
+}
+
+// canonicalQuery sorts query by keys and values, signature itself is not signed
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for k, values := range query {
+		if k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			values := r.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(name + ":" + value + "\n")
+	}
+	return b.String()
+}
+
+// uriEncode escapes everything except unreserved characters the way signature version 4 requires
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,146 @@
+package aws
+
+import (
+	"context"
+	"github.com/Stanly1995/golibs/cerr"
+	"sync"
+	"time"
+)
+
+// DefaultBatchConcurrency is used when BatchConfig.Concurrency is not set
+const DefaultBatchConcurrency = 4
+
+const (
+	// ErrInvalidBatchConfig is error, which is returned when concurrency or file timeout of batch is negative
+	ErrInvalidBatchConfig = cerr.New("batch concurrency and file timeout must not be negative")
+
+	// ErrSkipped is error, which is returned for files of batch which were not uploaded
+	// because upload of another file failed
+	ErrSkipped = cerr.New("upload skipped after failure of another file")
+)
+
+// BatchConfig configures uploads of PutFiles
+type BatchConfig struct {
+	// Concurrency is count of files uploaded in parallel
+	Concurrency int
+	// FileTimeout limits upload of one file, zero means timeout of AWSConnector only
+	FileTimeout time.Duration
+	// StopOnError cancels uploads in progress and skips the rest of files after the first failure
+	StopOnError bool
+}
+
+func (cfg BatchConfig) validate() error {
+	if cfg.Concurrency < 0 || cfg.FileTimeout < 0 {
+		return ErrInvalidBatchConfig
+	}
+	return nil
+}
+
+func (cfg BatchConfig) withDefaults() BatchConfig {
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = DefaultBatchConcurrency
+	}
+	return cfg
+}
+
+// WithBatchConfig sets concurrency, file timeout and error handling of PutFiles
+func WithBatchConfig(cfg BatchConfig) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.batch = cfg
+	}
+}
+
+// FileResult is result of upload of one file of the batch
+type FileResult struct {
+	PutResult
+	// Err is nil when the file is stored
+	Err error
+}
+
+// PutFiles uploads files in the same format as PutFile by a pool of workers and returns results in order of files,
+// result of every file is the same as of PutFileURL. opts are applied to every file. Returns the first failure, results keep error of every file.
+// Files which were not started are failed with ErrSkipped after failure when BatchConfig.StopOnError is set,
+// or with error of ctx when it's done
+func (awsConn *AWSConnector) PutFiles(ctx context.Context, files []string, opts ...PutOption) ([]FileResult, error) {
+	cfg := awsConn.batch.withDefaults()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	batchCtx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+
+	var (
+		results  = make([]FileResult, len(files))
+		jobs     = make(chan int)
+		firstErr error
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+	skipped := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrSkipped
+	}
+
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if batchCtx.Err() != nil {
+					results[i].Err = skipped()
+					continue
+				}
+				result, err := awsConn.putBatchFile(batchCtx, cfg, files[i], opts)
+				results[i] = FileResult{PutResult: result, Err: err}
+				if err == nil {
+					continue
+				}
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				if cfg.StopOnError {
+					cancelFn()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-batchCtx.Done():
+			for ; i < len(files); i++ {
+				results[i].Err = skipped()
+			}
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// files may be skipped only because ctx is done
+	for i := 0; firstErr == nil && i < len(results); i++ {
+		firstErr = results[i].Err
+	}
+	return results, firstErr
+}
+
+// putBatchFile uploads one file of the batch limited by file timeout the same way as PutFileURL
+func (awsConn *AWSConnector) putBatchFile(ctx context.Context, cfg BatchConfig, file string, opts []PutOption) (PutResult, error) {
+	if cfg.FileTimeout > 0 {
+		var cancelFn func()
+		ctx, cancelFn = context.WithTimeout(ctx, cfg.FileTimeout)
+		defer cancelFn()
+	}
+	result, err := awsConn.putFile(ctx, &file, opts)
+	if err != nil {
+		return PutResult{}, err
+	}
+	result.URL, err = awsConn.ObjectURL(result.Key)
+	return result, err
+}
@@ -0,0 +1,197 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_WithBatchConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		cfg     BatchConfig
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when concurrency is negative",
+			cfg:     BatchConfig{Concurrency: -1},
+			wantErr: ErrInvalidBatchConfig,
+		},
+		{
+			desc:    "Should returns error when file timeout is negative",
+			cfg:     BatchConfig{FileTimeout: -time.Second},
+			wantErr: ErrInvalidBatchConfig,
+		},
+		{
+			desc:    "Should returns no error",
+			cfg:     BatchConfig{Concurrency: 2, FileTimeout: time.Second, StopOnError: true},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			_, gotErr := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl), WithBatchConfig(c.cfg))
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFiles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const (
+		fileA   = "name:{a.txt},dataUrl:{data:text/plain,a}"
+		fileB   = "name:{b.txt},dataUrl:{data:text/plain,b}"
+		invalid = "invalid file"
+	)
+	generator := func(m *MockiGenerate) *MockiGenerate {
+		m.EXPECT().GenerateTime().Return("time").AnyTimes()
+		m.EXPECT().GenerateUUID().Return("111").AnyTimes()
+		return m
+	}
+	canceled, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+
+	// arrange
+	cases := []struct {
+		desc     string
+		ctx      context.Context
+		cfg      BatchConfig
+		files    []string
+		svc      *MockiS3Client
+		wantKeys []string
+		wantErrs []error
+		wantErr  error
+	}{
+		{
+			desc:  "Should returns results in order of files and continues after failure",
+			ctx:   context.Background(),
+			cfg:   BatchConfig{Concurrency: 2},
+			files: []string{fileA, invalid, fileB},
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantKeys: []string{"time_111_a.txt", "", "time_111_b.txt"},
+			wantErrs: []error{nil, ErrInvalidFileObject, nil},
+			wantErr:  ErrInvalidFileObject,
+		},
+		{
+			desc:  "Should skips the rest of files after failure when StopOnError is set",
+			ctx:   context.Background(),
+			cfg:   BatchConfig{Concurrency: 1, StopOnError: true},
+			files: []string{fileA, fileB, fileA},
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				gomock.InOrder(
+					m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).Return(nil),
+					m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error")),
+				)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantKeys: []string{"time_111_a.txt", "", ""},
+			wantErrs: []error{
+				nil,
+				S3Error{Operation: "PutObject", Bucket: "test bucket", Key: "time_111_b.txt", Attempts: 1, Err: errors.New("test error")},
+				ErrSkipped,
+			},
+			wantErr: S3Error{Operation: "PutObject", Bucket: "test bucket", Key: "time_111_b.txt", Attempts: 1, Err: errors.New("test error")},
+		},
+		{
+			desc:     "Should returns error of context when it's done",
+			ctx:      canceled,
+			files:    []string{fileA, fileB},
+			svc:      NewMockiS3Client(ctrl),
+			wantKeys: []string{"", ""},
+			wantErrs: []error{context.Canceled, context.Canceled},
+			wantErr:  context.Canceled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "https://cdn.example.com",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, generator(NewMockiGenerate(ctrl)),
+				WithBatchConfig(c.cfg), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+			// actual
+			got, gotErr := aws.PutFiles(c.ctx, c.files)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+			if assert.Len(t, got, len(c.files)) {
+				for i := range got {
+					assert.Equal(t, c.wantKeys[i], got[i].Key)
+					assert.Equal(t, c.wantErrs[i], got[i].Err)
+				}
+			}
+			if got[0].Err == nil {
+				assert.Equal(t, "https://cdn.example.com/time_111_a.txt", got[0].URL)
+			}
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFilesConcurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time").AnyTimes()
+	generator.EXPECT().GenerateUUID().Return("111").AnyTimes()
+
+	var active, maxActive int32
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ *s3.PutObjectInput) error {
+			n := atomic.AddInt32(&active, 1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			return nil
+		}).Times(10)
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "https://cdn.example.com",
+	}
+	aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator, WithBatchConfig(BatchConfig{Concurrency: 3}))
+	files := make([]string, 10)
+	for i := range files {
+		files[i] = "name:{a.txt},dataUrl:{data:text/plain,a}"
+	}
+
+	// actual
+	got, gotErr := aws.PutFiles(context.Background(), files)
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Len(t, got, 10)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxActive), int32(3))
+}
@@ -0,0 +1,371 @@
+package aws
+
+import (
+	"context"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"net/http"
+	"time"
+)
+
+// defaultRegion is region where bucket is created without location constraint
+const defaultRegion = "us-east-1"
+
+const (
+	// ErrInvalidCORSRule is error, which is returned when CORS rule has no origins or methods
+	ErrInvalidCORSRule = cerr.New("CORS rule has no origins or methods")
+
+	// ErrInvalidLifecycleRule is error, which is returned when lifecycle rule has no id, no action or days are not positive
+	ErrInvalidLifecycleRule = cerr.New("lifecycle rule is invalid")
+)
+
+// EnsureBucket creates the bucket in AWSInfo.Region when it doesn't exist
+func (awsConn *AWSConnector) EnsureBucket(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+			Bucket: aws.String(awsConn.AWSInfo.Bucket),
+		})
+	})
+	if err == nil {
+		return nil
+	}
+	if s3Err := newS3Error("HeadBucket", awsConn.AWSInfo.Bucket, "", err); !s3Err.NotFound() {
+		return s3Err
+	}
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(awsConn.AWSInfo.Bucket)}
+	if awsConn.AWSInfo.Region != "" && awsConn.AWSInfo.Region != defaultRegion {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(awsConn.AWSInfo.Region),
+		}
+	}
+	err = awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.CreateBucketWithContext(ctx, input)
+	})
+	if err == nil {
+		return nil
+	}
+	// the bucket may be created by another instance of the service in the meantime
+	if s3Err := newS3Error("CreateBucket", awsConn.AWSInfo.Bucket, "", err); s3Err.Code != s3.ErrCodeBucketAlreadyOwnedByYou {
+		return s3Err
+	}
+	return nil
+}
+
+// EnableVersioning turns versioning of the bucket on
+func (awsConn *AWSConnector) EnableVersioning(ctx context.Context) error {
+	return awsConn.setVersioning(ctx, s3.BucketVersioningStatusEnabled)
+}
+
+// SuspendVersioning stops creating new versions, existing versions are kept
+func (awsConn *AWSConnector) SuspendVersioning(ctx context.Context) error {
+	return awsConn.setVersioning(ctx, s3.BucketVersioningStatusSuspended)
+}
+
+func (awsConn *AWSConnector) setVersioning(ctx context.Context, status string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.PutBucketVersioningWithContext(ctx, &s3.PutBucketVersioningInput{
+			Bucket:                  aws.String(awsConn.AWSInfo.Bucket),
+			VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(status)},
+		})
+	})
+	if err != nil {
+		return newS3Error("PutBucketVersioning", awsConn.AWSInfo.Bucket, "", err)
+	}
+	return nil
+}
+
+// CORSRule allows browsers to call the bucket from other origins
+type CORSRule struct {
+	ID string
+	// AllowedOrigins are origins like https://example.com, * is wildcard
+	AllowedOrigins []string
+	// AllowedMethods are GET, PUT, POST, DELETE and HEAD
+	AllowedMethods []string
+	// AllowedHeaders are request headers allowed in preflight, * is wildcard
+	AllowedHeaders []string
+	// ExposeHeaders are response headers readable by scripts, e.g. ETag
+	ExposeHeaders []string
+	// MaxAge is time of caching preflight response by browser
+	MaxAge time.Duration
+}
+
+// BrowserUploadCORSRule returns rule which allows browser uploads by presigned urls and post policies
+// and downloads from origins
+func BrowserUploadCORSRule(origins ...string) CORSRule {
+	return CORSRule{
+		ID:             "BrowserUpload",
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPost},
+		AllowedHeaders: []string{"*"},
+		ExposeHeaders:  []string{"ETag"},
+		MaxAge:         time.Hour,
+	}
+}
+
+func (r CORSRule) validate() error {
+	if len(r.AllowedOrigins) == 0 || len(r.AllowedMethods) == 0 {
+		return ErrInvalidCORSRule
+	}
+	return nil
+}
+
+func (r CORSRule) s3Rule() *s3.CORSRule {
+	rule := &s3.CORSRule{
+		AllowedOrigins: aws.StringSlice(r.AllowedOrigins),
+		AllowedMethods: aws.StringSlice(r.AllowedMethods),
+	}
+	if r.ID != "" {
+		rule.ID = aws.String(r.ID)
+	}
+	if len(r.AllowedHeaders) > 0 {
+		rule.AllowedHeaders = aws.StringSlice(r.AllowedHeaders)
+	}
+	if len(r.ExposeHeaders) > 0 {
+		rule.ExposeHeaders = aws.StringSlice(r.ExposeHeaders)
+	}
+	if r.MaxAge > 0 {
+		rule.MaxAgeSeconds = aws.Int64(int64(r.MaxAge / time.Second))
+	}
+	return rule
+}
+
+// SetCORSRules replaces CORS configuration of the bucket with rules, no rules removes the configuration
+func (awsConn *AWSConnector) SetCORSRules(ctx context.Context, rules ...CORSRule) error {
+	s3Rules := make([]*s3.CORSRule, 0, len(rules))
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		s3Rules = append(s3Rules, rule.s3Rule())
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	if len(s3Rules) == 0 {
+		err := awsConn.retry(ctx, func(ctx context.Context) error {
+			return awsConn.svc.DeleteBucketCorsWithContext(ctx, &s3.DeleteBucketCorsInput{
+				Bucket: aws.String(awsConn.AWSInfo.Bucket),
+			})
+		})
+		if err != nil {
+			return newS3Error("DeleteBucketCors", awsConn.AWSInfo.Bucket, "", err)
+		}
+		return nil
+	}
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.PutBucketCorsWithContext(ctx, &s3.PutBucketCorsInput{
+			Bucket:            aws.String(awsConn.AWSInfo.Bucket),
+			CORSConfiguration: &s3.CORSConfiguration{CORSRules: s3Rules},
+		})
+	})
+	if err != nil {
+		return newS3Error("PutBucketCors", awsConn.AWSInfo.Bucket, "", err)
+	}
+	return nil
+}
+
+// LifecycleTransition moves objects to cheaper storage class after days since creation
+type LifecycleTransition struct {
+	Days int
+	// StorageClass is e.g. s3.TransitionStorageClassStandardIa or s3.TransitionStorageClassGlacier
+	StorageClass string
+}
+
+// LifecycleRule is lifecycle rule of objects which keys start with Prefix, zero values disable actions
+type LifecycleRule struct {
+	ID     string
+	Prefix string
+	// ExpireAfterDays deletes objects after days since creation
+	ExpireAfterDays int
+	// AbortIncompleteUploadAfterDays aborts multipart uploads which aren't completed after days since start
+	AbortIncompleteUploadAfterDays int
+	// NoncurrentExpireAfterDays deletes versions after days since they become noncurrent
+	NoncurrentExpireAfterDays int
+	Transitions               []LifecycleTransition
+}
+
+// ExpireRule returns rule which deletes objects under prefix after days, e.g. for temporary uploads
+func ExpireRule(id, prefix string, days int) LifecycleRule {
+	return LifecycleRule{ID: id, Prefix: prefix, ExpireAfterDays: days}
+}
+
+// AbortIncompleteUploadsRule returns rule which aborts multipart uploads of the bucket not completed after days
+func AbortIncompleteUploadsRule(id string, days int) LifecycleRule {
+	return LifecycleRule{ID: id, AbortIncompleteUploadAfterDays: days}
+}
+
+// TransitionRule returns rule which moves objects under prefix to storage class after days
+func TransitionRule(id, prefix string, days int, storageClass string) LifecycleRule {
+	return LifecycleRule{ID: id, Prefix: prefix, Transitions: []LifecycleTransition{{Days: days, StorageClass: storageClass}}}
+}
+
+func (r LifecycleRule) validate() error {
+	if r.ID == "" || r.ExpireAfterDays < 0 || r.AbortIncompleteUploadAfterDays < 0 || r.NoncurrentExpireAfterDays < 0 {
+		return ErrInvalidLifecycleRule
+	}
+	if r.ExpireAfterDays == 0 && r.AbortIncompleteUploadAfterDays == 0 && r.NoncurrentExpireAfterDays == 0 && len(r.Transitions) == 0 {
+		return ErrInvalidLifecycleRule
+	}
+	for _, t := range r.Transitions {
+		if t.Days <= 0 || t.StorageClass == "" {
+			return ErrInvalidLifecycleRule
+		}
+	}
+	return nil
+}
+
+func (r LifecycleRule) s3Rule() *s3.LifecycleRule {
+	rule := &s3.LifecycleRule{
+		ID:     aws.String(r.ID),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(r.Prefix)},
+	}
+	if r.ExpireAfterDays > 0 {
+		rule.Expiration = &s3.LifecycleExpiration{Days: aws.Int64(int64(r.ExpireAfterDays))}
+	}
+	if r.AbortIncompleteUploadAfterDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int64(int64(r.AbortIncompleteUploadAfterDays)),
+		}
+	}
+	if r.NoncurrentExpireAfterDays > 0 {
+		rule.NoncurrentVersionExpiration = &s3.NoncurrentVersionExpiration{
+			NoncurrentDays: aws.Int64(int64(r.NoncurrentExpireAfterDays)),
+		}
+	}
+	for _, t := range r.Transitions {
+		rule.Transitions = append(rule.Transitions, &s3.Transition{
+			Days:         aws.Int64(int64(t.Days)),
+			StorageClass: aws.String(t.StorageClass),
+		})
+	}
+	return rule
+}
+
+// lifecycleRules returns lifecycle rules of the bucket, no configuration means no rules
+func (awsConn *AWSConnector) lifecycleRules(ctx context.Context) ([]*s3.LifecycleRule, error) {
+	var out *s3.GetBucketLifecycleConfigurationOutput
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = awsConn.svc.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{
+			Bucket: aws.String(awsConn.AWSInfo.Bucket),
+		})
+		return err
+	})
+	if err != nil {
+		s3Err := newS3Error("GetBucketLifecycleConfiguration", awsConn.AWSInfo.Bucket, "", err)
+		if s3Err.Code == "NoSuchLifecycleConfiguration" {
+			return nil, nil
+		}
+		return nil, s3Err
+	}
+	return out.Rules, nil
+}
+
+func (awsConn *AWSConnector) putLifecycleRules(ctx context.Context, rules []*s3.LifecycleRule) error {
+	if len(rules) == 0 {
+		err := awsConn.retry(ctx, func(ctx context.Context) error {
+			return awsConn.svc.DeleteBucketLifecycleWithContext(ctx, &s3.DeleteBucketLifecycleInput{
+				Bucket: aws.String(awsConn.AWSInfo.Bucket),
+			})
+		})
+		if err != nil {
+			return newS3Error("DeleteBucketLifecycle", awsConn.AWSInfo.Bucket, "", err)
+		}
+		return nil
+	}
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 aws.String(awsConn.AWSInfo.Bucket),
+			LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+		})
+	})
+	if err != nil {
+		return newS3Error("PutBucketLifecycleConfiguration", awsConn.AWSInfo.Bucket, "", err)
+	}
+	return nil
+}
+
+// AddLifecycleRules merges rules into lifecycle configuration of the bucket by id, other rules are kept.
+// Configuration is read and written by separate calls, so concurrent changes may be lost
+func (awsConn *AWSConnector) AddLifecycleRules(ctx context.Context, rules ...LifecycleRule) error {
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	existing, err := awsConn.lifecycleRules(ctx)
+	if err != nil {
+		return err
+	}
+	merged := append([]*s3.LifecycleRule(nil), existing...)
+	for _, rule := range rules {
+		replaced := false
+		for i := range merged {
+			if aws.StringValue(merged[i].ID) == rule.ID {
+				merged[i], replaced = rule.s3Rule(), true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, rule.s3Rule())
+		}
+	}
+	return awsConn.putLifecycleRules(ctx, merged)
+}
+
+// RemoveLifecycleRules removes rules with ids from lifecycle configuration of the bucket,
+// the configuration is deleted when no rules remain
+func (awsConn *AWSConnector) RemoveLifecycleRules(ctx context.Context, ids ...string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	existing, err := awsConn.lifecycleRules(ctx)
+	if err != nil || len(existing) == 0 {
+		return err
+	}
+	removed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+	rules := make([]*s3.LifecycleRule, 0, len(existing))
+	for _, rule := range existing {
+		if !removed[aws.StringValue(rule.ID)] {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == len(existing) {
+		return nil
+	}
+	return awsConn.putLifecycleRules(ctx, rules)
+}
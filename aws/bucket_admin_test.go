@@ -0,0 +1,276 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_EnsureBucket(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	notFound := awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), http.StatusNotFound, "id")
+
+	// arrange
+	cases := []struct {
+		desc    string
+		region  string
+		svc     *MockiS3Client
+		wantErr error
+	}{
+		{
+			desc:   "Should not create existing bucket",
+			region: "eu-west-1",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadBucketWithContext(gomock.Any(), &s3.HeadBucketInput{Bucket: aws.String("test bucket")}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc:   "Should create bucket in region",
+			region: "eu-west-1",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadBucketWithContext(gomock.Any(), gomock.Any()).Return(notFound)
+				m.EXPECT().CreateBucketWithContext(gomock.Any(), &s3.CreateBucketInput{
+					Bucket:                    aws.String("test bucket"),
+					CreateBucketConfiguration: &s3.CreateBucketConfiguration{LocationConstraint: aws.String("eu-west-1")},
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc:   "Should create bucket in us-east-1 without location constraint",
+			region: "us-east-1",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadBucketWithContext(gomock.Any(), gomock.Any()).Return(notFound)
+				m.EXPECT().CreateBucketWithContext(gomock.Any(), &s3.CreateBucketInput{Bucket: aws.String("test bucket")}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc:   "Should returns no error when bucket is created concurrently",
+			region: "eu-west-1",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadBucketWithContext(gomock.Any(), gomock.Any()).Return(notFound)
+				m.EXPECT().CreateBucketWithContext(gomock.Any(), gomock.Any()).Return(
+					awserr.NewRequestFailure(awserr.New(s3.ErrCodeBucketAlreadyOwnedByYou, "owned", nil), http.StatusConflict, "id"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc:   "Should returns error when HeadBucket failed",
+			region: "eu-west-1",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadBucketWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "HeadBucket",
+				Bucket:    "test bucket",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc:   "Should returns error when CreateBucket failed",
+			region: "eu-west-1",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadBucketWithContext(gomock.Any(), gomock.Any()).Return(notFound)
+				m.EXPECT().CreateBucketWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "CreateBucket",
+				Bucket:    "test bucket",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL", Region: c.region}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := awsConn.EnsureBucket(context.Background())
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_SetCORSRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		svc     *MockiS3Client
+		rules   []CORSRule
+		wantErr error
+	}{
+		{
+			desc: "Should put rules",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().PutBucketCorsWithContext(gomock.Any(), &s3.PutBucketCorsInput{
+					Bucket: aws.String("test bucket"),
+					CORSConfiguration: &s3.CORSConfiguration{CORSRules: []*s3.CORSRule{{
+						ID:             aws.String("BrowserUpload"),
+						AllowedOrigins: aws.StringSlice([]string{"https://example.com"}),
+						AllowedMethods: aws.StringSlice([]string{"GET", "HEAD", "PUT", "POST"}),
+						AllowedHeaders: aws.StringSlice([]string{"*"}),
+						ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
+						MaxAgeSeconds:  aws.Int64(3600),
+					}}},
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			rules:   []CORSRule{BrowserUploadCORSRule("https://example.com")},
+			wantErr: nil,
+		},
+		{
+			desc: "Should delete configuration when rules are empty",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().DeleteBucketCorsWithContext(gomock.Any(), &s3.DeleteBucketCorsInput{Bucket: aws.String("test bucket")}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc:    "Should returns ErrInvalidCORSRule",
+			svc:     NewMockiS3Client(ctrl),
+			rules:   []CORSRule{{AllowedOrigins: []string{"*"}}},
+			wantErr: ErrInvalidCORSRule,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := awsConn.SetCORSRules(context.Background(), c.rules...)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_AddLifecycleRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	other := &s3.LifecycleRule{
+		ID:         aws.String("other"),
+		Status:     aws.String(s3.ExpirationStatusDisabled),
+		Filter:     &s3.LifecycleRuleFilter{Tag: &s3.Tag{Key: aws.String("k"), Value: aws.String("v")}},
+		Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)},
+	}
+	oldTmp := ExpireRule("tmp", "tmp/", 7).s3Rule()
+	newTmp := ExpireRule("tmp", "tmp/", 1)
+
+	// arrange
+	cases := []struct {
+		desc    string
+		svc     *MockiS3Client
+		rules   []LifecycleRule
+		wantErr error
+	}{
+		{
+			desc: "Should replace rule by id and keep other rules",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketLifecycleConfigurationWithContext(gomock.Any(), gomock.Any()).Return(
+					&s3.GetBucketLifecycleConfigurationOutput{Rules: []*s3.LifecycleRule{other, oldTmp}}, nil)
+				m.EXPECT().PutBucketLifecycleConfigurationWithContext(gomock.Any(), &s3.PutBucketLifecycleConfigurationInput{
+					Bucket: aws.String("test bucket"),
+					LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{
+						other,
+						newTmp.s3Rule(),
+						AbortIncompleteUploadsRule("abort", 2).s3Rule(),
+					}},
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			rules:   []LifecycleRule{newTmp, AbortIncompleteUploadsRule("abort", 2)},
+			wantErr: nil,
+		},
+		{
+			desc: "Should create configuration when bucket has none",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketLifecycleConfigurationWithContext(gomock.Any(), gomock.Any()).Return(nil,
+					awserr.NewRequestFailure(awserr.New("NoSuchLifecycleConfiguration", "none", nil), http.StatusNotFound, "id"))
+				m.EXPECT().PutBucketLifecycleConfigurationWithContext(gomock.Any(), &s3.PutBucketLifecycleConfigurationInput{
+					Bucket: aws.String("test bucket"),
+					LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{
+						TransitionRule("archive", "logs/", 30, s3.TransitionStorageClassGlacier).s3Rule(),
+					}},
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			rules:   []LifecycleRule{TransitionRule("archive", "logs/", 30, s3.TransitionStorageClassGlacier)},
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns error when GetBucketLifecycleConfiguration failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketLifecycleConfigurationWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			rules: []LifecycleRule{newTmp},
+			wantErr: S3Error{
+				Operation: "GetBucketLifecycleConfiguration",
+				Bucket:    "test bucket",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{desc: "Should returns ErrInvalidLifecycleRule when id is empty", svc: NewMockiS3Client(ctrl), rules: []LifecycleRule{ExpireRule("", "tmp/", 1)}, wantErr: ErrInvalidLifecycleRule},
+		{desc: "Should returns ErrInvalidLifecycleRule when rule has no action", svc: NewMockiS3Client(ctrl), rules: []LifecycleRule{{ID: "id"}}, wantErr: ErrInvalidLifecycleRule},
+		{desc: "Should returns ErrInvalidLifecycleRule when days are negative", svc: NewMockiS3Client(ctrl), rules: []LifecycleRule{ExpireRule("id", "tmp/", -1)}, wantErr: ErrInvalidLifecycleRule},
+		{desc: "Should returns ErrInvalidLifecycleRule when storage class is empty", svc: NewMockiS3Client(ctrl), rules: []LifecycleRule{TransitionRule("id", "", 1, "")}, wantErr: ErrInvalidLifecycleRule},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := awsConn.AddLifecycleRules(context.Background(), c.rules...)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_BucketAdminNilContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().HeadBucketWithContext(gomock.Any(), gomock.Any()).Return(nil)
+	svc.EXPECT().PutBucketVersioningWithContext(gomock.Any(), gomock.Any()).Return(nil)
+	awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	// actual
+	ensureErr := awsConn.EnsureBucket(nil)
+	versioningErr := awsConn.EnableVersioning(nil)
+
+	// assert
+	assert.NoError(t, ensureErr)
+	assert.NoError(t, versioningErr)
+}
@@ -0,0 +1,547 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// PolicyVersion is version of policy language
+const PolicyVersion = "2012-10-17"
+
+// ReadOnlySid is sid of statement added by SetBucketReadOnlyPolicy
+const ReadOnlySid = "AddPerm"
+
+const (
+	// ErrInvalidPolicy is error, which is returned when statement has no effect, principal, action or resource,
+	// or statement added by sid has no sid
+	ErrInvalidPolicy = cerr.New("bucket policy statement is incomplete")
+
+	// ErrDuplicateSid is error, which is returned when statements of the policy have the same sid
+	ErrDuplicateSid = cerr.New("bucket policy has duplicate sid")
+)
+
+// Effect is effect of policy statement
+type Effect string
+
+const (
+	// EffectAllow allows actions of the statement
+	EffectAllow Effect = "Allow"
+	// EffectDeny denies actions of the statement, deny wins over allow
+	EffectDeny Effect = "Deny"
+)
+
+// StringList is list of policy values, json string is decoded as list of one value
+type StringList []string
+
+// UnmarshalJSON decodes string or array of strings
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = StringList{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Principal is who the statement applies to
+type Principal struct {
+	// Anyone is anonymous principal "*"
+	Anyone  bool       `json:"-"`
+	AWS     StringList `json:"AWS,omitempty"`
+	Service StringList `json:"Service,omitempty"`
+
+	// extra keeps principals which aren't supported, e.g. Federated or CanonicalUser, so they survive merge
+	extra map[string]json.RawMessage
+}
+
+// principalFields are json fields of Principal
+var principalFields = []string{"AWS", "Service"}
+
+// AnyPrincipal returns principal of everyone including anonymous users
+func AnyPrincipal() Principal {
+	return Principal{Anyone: true}
+}
+
+// AWSPrincipal returns principal of accounts, users or roles given by arn or account id
+func AWSPrincipal(arns ...string) Principal {
+	return Principal{AWS: arns}
+}
+
+// ServicePrincipal returns principal of aws services, e.g. cloudfront.amazonaws.com
+func ServicePrincipal(services ...string) Principal {
+	return Principal{Service: services}
+}
+
+func (p Principal) isEmpty() bool {
+	return !p.Anyone && len(p.AWS) == 0 && len(p.Service) == 0 && len(p.extra) == 0
+}
+
+// MarshalJSON encodes anonymous principal as "*" and other principals with ones which aren't supported by Principal
+func (p Principal) MarshalJSON() ([]byte, error) {
+	if p.Anyone {
+		return json.Marshal("*")
+	}
+	type principal Principal
+	data, err := json.Marshal(principal(p))
+	if err != nil || len(p.extra) == 0 {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage, len(p.extra)+len(principalFields))
+	for k, v := range p.extra {
+		fields[k] = v
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON decodes "*" or object of principals and keeps principals which aren't supported by Principal
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = Principal{Anyone: s == "*"}
+		return nil
+	}
+	type principal Principal
+	var decoded principal
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, name := range principalFields {
+		delete(fields, name)
+	}
+	*p = Principal(decoded)
+	if len(fields) > 0 {
+		p.extra = fields
+	}
+	return nil
+}
+
+// PolicyCondition maps condition operator to keys and their values,
+// e.g. {"IpAddress": {"aws:SourceIp": ["10.0.0.0/8"]}}. Values are kept as decoded by encoding/json,
+// so bool and number values like {"Bool": {"aws:SecureTransport": false}} survive merge
+type PolicyCondition map[string]map[string]interface{}
+
+// PolicyStatement is statement of bucket policy. Statements are built by NewStatement and With... methods,
+// which return modified copy of the statement
+type PolicyStatement struct {
+	Sid       string          `json:"Sid,omitempty"`
+	Effect    Effect          `json:"Effect"`
+	Principal Principal       `json:"Principal"`
+	Actions   StringList      `json:"Action,omitempty"`
+	Resources StringList      `json:"Resource,omitempty"`
+	Condition PolicyCondition `json:"Condition,omitempty"`
+
+	// extra keeps fields which aren't supported, e.g. NotAction, so they survive merge
+	extra map[string]json.RawMessage
+}
+
+// policyStatementFields are json fields of PolicyStatement
+var policyStatementFields = []string{"Sid", "Effect", "Principal", "Action", "Resource", "Condition"}
+
+// NewStatement returns statement with sid and effect
+func NewStatement(sid string, effect Effect) PolicyStatement {
+	return PolicyStatement{Sid: sid, Effect: effect}
+}
+
+// WithPrincipal sets principal of the statement
+func (st PolicyStatement) WithPrincipal(p Principal) PolicyStatement {
+	st.Principal = p
+	return st
+}
+
+// WithActions adds actions, e.g. s3:GetObject
+func (st PolicyStatement) WithActions(actions ...string) PolicyStatement {
+	st.Actions = append(append(StringList(nil), st.Actions...), actions...)
+	return st
+}
+
+// WithResources adds resources given by arn
+func (st PolicyStatement) WithResources(arns ...string) PolicyStatement {
+	st.Resources = append(append(StringList(nil), st.Resources...), arns...)
+	return st
+}
+
+// WithBucket adds the bucket itself as resource, it's required by bucket actions like s3:ListBucket
+func (st PolicyStatement) WithBucket(bucket string) PolicyStatement {
+	return st.WithResources(BucketARN(bucket))
+}
+
+// WithPrefixes adds objects of the bucket which keys start with prefixes as resources,
+// empty prefix means all objects
+func (st PolicyStatement) WithPrefixes(bucket string, prefixes ...string) PolicyStatement {
+	arns := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		arns = append(arns, ObjectsARN(bucket, prefix))
+	}
+	return st.WithResources(arns...)
+}
+
+// WithCondition adds values of condition key under operator, e.g. ("StringEquals", "s3:prefix", "public/")
+func (st PolicyStatement) WithCondition(operator, key string, values ...string) PolicyStatement {
+	condition := make(PolicyCondition, len(st.Condition)+1)
+	for op, keys := range st.Condition {
+		condition[op] = make(map[string]interface{}, len(keys))
+		for k, v := range keys {
+			condition[op][k] = v
+		}
+	}
+	if condition[operator] == nil {
+		condition[operator] = make(map[string]interface{})
+	}
+	list := conditionValues(condition[operator][key])
+	for _, v := range values {
+		list = append(list, v)
+	}
+	condition[operator][key] = list
+	st.Condition = condition
+	return st
+}
+
+// conditionValues returns copy of condition value as list, single value is list of one value
+func conditionValues(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return append([]interface{}(nil), value...)
+	default:
+		return []interface{}{value}
+	}
+}
+
+// WithSourceIP limits the statement to requests from ip ranges in CIDR notation
+func (st PolicyStatement) WithSourceIP(cidrs ...string) PolicyStatement {
+	return st.WithCondition("IpAddress", "aws:SourceIp", cidrs...)
+}
+
+// WithReferer limits the statement to requests with Referer header matching patterns, * is wildcard
+func (st PolicyStatement) WithReferer(patterns ...string) PolicyStatement {
+	return st.WithCondition("StringLike", "aws:Referer", patterns...)
+}
+
+// validate checks that the statement is complete, NotPrincipal, NotAction and NotResource
+// kept in extra fields replace Principal, Action and Resource
+func (st PolicyStatement) validate() error {
+	if (st.Effect != EffectAllow && st.Effect != EffectDeny) ||
+		(st.Principal.isEmpty() && st.extra["NotPrincipal"] == nil) ||
+		(len(st.Actions) == 0 && st.extra["NotAction"] == nil) ||
+		(len(st.Resources) == 0 && st.extra["NotResource"] == nil) {
+		return ErrInvalidPolicy
+	}
+	return nil
+}
+
+// MarshalJSON encodes the statement with fields which aren't supported by PolicyStatement,
+// empty principal is omitted, e.g. when the statement has NotPrincipal
+func (st PolicyStatement) MarshalJSON() ([]byte, error) {
+	type statement PolicyStatement
+	data, err := json.Marshal(statement(st))
+	if err != nil || (len(st.extra) == 0 && !st.Principal.isEmpty()) {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage, len(st.extra)+len(policyStatementFields))
+	for k, v := range st.extra {
+		fields[k] = v
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if st.Principal.isEmpty() {
+		delete(fields, "Principal")
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON decodes the statement and keeps fields which aren't supported by PolicyStatement
+func (st *PolicyStatement) UnmarshalJSON(data []byte) error {
+	type statement PolicyStatement
+	var decoded statement
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, name := range policyStatementFields {
+		delete(fields, name)
+	}
+	*st = PolicyStatement(decoded)
+	if len(fields) > 0 {
+		st.extra = fields
+	}
+	return nil
+}
+
+// BucketPolicy is resource policy of bucket
+type BucketPolicy struct {
+	Version    string            `json:"Version"`
+	ID         string            `json:"Id,omitempty"`
+	Statements []PolicyStatement `json:"Statement"`
+}
+
+// UnmarshalJSON decodes the policy, "Statement" may be one statement or array of statements
+func (p *BucketPolicy) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Version   string          `json:"Version"`
+		ID        string          `json:"Id"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*p = BucketPolicy{Version: fields.Version, ID: fields.ID}
+	if len(fields.Statement) == 0 {
+		return nil
+	}
+	if fields.Statement[0] != '{' {
+		return json.Unmarshal(fields.Statement, &p.Statements)
+	}
+	var st PolicyStatement
+	if err := json.Unmarshal(fields.Statement, &st); err != nil {
+		return err
+	}
+	p.Statements = []PolicyStatement{st}
+	return nil
+}
+
+// NewBucketPolicy returns policy of current version with statements
+func NewBucketPolicy(statements ...PolicyStatement) BucketPolicy {
+	return BucketPolicy{Version: PolicyVersion, Statements: statements}
+}
+
+// Statement returns statement by sid
+func (p BucketPolicy) Statement(sid string) (PolicyStatement, bool) {
+	for _, st := range p.Statements {
+		if st.Sid == sid {
+			return st, true
+		}
+	}
+	return PolicyStatement{}, false
+}
+
+// Merge returns copy of the policy where statements replace statements with the same sid,
+// statements with new sid or without sid are appended
+func (p BucketPolicy) Merge(statements ...PolicyStatement) BucketPolicy {
+	merged := append([]PolicyStatement(nil), p.Statements...)
+	for _, st := range statements {
+		replaced := false
+		for i := range merged {
+			if st.Sid != "" && merged[i].Sid == st.Sid {
+				merged[i], replaced = st, true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, st)
+		}
+	}
+	p.Statements = merged
+	return p
+}
+
+// Remove returns copy of the policy without statements with sids,
+// empty sid is invalid because statements without sid can't be told apart
+func (p BucketPolicy) Remove(sids ...string) (BucketPolicy, error) {
+	removed := make(map[string]bool, len(sids))
+	for _, sid := range sids {
+		if sid == "" {
+			return BucketPolicy{}, cerr.ErrFuncArg{}.Invalidate("sid")
+		}
+		removed[sid] = true
+	}
+	statements := make([]PolicyStatement, 0, len(p.Statements))
+	for _, st := range p.Statements {
+		if !removed[st.Sid] {
+			statements = append(statements, st)
+		}
+	}
+	p.Statements = statements
+	return p, nil
+}
+
+// Validate checks that every statement is complete and sids are unique, sid is optional
+func (p BucketPolicy) Validate() error {
+	sids := make(map[string]bool, len(p.Statements))
+	for _, st := range p.Statements {
+		if err := st.validate(); err != nil {
+			return err
+		}
+		if st.Sid == "" {
+			continue
+		}
+		if sids[st.Sid] {
+			return ErrDuplicateSid
+		}
+		sids[st.Sid] = true
+	}
+	return nil
+}
+
+// validateAdded checks statements merged into policy by sid, so every statement must have sid
+func validateAdded(statements []PolicyStatement) error {
+	for _, st := range statements {
+		if st.Sid == "" {
+			return ErrInvalidPolicy
+		}
+	}
+	return NewBucketPolicy(statements...).Validate()
+}
+
+// BucketARN returns arn of the bucket
+func BucketARN(bucket string) string {
+	return "arn:aws:s3:::" + bucket
+}
+
+// ObjectsARN returns arn of objects of the bucket which keys start with prefix
+func ObjectsARN(bucket, prefix string) string {
+	return BucketARN(bucket) + "/" + prefix + "*"
+}
+
+// ReadOnlyStatement returns statement which allows anyone to get objects of the bucket
+func ReadOnlyStatement(bucket string) PolicyStatement {
+	return NewStatement(ReadOnlySid, EffectAllow).
+		WithPrincipal(AnyPrincipal()).
+		WithActions("s3:GetObject").
+		WithPrefixes(bucket, "")
+}
+
+// GetBucketPolicy returns policy of the bucket.
+// Returns S3Error which is cerr.ErrNotFound when the bucket has no policy
+func (awsConn *AWSConnector) GetBucketPolicy(ctx context.Context) (BucketPolicy, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	var out *s3.GetBucketPolicyOutput
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = awsConn.svc.GetBucketPolicyWithContext(ctx, &s3.GetBucketPolicyInput{
+			Bucket: aws.String(awsConn.AWSInfo.Bucket),
+		})
+		return err
+	})
+	if err != nil {
+		return BucketPolicy{}, newS3Error("GetBucketPolicy", awsConn.AWSInfo.Bucket, "", err)
+	}
+
+	var policy BucketPolicy
+	if err := json.Unmarshal([]byte(aws.StringValue(out.Policy)), &policy); err != nil {
+		return BucketPolicy{}, err
+	}
+	return policy, nil
+}
+
+// PutBucketPolicy replaces policy of the bucket
+func (awsConn *AWSConnector) PutBucketPolicy(ctx context.Context, policy BucketPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	return awsConn.putBucketPolicy(ctx, policy)
+}
+
+// putBucketPolicy writes policy without validation, statements already stored
+// on the bucket are accepted by aws and are kept as is
+func (awsConn *AWSConnector) putBucketPolicy(ctx context.Context, policy BucketPolicy) error {
+	if policy.Version == "" {
+		policy.Version = PolicyVersion
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	err = awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.PutBucketPolicyWithContext(ctx, &s3.PutBucketPolicyInput{
+			Bucket: aws.String(awsConn.AWSInfo.Bucket),
+			Policy: aws.String(string(data)),
+		})
+	})
+	if err != nil {
+		return newS3Error("PutBucketPolicy", awsConn.AWSInfo.Bucket, "", err)
+	}
+	return nil
+}
+
+// DeleteBucketPolicy removes policy of the bucket
+func (awsConn *AWSConnector) DeleteBucketPolicy(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.DeleteBucketPolicyWithContext(ctx, &s3.DeleteBucketPolicyInput{
+			Bucket: aws.String(awsConn.AWSInfo.Bucket),
+		})
+	})
+	if err != nil {
+		return newS3Error("DeleteBucketPolicy", awsConn.AWSInfo.Bucket, "", err)
+	}
+	return nil
+}
+
+// AddPolicyStatements merges statements into policy of the bucket by sid, other statements are kept.
+// Only the added statements are validated, every of them must have sid.
+// Policy is read and written by separate calls, so concurrent changes of the policy may be lost
+func (awsConn *AWSConnector) AddPolicyStatements(ctx context.Context, statements ...PolicyStatement) error {
+	if err := validateAdded(statements); err != nil {
+		return err
+	}
+	policy, err := awsConn.GetBucketPolicy(ctx)
+	if errors.Is(err, cerr.ErrNotFound) {
+		policy, err = NewBucketPolicy(), nil
+	}
+	if err != nil {
+		return err
+	}
+	return awsConn.putBucketPolicy(ctx, policy.Merge(statements...))
+}
+
+// RemovePolicyStatements removes statements with sids from policy of the bucket,
+// the policy is deleted when no statements remain. Empty sid is invalid
+func (awsConn *AWSConnector) RemovePolicyStatements(ctx context.Context, sids ...string) error {
+	for _, sid := range sids {
+		if sid == "" {
+			return cerr.ErrFuncArg{}.Invalidate("sid")
+		}
+	}
+	policy, err := awsConn.GetBucketPolicy(ctx)
+	if errors.Is(err, cerr.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if policy, err = policy.Remove(sids...); err != nil {
+		return err
+	}
+	if len(policy.Statements) == 0 {
+		return awsConn.DeleteBucketPolicy(ctx)
+	}
+	return awsConn.putBucketPolicy(ctx, policy)
+}
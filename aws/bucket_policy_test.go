@@ -0,0 +1,372 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var errNoSuchBucketPolicy = awserr.NewRequestFailure(awserr.New("NoSuchBucketPolicy", "no policy", nil), http.StatusNotFound, "id")
+
+func TestPolicyStatement_MarshalJSON(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc      string
+		statement PolicyStatement
+		want      string
+	}{
+		{
+			desc:      "Should encode read only statement",
+			statement: ReadOnlyStatement("bucket"),
+			want:      `{"Sid":"AddPerm","Effect":"Allow","Principal":"*","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::bucket/*"]}`,
+		},
+		{
+			desc: "Should encode prefixes and conditions",
+			statement: NewStatement("Site", EffectAllow).
+				WithPrincipal(AnyPrincipal()).
+				WithActions("s3:GetObject").
+				WithPrefixes("bucket", "public/", "img/").
+				WithSourceIP("10.0.0.0/8").
+				WithReferer("https://example.com/*"),
+			want: `{"Sid":"Site","Effect":"Allow","Principal":"*","Action":["s3:GetObject"],` +
+				`"Resource":["arn:aws:s3:::bucket/public/*","arn:aws:s3:::bucket/img/*"],` +
+				`"Condition":{"IpAddress":{"aws:SourceIp":["10.0.0.0/8"]},"StringLike":{"aws:Referer":["https://example.com/*"]}}}`,
+		},
+		{
+			desc: "Should encode aws and service principals",
+			statement: NewStatement("List", EffectDeny).
+				WithPrincipal(Principal{AWS: StringList{"123456789012"}, Service: StringList{"cloudfront.amazonaws.com"}}).
+				WithActions("s3:ListBucket").
+				WithBucket("bucket"),
+			want: `{"Sid":"List","Effect":"Deny","Principal":{"AWS":["123456789012"],"Service":["cloudfront.amazonaws.com"]},` +
+				`"Action":["s3:ListBucket"],"Resource":["arn:aws:s3:::bucket"]}`,
+		},
+		{
+			desc:      "Should omit empty sid, principal, actions and resources",
+			statement: notStatementPolicy(t).Statements[0],
+			want: `{"Effect":"Deny","NotPrincipal":{"AWS":"arn:aws:iam::1:root"},"NotAction":"s3:GetObject",` +
+				`"NotResource":"arn:aws:s3:::b/public/*"}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			got, err := json.Marshal(c.statement)
+
+			// assert
+			assert.NoError(t, err)
+			assert.JSONEq(t, c.want, string(got))
+		})
+	}
+}
+
+func TestPolicyStatement_UnmarshalJSON(t *testing.T) {
+	// arrange
+	data := `{"Sid":"Other","Effect":"Deny","NotPrincipal":{"AWS":"arn:aws:iam::1:root"},"Principal":{"AWS":"1"},"Action":"s3:*","Resource":"arn:aws:s3:::b/*"}`
+
+	// actual
+	var got PolicyStatement
+	err := json.Unmarshal([]byte(data), &got)
+	encoded, encodeErr := json.Marshal(got)
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, encodeErr)
+	assert.Equal(t, "Other", got.Sid)
+	assert.Equal(t, AWSPrincipal("1"), got.Principal)
+	assert.Equal(t, StringList{"s3:*"}, got.Actions)
+	assert.Equal(t, StringList{"arn:aws:s3:::b/*"}, got.Resources)
+	assert.JSONEq(t, `{"Sid":"Other","Effect":"Deny","NotPrincipal":{"AWS":"arn:aws:iam::1:root"},"Principal":{"AWS":["1"]},`+
+		`"Action":["s3:*"],"Resource":["arn:aws:s3:::b/*"]}`, string(encoded))
+}
+
+func TestPolicyStatement_ConditionValues(t *testing.T) {
+	// arrange
+	data := `{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::b/*",` +
+		`"Condition":{"Bool":{"aws:SecureTransport":false},"NumericLessThan":{"s3:TlsVersion":1.2},"StringEquals":{"aws:SourceVpc":"vpc-1"}}}`
+
+	// actual
+	var got PolicyStatement
+	err := json.Unmarshal([]byte(data), &got)
+	encoded, encodeErr := json.Marshal(got)
+	extended, extendErr := json.Marshal(got.WithCondition("StringEquals", "aws:SourceVpc", "vpc-2"))
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, encodeErr)
+	require.NoError(t, extendErr)
+	assert.Equal(t, false, got.Condition["Bool"]["aws:SecureTransport"])
+	assert.JSONEq(t, `{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":["s3:*"],"Resource":["arn:aws:s3:::b/*"],`+
+		`"Condition":{"Bool":{"aws:SecureTransport":false},"NumericLessThan":{"s3:TlsVersion":1.2},"StringEquals":{"aws:SourceVpc":"vpc-1"}}}`, string(encoded))
+	assert.JSONEq(t, `{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":["s3:*"],"Resource":["arn:aws:s3:::b/*"],`+
+		`"Condition":{"Bool":{"aws:SecureTransport":false},"NumericLessThan":{"s3:TlsVersion":1.2},"StringEquals":{"aws:SourceVpc":["vpc-1","vpc-2"]}}}`, string(extended))
+}
+
+func TestPrincipal_UnsupportedPrincipals(t *testing.T) {
+	// arrange
+	data := `{"AWS":"arn:aws:iam::1:root","Federated":"cognito-identity.amazonaws.com","CanonicalUser":"79a59df900b949e55d96a1e698fbaced"}`
+
+	// actual
+	var got Principal
+	err := json.Unmarshal([]byte(data), &got)
+	encoded, encodeErr := json.Marshal(got)
+	var federated Principal
+	federatedErr := json.Unmarshal([]byte(`{"Federated":"accounts.google.com"}`), &federated)
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, encodeErr)
+	require.NoError(t, federatedErr)
+	assert.Equal(t, StringList{"arn:aws:iam::1:root"}, got.AWS)
+	assert.JSONEq(t, `{"AWS":["arn:aws:iam::1:root"],"Federated":"cognito-identity.amazonaws.com",`+
+		`"CanonicalUser":"79a59df900b949e55d96a1e698fbaced"}`, string(encoded))
+	assert.False(t, federated.isEmpty())
+}
+
+func TestBucketPolicy_UnmarshalJSON(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc string
+		data string
+		want []PolicyStatement
+	}{
+		{
+			desc: "Should decode array of statements",
+			data: `{"Version":"2012-10-17","Statement":[{"Sid":"A","Effect":"Allow"},{"Sid":"B","Effect":"Deny"}]}`,
+			want: []PolicyStatement{NewStatement("A", EffectAllow), NewStatement("B", EffectDeny)},
+		},
+		{
+			desc: "Should decode one statement",
+			data: `{"Version":"2012-10-17","Statement":{"Sid":"A","Effect":"Allow"}}`,
+			want: []PolicyStatement{NewStatement("A", EffectAllow)},
+		},
+		{
+			desc: "Should decode policy without statements",
+			data: `{"Version":"2012-10-17"}`,
+			want: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			var got BucketPolicy
+			err := json.Unmarshal([]byte(c.data), &got)
+
+			// assert
+			require.NoError(t, err)
+			assert.Equal(t, PolicyVersion, got.Version)
+			assert.Equal(t, c.want, got.Statements)
+		})
+	}
+}
+
+func TestBucketPolicy_Merge(t *testing.T) {
+	// arrange
+	a := ReadOnlyStatement("bucket")
+	b := NewStatement("B", EffectDeny).WithPrincipal(AnyPrincipal()).WithActions("s3:DeleteObject").WithPrefixes("bucket", "")
+	newA := a.WithSourceIP("10.0.0.0/8")
+	policy := NewBucketPolicy(a, b)
+
+	// actual
+	merged := policy.Merge(newA, NewStatement("C", EffectAllow))
+	removed, removeErr := merged.Remove(ReadOnlySid, "missing")
+	_, emptySidErr := merged.Remove("")
+
+	// assert
+	require.NoError(t, removeErr)
+	assert.Equal(t, cerr.NewErrFuncArgMock("sid", "Remove"), emptySidErr)
+	assert.Equal(t, NewBucketPolicy(a, b), policy)
+	assert.Equal(t, []PolicyStatement{newA, b, NewStatement("C", EffectAllow)}, merged.Statements)
+	assert.Equal(t, []PolicyStatement{b, NewStatement("C", EffectAllow)}, removed.Statements)
+	got, ok := merged.Statement(ReadOnlySid)
+	assert.True(t, ok)
+	assert.Equal(t, newA, got)
+}
+
+func TestBucketPolicy_Validate(t *testing.T) {
+	// arrange
+	valid := ReadOnlyStatement("bucket")
+	cases := []struct {
+		desc    string
+		policy  BucketPolicy
+		wantErr error
+	}{
+		{desc: "Should returns no error", policy: NewBucketPolicy(valid), wantErr: nil},
+		{desc: "Should returns no error when sid is empty", policy: NewBucketPolicy(NewStatement("", EffectAllow).WithPrincipal(AnyPrincipal()).WithActions("s3:GetObject").WithBucket("b"), NewStatement("", EffectDeny).WithPrincipal(AnyPrincipal()).WithActions("s3:DeleteObject").WithBucket("b")), wantErr: nil},
+		{desc: "Should returns no error when statement has NotPrincipal, NotAction and NotResource", policy: notStatementPolicy(t), wantErr: nil},
+		{desc: "Should returns ErrInvalidPolicy when effect is unknown", policy: NewBucketPolicy(NewStatement("S", "Maybe").WithPrincipal(AnyPrincipal()).WithActions("s3:GetObject").WithBucket("b")), wantErr: ErrInvalidPolicy},
+		{desc: "Should returns ErrInvalidPolicy when principal is empty", policy: NewBucketPolicy(NewStatement("S", EffectAllow).WithActions("s3:GetObject").WithBucket("b")), wantErr: ErrInvalidPolicy},
+		{desc: "Should returns ErrInvalidPolicy when actions are empty", policy: NewBucketPolicy(NewStatement("S", EffectAllow).WithPrincipal(AnyPrincipal()).WithBucket("b")), wantErr: ErrInvalidPolicy},
+		{desc: "Should returns ErrInvalidPolicy when resources are empty", policy: NewBucketPolicy(NewStatement("S", EffectAllow).WithPrincipal(AnyPrincipal()).WithActions("s3:GetObject")), wantErr: ErrInvalidPolicy},
+		{desc: "Should returns ErrDuplicateSid", policy: NewBucketPolicy(valid, valid), wantErr: ErrDuplicateSid},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			gotErr := c.policy.Validate()
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_RemovePolicyStatements(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	existing := `{"Version":"2012-10-17","Statement":[{"Sid":"AddPerm","Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::test bucket/*"}]}`
+	cases := []struct {
+		desc    string
+		svc     *MockiS3Client
+		sids    []string
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when sid is empty",
+			svc:     NewMockiS3Client(ctrl),
+			sids:    []string{ReadOnlySid, ""},
+			wantErr: cerr.NewErrFuncArgMock("sid", "RemovePolicyStatements"),
+		},
+		{
+			desc: "Should keep other statement of policy with one statement",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(&s3.GetBucketPolicyOutput{Policy: aws.String(
+					`{"Version":"2012-10-17","Statement":{"Sid":"Other","Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::test bucket/*"}}`)}, nil)
+				m.EXPECT().PutBucketPolicyWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.PutBucketPolicyInput) error {
+						assert.Contains(t, *input.Policy, `"Sid":"Other"`)
+						return nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			sids:    []string{ReadOnlySid},
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns no error when bucket has no policy",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil, errNoSuchBucketPolicy)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			sids:    []string{ReadOnlySid},
+			wantErr: nil,
+		},
+		{
+			desc: "Should delete policy when no statements remain",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(&s3.GetBucketPolicyOutput{Policy: aws.String(existing)}, nil)
+				m.EXPECT().DeleteBucketPolicyWithContext(gomock.Any(), &s3.DeleteBucketPolicyInput{Bucket: aws.String("test bucket")}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			sids:    []string{ReadOnlySid},
+			wantErr: nil,
+		},
+		{
+			desc: "Should keep policy when sid is missing",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(&s3.GetBucketPolicyOutput{Policy: aws.String(existing)}, nil)
+				m.EXPECT().PutBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			sids:    []string{"missing"},
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns error when DeleteBucketPolicy failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(&s3.GetBucketPolicyOutput{Policy: aws.String(existing)}, nil)
+				m.EXPECT().DeleteBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			sids: []string{ReadOnlySid},
+			wantErr: S3Error{
+				Operation: "DeleteBucketPolicy",
+				Bucket:    "test bucket",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := awsConn.RemovePolicyStatements(context.Background(), c.sids...)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func notStatementPolicy(t *testing.T) BucketPolicy {
+	var policy BucketPolicy
+	require.NoError(t, json.Unmarshal([]byte(`{"Version":"2012-10-17","Statement":[{"Effect":"Deny",`+
+		`"NotPrincipal":{"AWS":"arn:aws:iam::1:root"},"NotAction":"s3:GetObject","NotResource":"arn:aws:s3:::b/public/*"}]}`), &policy))
+	return policy
+}
+
+func TestClientStatusUpdater_AddPolicyStatementsInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl))
+	withoutSid := NewStatement("", EffectAllow).WithPrincipal(AnyPrincipal()).WithActions("s3:GetObject").WithBucket("b")
+
+	// actual
+	gotErr := awsConn.AddPolicyStatements(context.Background(), withoutSid)
+
+	// assert
+	assert.Equal(t, ErrInvalidPolicy, gotErr)
+}
+
+func TestClientStatusUpdater_PutBucketPolicyInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl))
+
+	// actual
+	gotErr := awsConn.PutBucketPolicy(context.Background(), NewBucketPolicy(NewStatement("S", EffectAllow)))
+
+	// assert
+	assert.Equal(t, ErrInvalidPolicy, gotErr)
+}
+
+func TestClientStatusUpdater_BucketPolicyNilContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().GetBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil, errNoSuchBucketPolicy)
+	svc.EXPECT().PutBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil)
+	svc.EXPECT().DeleteBucketPolicyWithContext(gomock.Any(), gomock.Any()).Return(nil)
+	awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	// actual
+	addErr := awsConn.AddPolicyStatements(nil, ReadOnlyStatement("test bucket"))
+	deleteErr := awsConn.DeleteBucketPolicy(nil)
+
+	// assert
+	assert.NoError(t, addErr)
+	assert.NoError(t, deleteErr)
+}
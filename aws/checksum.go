@@ -0,0 +1,115 @@
+package aws
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ChecksumMetadataKey is user metadata key which keeps hex SHA-256 of the object body.
+// It's set by PutFile, aws returns it with x-amz-meta- prefix
+const ChecksumMetadataKey = "Sha256"
+
+// ErrChecksumMismatch is error, which is returned when body stored in aws or read from it doesn't match its checksum
+const ErrChecksumMismatch = cerr.New("checksum mismatch")
+
+// Checksum is digest of the object body
+type Checksum struct {
+	// MD5 is hex MD5 of the body, aws verifies it by Content-MD5 on upload
+	MD5 string
+	// SHA256 is hex SHA-256 of the body
+	SHA256 string
+}
+
+func newChecksum(data []byte) Checksum {
+	md5Sum := md5.Sum(data)
+	sha256Sum := sha256.Sum256(data)
+	return Checksum{
+		MD5:    hex.EncodeToString(md5Sum[:]),
+		SHA256: hex.EncodeToString(sha256Sum[:]),
+	}
+}
+
+// contentMD5 returns value of Content-MD5 header of the body
+func (c Checksum) contentMD5() *string {
+	sum, _ := hex.DecodeString(c.MD5)
+	return aws.String(base64.StdEncoding.EncodeToString(sum))
+}
+
+// contentMD5Of returns value of Content-MD5 header of data
+func contentMD5Of(data []byte) (*string, []byte) {
+	sum := md5.Sum(data)
+	return aws.String(base64.StdEncoding.EncodeToString(sum[:])), sum[:]
+}
+
+// compositeETag returns ETag which aws gives to multipart object:
+// MD5 of concatenated binary MD5s of parts and count of parts
+func compositeETag(partMD5s [][]byte) string {
+	h := md5.New()
+	for _, sum := range partMD5s {
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(partMD5s))
+}
+
+// etagIsMD5 reports whether ETag of the object written with enc is MD5 of its body.
+// ETag of objects encrypted by SSE-KMS or SSE-C is not derived from the body
+func etagIsMD5(enc EncryptionMode) bool {
+	return enc != SSEKMS && enc != SSEC
+}
+
+// verifyingBody computes digest of the read body and returns ErrChecksumMismatch
+// instead of io.EOF when the digest doesn't match the expected one
+type verifyingBody struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+// newVerifyingBody wraps body of the whole object by checksum verification.
+// SHA-256 from metadata is preferred, MD5 is taken from ETag when it's plain MD5 of the body.
+// Body is returned as is when the object has no checksum to compare
+func newVerifyingBody(body io.ReadCloser, metadata map[string]*string, etag string, enc EncryptionMode) io.ReadCloser {
+	if sum := strings.ToLower(aws.StringValue(metadata[ChecksumMetadataKey])); isHexDigest(sum, sha256.Size) {
+		return &verifyingBody{ReadCloser: body, hash: sha256.New(), expected: sum}
+	}
+	// multipart ETag has -<parts count> suffix, so it's not a hex digest
+	if sum := strings.Trim(etag, `"`); etagIsMD5(enc) && isHexDigest(sum, md5.Size) {
+		return &verifyingBody{ReadCloser: body, hash: md5.New(), expected: sum}
+	}
+	return body
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(b.hash.Sum(nil)) != b.expected {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+func isHexDigest(s string, size int) bool {
+	if len(s) != size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// withChecksumMetadata returns copy of metadata with SHA-256 of the body
+func withChecksumMetadata(metadata map[string]*string, checksum Checksum) map[string]*string {
+	result := make(map[string]*string, len(metadata)+1)
+	for k, v := range metadata {
+		result[k] = v
+	}
+	result[ChecksumMetadataKey] = aws.String(checksum.SHA256)
+	return result
+}
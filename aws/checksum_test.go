@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_GetFileVerifiesChecksum(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// md5 and sha256 of "body"
+	const (
+		bodyMD5    = "841a2d689ad86bd1611447453c22c6fc"
+		bodySHA256 = "230d8358dc8e8890b4c58deeb62912ee2f20357ae92a5cc861b98e68fe31acb5"
+	)
+
+	// arrange
+	cases := []struct {
+		desc      string
+		byteRange bool
+		out       *s3.GetObjectOutput
+		wantErr   error
+	}{
+		{
+			desc: "Should returns no error when sha256 from metadata matches body",
+			out: &s3.GetObjectOutput{
+				ETag:     aws.String(`"etag"`),
+				Metadata: map[string]*string{ChecksumMetadataKey: aws.String(bodySHA256)},
+			},
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns ErrChecksumMismatch when sha256 from metadata doesn't match body",
+			out: &s3.GetObjectOutput{
+				ETag:     aws.String(`"` + bodyMD5 + `"`),
+				Metadata: map[string]*string{ChecksumMetadataKey: aws.String(strings.Repeat("0", 64))},
+			},
+			wantErr: ErrChecksumMismatch,
+		},
+		{
+			desc:    "Should returns no error when md5 ETag matches body",
+			out:     &s3.GetObjectOutput{ETag: aws.String(`"` + bodyMD5 + `"`)},
+			wantErr: nil,
+		},
+		{
+			desc:    "Should returns ErrChecksumMismatch when md5 ETag doesn't match body",
+			out:     &s3.GetObjectOutput{ETag: aws.String(`"` + strings.Repeat("0", 32) + `"`)},
+			wantErr: ErrChecksumMismatch,
+		},
+		{
+			desc: "Should returns no error when object is encrypted by SSE-KMS, its ETag isn't md5",
+			out: &s3.GetObjectOutput{
+				ETag:                 aws.String(`"` + strings.Repeat("0", 32) + `"`),
+				ServerSideEncryption: aws.String(s3.ServerSideEncryptionAwsKms),
+			},
+			wantErr: nil,
+		},
+		{
+			desc:    "Should returns no error when ETag is composite ETag of multipart object",
+			out:     &s3.GetObjectOutput{ETag: aws.String(`"` + strings.Repeat("0", 32) + `-2"`)},
+			wantErr: nil,
+		},
+		{
+			desc:      "Should returns no error when range is read",
+			byteRange: true,
+			out:       &s3.GetObjectOutput{ETag: aws.String(`"` + strings.Repeat("0", 32) + `"`)},
+			wantErr:   nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			svc := NewMockiS3Client(ctrl)
+			c.out.Body = ioutil.NopCloser(strings.NewReader("body"))
+			svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).Return(c.out, nil)
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+
+			// actual
+			get := aws.GetFile
+			if c.byteRange {
+				get = func(ctx context.Context, key string, opts ...GetOption) (io.ReadCloser, ObjectInfo, error) {
+					return aws.GetFileRange(ctx, key, 0, 4, opts...)
+				}
+			}
+			body, _, err := get(context.Background(), "time_111_img.png")
+			assert.NoError(t, err)
+			got, gotErr := ioutil.ReadAll(body)
+			body.Close()
+
+			// assert
+			assert.Equal(t, "body", string(got))
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFileChecksum(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3.PutObjectInput) error {
+			// md5 and sha256 of "hello"
+			assert.Equal(t, "XUFAKrxLKna5cZ2REBfFkg==", aws.StringValue(input.ContentMD5))
+			assert.Equal(t, map[string]*string{
+				"owner":             aws.String("42"),
+				ChecksumMetadataKey: aws.String("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"),
+			}, input.Metadata)
+			return nil
+		})
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "https://cdn.example.com",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+	fileObj := "name:{hello.txt},dataUrl:{data:text/plain,hello}"
+	metadata := map[string]string{"owner": "42"}
+
+	// actual
+	got, gotErr := awsConn.PutFileURL(context.Background(), &fileObj, WithMetadata(metadata))
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Equal(t, Checksum{
+		MD5:    "5d41402abc4b2a76b9719d911017c592",
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}, got.Checksum)
+	assert.Equal(t, map[string]string{"owner": "42"}, metadata)
+}
@@ -0,0 +1,225 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"strings"
+)
+
+const (
+	// DefaultDedupPrefix is used when DedupConfig.Prefix is not set
+	DefaultDedupPrefix = "cas/"
+	// DefaultDedupRefsPrefix is used when DedupConfig.RefsPrefix is not set
+	DefaultDedupRefsPrefix = "cas-refs/"
+)
+
+const (
+	// ErrInvalidDedupConfig is error, which is returned when prefixes of content and references overlap
+	ErrInvalidDedupConfig = cerr.New("prefixes of content and references must not overlap")
+
+	// ErrReservedKey is error, which is returned when key set by caller is under prefix of deduplicated content
+	// or references, such objects are managed only by deduplication
+	ErrReservedKey = cerr.New("key is reserved for deduplicated content")
+)
+
+// DedupConfig configures content-addressed deduplication of files uploaded by PutFile.
+// Content is stored once by key <Prefix><sha256 of content><extension>, every upload of the same content
+// adds an empty reference object <RefsPrefix><content key>/<time>_<uuid>, Delete removes one reference
+// and removes the content only when no references are left.
+//
+// Content is shared by every uploader, so it's stored without file name in Content-Disposition,
+// without metadata set by WithMetadata and without tags. Only the first upload of the content sets its headers
+//
+// S3 has no transactions, so Delete racing with upload of the same content may remove the content
+// the upload has just referenced. Uploads add the reference before checking the content to make the window small
+type DedupConfig struct {
+	// Prefix is prefix of content keys
+	Prefix string
+	// RefsPrefix is prefix of reference objects
+	RefsPrefix string
+}
+
+func (cfg DedupConfig) validate() error {
+	cfg = cfg.withDefaults()
+	if strings.HasPrefix(cfg.Prefix, cfg.RefsPrefix) || strings.HasPrefix(cfg.RefsPrefix, cfg.Prefix) {
+		return ErrInvalidDedupConfig
+	}
+	return nil
+}
+
+func (cfg DedupConfig) withDefaults() DedupConfig {
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultDedupPrefix
+	}
+	if cfg.RefsPrefix == "" {
+		cfg.RefsPrefix = DefaultDedupRefsPrefix
+	}
+	return cfg
+}
+
+// WithDedup turns on deduplication of files uploaded by PutFile and PutFileURL.
+// Key strategy is not used for them, uploads with WithObjectKey and streamed uploads are not deduplicated.
+// Keys under prefixes of DedupConfig can't be set by WithObjectKey or be destination of Copy and Move,
+// and deduplicated content can't be moved, ErrReservedKey is returned for them
+func WithDedup(cfg DedupConfig) Option {
+	return func(awsConn *AWSConnector) {
+		cfg = cfg.withDefaults()
+		awsConn.dedup = &cfg
+	}
+}
+
+// isDedupKey reports whether key is content key of deduplicated file
+func (awsConn *AWSConnector) isDedupKey(key string) bool {
+	return awsConn.dedup != nil && strings.HasPrefix(key, awsConn.dedup.Prefix)
+}
+
+// isReservedKey reports whether key is under prefix of deduplicated content or references
+func (awsConn *AWSConnector) isReservedKey(key string) bool {
+	return awsConn.isDedupKey(key) || awsConn.dedup != nil && strings.HasPrefix(key, awsConn.dedup.RefsPrefix)
+}
+
+// dedupKey returns content key of the file
+func (awsConn *AWSConnector) dedupKey(ctx context.Context, fileName string, data []byte) (string, error) {
+	key, err := ContentHashKeys.Key(ctx, KeySource{FileName: SanitizeFileName(fileName), Content: data})
+	if err != nil {
+		return "", err
+	}
+	return awsConn.dedup.Prefix + key, nil
+}
+
+// refsPrefix returns prefix of references of the content key
+func (awsConn *AWSConnector) refsPrefix(key string) string {
+	return awsConn.dedup.RefsPrefix + key + "/"
+}
+
+// addReference stores new reference of the content key and returns key of the reference
+func (awsConn *AWSConnector) addReference(ctx context.Context, key string, enc Encryption) (string, error) {
+	refKey := awsConn.refsPrefix(key) + awsConn.generator.GenerateTime() + "_" + awsConn.generator.GenerateUUID()
+	input := &s3.PutObjectInput{
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &refKey,
+	}
+	enc.headers().applyToPutObject(input)
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		input.Body = bytes.NewReader(nil)
+		return awsConn.svc.PutObjectWithContext(ctx, input)
+	})
+	if err != nil {
+		return "", newS3Error("PutObject", awsConn.AWSInfo.Bucket, refKey, err)
+	}
+	return refKey, nil
+}
+
+// hasContent reports whether the content key is stored with the same checksum
+func (awsConn *AWSConnector) hasContent(ctx context.Context, key string, checksum Checksum, enc Encryption) (bool, error) {
+	var opts []GetOption
+	if enc.Mode == SSEC {
+		opts = append(opts, WithCustomerKey(enc.CustomerKey))
+	}
+	info, err := awsConn.Stat(ctx, key, opts...)
+	if errors.Is(err, cerr.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// object without checksum or with another one is overwritten
+	return strings.EqualFold(info.Metadata[ChecksumMetadataKey], checksum.SHA256), nil
+}
+
+// firstReference returns key of any reference of the content key, empty key means no references
+func (awsConn *AWSConnector) firstReference(ctx context.Context, key string) (string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  &awsConn.AWSInfo.Bucket,
+		Prefix:  aws.String(awsConn.refsPrefix(key)),
+		MaxKeys: aws.Int64(1),
+	}
+	var out *s3.ListObjectsV2Output
+	err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		out, err = awsConn.svc.ListObjectsV2WithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return "", newS3Error("ListObjectsV2", awsConn.AWSInfo.Bucket, key, err)
+	}
+	if len(out.Contents) == 0 {
+		return "", nil
+	}
+	return aws.StringValue(out.Contents[0].Key), nil
+}
+
+// release removes one reference of the content key and the content when it's not referenced anymore.
+// Content without references is removed at once
+func (awsConn *AWSConnector) release(ctx context.Context, key string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	refKey, err := awsConn.firstReference(ctx, key)
+	if err != nil {
+		return err
+	}
+	if refKey != "" {
+		if err := awsConn.deleteObject(ctx, refKey); err != nil {
+			return err
+		}
+		if refKey, err = awsConn.firstReference(ctx, key); err != nil || refKey != "" {
+			return err
+		}
+	}
+	return awsConn.deleteObject(ctx, key)
+}
+
+// References returns count of references of the deduplicated content key
+func (awsConn *AWSConnector) References(ctx context.Context, key string) (int, error) {
+	if !awsConn.isDedupKey(key) {
+		return 0, cerr.ErrFuncArg{}.Invalidate("key")
+	}
+	count := 0
+	it := awsConn.List(ctx, awsConn.refsPrefix(key))
+	for it.Next() {
+		count++
+	}
+	return count, it.Err()
+}
+
+// putDeduplicated references content of the file and uploads it only when it's not stored yet
+func (awsConn *AWSConnector) putDeduplicated(ctx context.Context, fileName string, data []byte, contentType string, checksum Checksum, putOpts putOptions, enc Encryption) (PutResult, error) {
+	key, err := awsConn.dedupKey(ctx, fileName, data)
+	if err != nil {
+		return PutResult{}, err
+	}
+	// reference is added first, so concurrent Delete doesn't see the content as unreferenced
+	refKey, err := awsConn.addReference(ctx, key, enc)
+	if err != nil {
+		return PutResult{}, err
+	}
+
+	stored, err := awsConn.hasContent(ctx, key, checksum, enc)
+	if err != nil {
+		awsConn.removeDetached(refKey)
+		return PutResult{}, err
+	}
+	if stored {
+		// nothing is sent, so the whole body is reported at once
+		if putOpts.progress != nil {
+			size := int64(len(data))
+			putOpts.progress(Progress{Key: key, Transferred: size, Total: size})
+		}
+		return PutResult{Key: key, Checksum: checksum}, nil
+	}
+	// headers of one uploader must not be seen by others, see DedupConfig
+	putOpts.metadata, putOpts.tags = nil, nil
+	result, err := awsConn.putObject(ctx, key, "", data, contentType, checksum, putOpts, enc)
+	if err != nil {
+		awsConn.removeDetached(refKey)
+		return PutResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,356 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sha256 of "hello"
+const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestClientStatusUpdater_WithDedup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		cfg     DedupConfig
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when references are stored under content prefix",
+			cfg:     DedupConfig{Prefix: "files/", RefsPrefix: "files/refs/"},
+			wantErr: ErrInvalidDedupConfig,
+		},
+		{
+			desc:    "Should returns error when content is stored under references prefix",
+			cfg:     DedupConfig{Prefix: "cas-refs/content/"},
+			wantErr: ErrInvalidDedupConfig,
+		},
+		{
+			desc:    "Should returns no error",
+			cfg:     DedupConfig{},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			_, gotErr := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl), WithDedup(c.cfg))
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFileDedup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const (
+		contentKey = "cas/" + helloSHA256 + ".txt"
+		refKey     = "cas-refs/" + contentKey + "/time_111"
+	)
+	addsReference := func(m *MockiS3Client) *gomock.Call {
+		return m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.PutObjectInput) error {
+				assert.Equal(t, refKey, *input.Key)
+				return nil
+			})
+	}
+
+	// arrange
+	cases := []struct {
+		desc    string
+		svc     *MockiS3Client
+		wantKey string
+		wantErr error
+	}{
+		{
+			desc: "Should returns key of stored content and skips upload",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				addsReference(m)
+				m.EXPECT().HeadObjectWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.HeadObjectOutput{Metadata: map[string]*string{ChecksumMetadataKey: aws.String(helloSHA256)}}, nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantKey: contentKey,
+			wantErr: nil,
+		},
+		{
+			desc: "Should uploads content without file name, metadata and tags of uploader when it's not stored",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				gomock.InOrder(
+					addsReference(m),
+					m.EXPECT().HeadObjectWithContext(gomock.Any(), gomock.Any()).
+						Return(nil, awserr.New("NotFound", "not found", nil)),
+					m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(_ context.Context, input *s3.PutObjectInput) error {
+							assert.Equal(t, contentKey, *input.Key)
+							assert.Equal(t, DispositionAttachment, aws.StringValue(input.ContentDisposition))
+							assert.Equal(t, map[string]*string{ChecksumMetadataKey: aws.String(helloSHA256)}, input.Metadata)
+							assert.Nil(t, input.Tagging)
+							return nil
+						}),
+				)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantKey: contentKey,
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns error and removes reference when upload failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				gomock.InOrder(
+					addsReference(m),
+					m.EXPECT().HeadObjectWithContext(gomock.Any(), gomock.Any()).
+						Return(nil, awserr.New("NotFound", "not found", nil)),
+					m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error")),
+					m.EXPECT().DeleteObjectWithContext(gomock.Any(), &s3.DeleteObjectInput{
+						Bucket: aws.String("test bucket"),
+						Key:    aws.String(refKey),
+					}).Return(nil),
+				)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantKey: "",
+			wantErr: S3Error{
+				Operation: "PutObject",
+				Bucket:    "test bucket",
+				Key:       contentKey,
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			generator := NewMockiGenerate(ctrl)
+			generator.EXPECT().GenerateTime().Return("time")
+			generator.EXPECT().GenerateUUID().Return("111")
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, generator, WithDedup(DedupConfig{}),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+			fileObj := "name:{hello.txt},dataUrl:{data:text/plain,hello}"
+
+			// actual
+			got, gotErr := aws.PutFile(context.Background(), &fileObj, WithContentDisposition(DispositionAttachment),
+				WithMetadata(map[string]string{"owner": "alice"}), WithTags(map[string]string{"owner": "alice"}))
+
+			// assert
+			assert.Equal(t, c.wantKey, got)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_ReservedDedupKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const contentKey = "cas/" + helloSHA256 + ".txt"
+
+	// arrange
+	cases := []struct {
+		desc    string
+		svc     *MockiS3Client
+		fn      func(awsConn *AWSConnector) error
+		wantErr error
+	}{
+		{
+			desc: "Should returns error when object key is under content prefix",
+			svc:  NewMockiS3Client(ctrl),
+			fn: func(awsConn *AWSConnector) error {
+				_, err := awsConn.PutReader(context.Background(), "a.txt", "text/plain", strings.NewReader("hello"), 5, WithObjectKey(contentKey))
+				return err
+			},
+			wantErr: ErrReservedKey,
+		},
+		{
+			desc: "Should returns error when object key is under references prefix",
+			svc:  NewMockiS3Client(ctrl),
+			fn: func(awsConn *AWSConnector) error {
+				_, err := awsConn.PutMultipart(context.Background(), "a.txt", "text/plain", strings.NewReader("hello"), WithObjectKey("cas-refs/"+contentKey+"/time_111"))
+				return err
+			},
+			wantErr: ErrReservedKey,
+		},
+		{
+			desc: "Should returns error when copy destination is under content prefix",
+			svc:  NewMockiS3Client(ctrl),
+			fn: func(awsConn *AWSConnector) error {
+				return awsConn.Copy(context.Background(), ObjectRef{Key: "dir/a.txt"}, ObjectRef{Key: contentKey})
+			},
+			wantErr: ErrReservedKey,
+		},
+		{
+			desc: "Should returns error when deduplicated content is moved",
+			svc:  NewMockiS3Client(ctrl),
+			fn: func(awsConn *AWSConnector) error {
+				return awsConn.Move(context.Background(), ObjectRef{Key: contentKey}, ObjectRef{Key: "dir/a.txt"})
+			},
+			wantErr: ErrReservedKey,
+		},
+		{
+			desc: "Should copies deduplicated content to other key",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CopyObjectWithContext(gomock.Any(), gomock.Any()).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			fn: func(awsConn *AWSConnector) error {
+				return awsConn.Copy(context.Background(), ObjectRef{Key: contentKey}, ObjectRef{Key: "dir/a.txt"})
+			},
+			wantErr: nil,
+		},
+		{
+			desc: "Should copies to key under content prefix of other bucket",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CopyObjectWithContext(gomock.Any(), gomock.Any()).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			fn: func(awsConn *AWSConnector) error {
+				return awsConn.Copy(context.Background(), ObjectRef{Key: "dir/a.txt"}, ObjectRef{Bucket: "archive", Key: contentKey})
+			},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			awsConn, err := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl), WithDedup(DedupConfig{}), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			gotErr := c.fn(awsConn)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_DeleteDedup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const (
+		contentKey = "cas/" + helloSHA256 + ".txt"
+		refKey     = "cas-refs/" + contentKey + "/time_111"
+	)
+	listsReference := func(m *MockiS3Client, refKeys ...string) *gomock.Call {
+		out := &s3.ListObjectsV2Output{}
+		for _, key := range refKeys {
+			out.Contents = append(out.Contents, &s3.Object{Key: aws.String(key)})
+		}
+		return m.EXPECT().ListObjectsV2WithContext(gomock.Any(), &s3.ListObjectsV2Input{
+			Bucket:  aws.String("test bucket"),
+			Prefix:  aws.String("cas-refs/" + contentKey + "/"),
+			MaxKeys: aws.Int64(1),
+		}).Return(out, nil)
+	}
+	deletes := func(m *MockiS3Client, key string) *gomock.Call {
+		return m.EXPECT().DeleteObjectWithContext(gomock.Any(), &s3.DeleteObjectInput{
+			Bucket: aws.String("test bucket"),
+			Key:    aws.String(key),
+		}).Return(nil)
+	}
+
+	// arrange
+	cases := []struct {
+		desc    string
+		svc     *MockiS3Client
+		wantErr error
+	}{
+		{
+			desc: "Should removes reference and keeps content which is referenced",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				gomock.InOrder(
+					listsReference(m, refKey),
+					deletes(m, refKey),
+					listsReference(m, refKey+"2"),
+				)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc: "Should removes content with its last reference",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				gomock.InOrder(
+					listsReference(m, refKey),
+					deletes(m, refKey),
+					listsReference(m),
+					deletes(m, contentKey),
+				)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc: "Should removes content which has no references",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				gomock.InOrder(
+					listsReference(m),
+					deletes(m, contentKey),
+				)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns error when ListObjectsV2WithContext failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().ListObjectsV2WithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "ListObjectsV2",
+				Bucket:    "test bucket",
+				Key:       contentKey,
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl), WithDedup(DedupConfig{}),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+			// actual
+			gotErr := aws.Delete(context.Background(), contentKey)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/vincent-petithory/dataurl"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultContentType is used when object has no content type
+const defaultContentType = "application/octet-stream"
+
+// ObjectInfo describes object stored in aws
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// GetFile returns body and info of the object stored by key.
+// Returns S3Error which is cerr.ErrNotFound when there is no such key.
+// Body is verified by SHA-256 from metadata or by ETag when it's MD5 of the body,
+// reading of the body ends with ErrChecksumMismatch instead of io.EOF when it doesn't match.
+// Caller must close the body
+func (awsConn *AWSConnector) GetFile(ctx context.Context, key string, opts ...GetOption) (io.ReadCloser, ObjectInfo, error) {
+	return awsConn.getObject(ctx, key, "", opts)
+}
+
+// GetFileRange returns length bytes of the object starting from offset.
+// Not positive length means reading till the end of the object
+func (awsConn *AWSConnector) GetFileRange(ctx context.Context, key string, offset, length int64, opts ...GetOption) (io.ReadCloser, ObjectInfo, error) {
+	if offset < 0 {
+		return nil, ObjectInfo{}, cerr.ErrFuncArg{}.Invalidate("offset")
+	}
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		byteRange += fmt.Sprint(offset + length - 1)
+	}
+	return awsConn.getObject(ctx, key, byteRange, opts)
+}
+
+// GetFileAsDataURL returns the object in the same format as PutFile receives:
+// name:{filename},dataUrl:{file body in dataURL format}
+func (awsConn *AWSConnector) GetFileAsDataURL(ctx context.Context, key string, opts ...GetOption) (string, error) {
+	body, info, err := awsConn.GetFile(ctx, key, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(info.ContentType)
+	if err != nil || strings.Count(mediaType, "/") != 1 {
+		mediaType, params = defaultContentType, nil
+	}
+	// params are emitted in order of names, so the same object always gives the same data url
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	paramPairs := make([]string, 0, len(params)*2)
+	for _, name := range names {
+		paramPairs = append(paramPairs, name, params[name])
+	}
+
+	return fmt.Sprintf("name:{%s},dataUrl:{%s}", fileNameFromKey(key), dataurl.New(data, mediaType, paramPairs...).String()), nil
+}
+
+func (awsConn *AWSConnector) getObject(ctx context.Context, key, byteRange string, opts []GetOption) (io.ReadCloser, ObjectInfo, error) {
+	if key == "" {
+		return nil, ObjectInfo{}, cerr.ErrFuncArg{}.Invalidate("key")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+
+	input := &s3.GetObjectInput{
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &key,
+	}
+	if byteRange != "" {
+		input.Range = aws.String(byteRange)
+	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey = customerKeyHeaders(awsConn.readCustomerKey(opts))
+	input.VersionId = readVersionID(opts)
+
+	var out *s3.GetObjectOutput
+	err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		out, err = awsConn.svc.GetObjectWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		cancelFn()
+		return nil, ObjectInfo{}, newS3Error("GetObject", awsConn.AWSInfo.Bucket, key, err)
+	}
+
+	if out.Body == nil {
+		out.Body = ioutil.NopCloser(strings.NewReader(""))
+	}
+	// checksums describe the whole object, so ranges can't be verified
+	if byteRange == "" {
+		enc := EncryptionMode(aws.StringValue(out.ServerSideEncryption))
+		if out.SSECustomerAlgorithm != nil {
+			enc = SSEC
+		}
+		out.Body = newVerifyingBody(out.Body, out.Metadata, aws.StringValue(out.ETag), enc)
+	}
+	getOpts := newGetOptions(opts)
+	if t := awsConn.newTransfer(key, aws.Int64Value(out.ContentLength), getOpts.progress, getOpts.limiter); t != nil {
+		out.Body = t.body(ctx, out.Body, 0, aws.Int64Value(out.ContentLength))
+	}
+
+	info := ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		ContentType:  aws.StringValue(out.ContentType),
+		ETag:         aws.StringValue(out.ETag),
+		LastModified: aws.TimeValue(out.LastModified),
+		Metadata:     aws.StringValueMap(out.Metadata),
+	}
+
+	return &cancelOnCloseBody{ReadCloser: out.Body, cancelFn: cancelFn}, info, nil
+}
+
+// Stat returns info of the object stored by key without its body.
+// Returns S3Error which is cerr.ErrNotFound when there is no such key
+func (awsConn *AWSConnector) Stat(ctx context.Context, key string, opts ...GetOption) (ObjectInfo, error) {
+	if key == "" {
+		return ObjectInfo{}, cerr.ErrFuncArg{}.Invalidate("key")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	input := &s3.HeadObjectInput{
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &key,
+	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey = customerKeyHeaders(awsConn.readCustomerKey(opts))
+	input.VersionId = readVersionID(opts)
+
+	var out *s3.HeadObjectOutput
+	err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		out, err = awsConn.svc.HeadObjectWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return ObjectInfo{}, newS3Error("HeadObject", awsConn.AWSInfo.Bucket, key, err)
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		ContentType:  aws.StringValue(out.ContentType),
+		ETag:         aws.StringValue(out.ETag),
+		LastModified: aws.TimeValue(out.LastModified),
+		Metadata:     aws.StringValueMap(out.Metadata),
+	}, nil
+}
+
+// cancelOnCloseBody keeps context of the request alive until the body is closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancelFn func()
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancelFn()
+	return b.ReadCloser.Close()
+}
+
+// fileNameFromKey cuts directories and <time>_<uuid>_ prefix added by DefaultKeys
+func fileNameFromKey(key string) string {
+	name := path.Base(key)
+	parts := strings.SplitN(name, "_", 3)
+	if len(parts) != 3 {
+		return name
+	}
+	return parts[2]
+}
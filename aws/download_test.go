@@ -0,0 +1,284 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_GetFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc     string
+		key      string
+		svc      *MockiS3Client
+		wantBody string
+		wantInfo ObjectInfo
+		wantErr  error
+	}{
+		{
+			desc:    "Should returns error when key is empty",
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: cerr.NewErrFuncArgMock("key", "getObject"),
+		},
+		{
+			desc: "Should returns cerr.ErrNotFound when there is no such key",
+			key:  "time_111_img.png",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+					Return(nil, awserr.New(s3.ErrCodeNoSuchKey, "no such key", nil))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: cerr.ErrNotFound,
+		},
+		{
+			desc: "Should returns error when GetObjectWithContext failed",
+			key:  "time_111_img.png",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "GetObject",
+				Bucket:    "test bucket",
+				Key:       "time_111_img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc: "Should returns no error, body and info",
+			key:  "time_111_img.png",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.GetObjectOutput{
+						Body:          ioutil.NopCloser(strings.NewReader("body")),
+						ContentLength: aws.Int64(4),
+						ContentType:   aws.String("image/png"),
+						ETag:          aws.String("etag"),
+					}, nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantBody: "body",
+			wantInfo: ObjectInfo{
+				Key:         "time_111_img.png",
+				Size:        4,
+				ContentType: "image/png",
+				ETag:        "etag",
+				Metadata:    map[string]string{},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			body, info, gotErr := aws.GetFile(context.Background(), c.key)
+
+			// assert
+			if c.wantErr != nil {
+				if !errors.Is(gotErr, c.wantErr) {
+					assert.Equal(t, c.wantErr, gotErr)
+				}
+				return
+			}
+			assert.NoError(t, gotErr)
+			got, _ := ioutil.ReadAll(body)
+			assert.NoError(t, body.Close())
+			assert.Equal(t, c.wantBody, string(got))
+			assert.Equal(t, c.wantInfo, info)
+		})
+	}
+}
+
+func TestClientStatusUpdater_GetFileRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc      string
+		offset    int64
+		length    int64
+		wantRange string
+	}{
+		{
+			desc:      "Should request range till the end when length is not set",
+			offset:    10,
+			wantRange: "bytes=10-",
+		},
+		{
+			desc:      "Should request range of length bytes",
+			offset:    10,
+			length:    5,
+			wantRange: "bytes=10-14",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			svc := NewMockiS3Client(ctrl)
+			svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+					assert.Equal(t, c.wantRange, *input.Range)
+					return &s3.GetObjectOutput{}, nil
+				})
+
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+			body, _, gotErr := aws.GetFileRange(context.Background(), "key", c.offset, c.length)
+
+			// assert
+			assert.NoError(t, gotErr)
+			assert.NoError(t, body.Close())
+		})
+	}
+}
+
+func TestClientStatusUpdater_GetFileAsDataURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stubFileObj := "name:{img.png},dataUrl:{data:image/png;base64,iVBggg==}"
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+		Return(&s3.GetObjectOutput{
+			Body:        ioutil.NopCloser(strings.NewReader("\x89P\x60\x82")),
+			ContentType: aws.String("image/png"),
+		}, nil)
+
+	// actual
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+	got, gotErr := awsConn.GetFileAsDataURL(context.Background(), "time_111_img.png")
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Equal(t, stubFileObj, got)
+}
+
+func TestClientStatusUpdater_GetFileAsDataURLParams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body:        ioutil.NopCloser(strings.NewReader("a,b")),
+				ContentType: aws.String("text/csv; header=present; charset=utf-8; delimiter=comma"),
+			}, nil
+		}).Times(10)
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	for i := 0; i < 10; i++ {
+		// actual
+		got, gotErr := awsConn.GetFileAsDataURL(context.Background(), "time_111_table.csv")
+
+		// assert
+		assert.NoError(t, gotErr)
+		assert.Equal(t, "name:{table.csv},dataUrl:{data:text/csv;charset=utf-8;delimiter=comma;header=present;base64,YSxi}", got)
+	}
+}
+
+func TestClientStatusUpdater_Stat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc     string
+		key      string
+		svc      *MockiS3Client
+		wantInfo ObjectInfo
+		wantErr  error
+	}{
+		{
+			desc:    "Should returns error when key is empty",
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: cerr.NewErrFuncArgMock("key", "Stat"),
+		},
+		{
+			desc: "Should returns cerr.ErrNotFound when there is no such key",
+			key:  "key",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadObjectWithContext(gomock.Any(), gomock.Any()).
+					Return(nil, awserr.New("NotFound", "not found", nil))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: cerr.ErrNotFound,
+		},
+		{
+			desc: "Should returns no error and info",
+			key:  "key",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().HeadObjectWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.HeadObjectOutput{
+						ContentLength: aws.Int64(4),
+						ContentType:   aws.String("image/png"),
+						ETag:          aws.String(`"etag"`),
+						Metadata:      map[string]*string{"A": aws.String("b")},
+					}, nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantInfo: ObjectInfo{
+				Key:         "key",
+				Size:        4,
+				ContentType: "image/png",
+				ETag:        `"etag"`,
+				Metadata:    map[string]string{"A": "b"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			awsConn, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			got, gotErr := awsConn.Stat(context.Background(), c.key)
+
+			// assert
+			assert.Equal(t, c.wantInfo, got)
+			if c.wantErr == cerr.ErrNotFound {
+				assert.True(t, errors.Is(gotErr, cerr.ErrNotFound))
+				return
+			}
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
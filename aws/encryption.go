@@ -0,0 +1,217 @@
+package aws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// EncryptionMode is kind of server-side encryption
+type EncryptionMode string
+
+const (
+	// SSENone means that encryption depends on bucket defaults
+	SSENone EncryptionMode = ""
+	// SSES3 encrypts objects with keys managed by S3
+	SSES3 EncryptionMode = s3.ServerSideEncryptionAes256
+	// SSEKMS encrypts objects with KMS key
+	SSEKMS EncryptionMode = s3.ServerSideEncryptionAwsKms
+	// SSEC encrypts objects with key provided by customer, the same key is required for reads
+	SSEC EncryptionMode = "SSE-C"
+
+	// customerKeyLen is length of AES-256 key required by SSE-C
+	customerKeyLen = 32
+	// customerKeyAlgorithm is the only algorithm supported by SSE-C
+	customerKeyAlgorithm = "AES256"
+)
+
+const (
+	// ErrInvalidEncryption is error, which is returned when encryption settings are inconsistent
+	ErrInvalidEncryption = cerr.New("invalid encryption settings")
+
+	// ErrEncryptionRequired is error, which is returned when encryption is required, but write has no encryption
+	ErrEncryptionRequired = cerr.New("encryption is required for every write")
+)
+
+// Encryption describes server-side encryption of objects
+type Encryption struct {
+	Mode EncryptionMode
+	// KMSKeyID is id or arn of KMS key for SSEKMS, empty means aws managed key
+	KMSKeyID string
+	// KMSContext is encryption context for SSEKMS
+	KMSContext map[string]string
+	// CustomerKey is 32 bytes AES-256 key for SSEC
+	CustomerKey []byte
+}
+
+func (enc Encryption) validate() error {
+	switch enc.Mode {
+	case SSENone, SSES3:
+		if enc.KMSKeyID != "" || len(enc.KMSContext) != 0 || enc.CustomerKey != nil {
+			return ErrInvalidEncryption
+		}
+	case SSEKMS:
+		if enc.CustomerKey != nil {
+			return ErrInvalidEncryption
+		}
+	case SSEC:
+		if len(enc.CustomerKey) != customerKeyLen || enc.KMSKeyID != "" || len(enc.KMSContext) != 0 {
+			return ErrInvalidEncryption
+		}
+	default:
+		return ErrInvalidEncryption
+	}
+	return nil
+}
+
+// WithEncryption sets encryption of every write of AWSConnector.
+// Reads use CustomerKey when encryption is SSEC
+func WithEncryption(enc Encryption) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.encryption = enc
+	}
+}
+
+// WithRequiredEncryption makes every write without encryption fail with ErrEncryptionRequired
+func WithRequiredEncryption() Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.encryptionRequired = true
+	}
+}
+
+// WithPutEncryption overrides encryption of AWSConnector for one write
+func WithPutEncryption(enc Encryption) PutOption {
+	return func(opts *putOptions) {
+		opts.encryption = &enc
+	}
+}
+
+// GetOption configures one read
+type GetOption func(opts *getOptions)
+
+type getOptions struct {
+	customerKey []byte
+	versionID   string
+	progress    ProgressFunc
+	limiter     *BandwidthLimiter
+}
+
+func newGetOptions(opts []GetOption) getOptions {
+	var o getOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCustomerKey sets SSE-C key for one read, it overrides key of AWSConnector
+func WithCustomerKey(key []byte) GetOption {
+	return func(opts *getOptions) {
+		opts.customerKey = key
+	}
+}
+
+// writeEncryption returns encryption of one write
+func (awsConn *AWSConnector) writeEncryption(o putOptions) (Encryption, error) {
+	enc := awsConn.encryption
+	if o.encryption != nil {
+		enc = *o.encryption
+		if err := enc.validate(); err != nil {
+			return Encryption{}, err
+		}
+	}
+	if awsConn.encryptionRequired && enc.Mode == SSENone {
+		return Encryption{}, ErrEncryptionRequired
+	}
+	return enc, nil
+}
+
+// readCustomerKey returns SSE-C key of one read, nil means no key
+func (awsConn *AWSConnector) readCustomerKey(opts []GetOption) []byte {
+	o := newGetOptions(opts)
+	if o.customerKey != nil {
+		return o.customerKey
+	}
+	if awsConn.encryption.Mode == SSEC {
+		return awsConn.encryption.CustomerKey
+	}
+	return nil
+}
+
+// sseHeaders are encryption headers shared by all kinds of write inputs
+type sseHeaders struct {
+	serverSideEncryption *string
+	kmsKeyID             *string
+	kmsContext           *string
+	customerAlgorithm    *string
+	customerKey          *string
+}
+
+func (enc Encryption) headers() sseHeaders {
+	var h sseHeaders
+	switch enc.Mode {
+	case SSES3:
+		h.serverSideEncryption = aws.String(string(SSES3))
+	case SSEKMS:
+		h.serverSideEncryption = aws.String(string(SSEKMS))
+		if enc.KMSKeyID != "" {
+			h.kmsKeyID = aws.String(enc.KMSKeyID)
+		}
+		if len(enc.KMSContext) != 0 {
+			kmsContext, _ := json.Marshal(enc.KMSContext)
+			h.kmsContext = aws.String(base64.StdEncoding.EncodeToString(kmsContext))
+		}
+	case SSEC:
+		h.customerAlgorithm, h.customerKey = customerKeyHeaders(enc.CustomerKey)
+	}
+	return h
+}
+
+// customerKeyHeaders returns algorithm and key headers of SSE-C, md5 of the key is added by aws sdk
+func customerKeyHeaders(key []byte) (*string, *string) {
+	if key == nil {
+		return nil, nil
+	}
+	return aws.String(customerKeyAlgorithm), aws.String(string(key))
+}
+
+func (h sseHeaders) applyToPutObject(input *s3.PutObjectInput) {
+	input.ServerSideEncryption = h.serverSideEncryption
+	input.SSEKMSKeyId = h.kmsKeyID
+	input.SSEKMSEncryptionContext = h.kmsContext
+	input.SSECustomerAlgorithm = h.customerAlgorithm
+	input.SSECustomerKey = h.customerKey
+}
+
+func (h sseHeaders) applyToUpload(input *s3manager.UploadInput) {
+	input.ServerSideEncryption = h.serverSideEncryption
+	input.SSEKMSKeyId = h.kmsKeyID
+	input.SSEKMSEncryptionContext = h.kmsContext
+	input.SSECustomerAlgorithm = h.customerAlgorithm
+	input.SSECustomerKey = h.customerKey
+}
+
+func (h sseHeaders) applyToCreateMultipartUpload(input *s3.CreateMultipartUploadInput) {
+	input.ServerSideEncryption = h.serverSideEncryption
+	input.SSEKMSKeyId = h.kmsKeyID
+	input.SSEKMSEncryptionContext = h.kmsContext
+	input.SSECustomerAlgorithm = h.customerAlgorithm
+	input.SSECustomerKey = h.customerKey
+}
+
+// applyToUploadPart sets SSE-C headers, which are required for every part
+func (h sseHeaders) applyToUploadPart(input *s3.UploadPartInput) {
+	input.SSECustomerAlgorithm = h.customerAlgorithm
+	input.SSECustomerKey = h.customerKey
+}
+
+func (h sseHeaders) applyToCopyObject(input *s3.CopyObjectInput) {
+	input.ServerSideEncryption = h.serverSideEncryption
+	input.SSEKMSKeyId = h.kmsKeyID
+	input.SSEKMSEncryptionContext = h.kmsContext
+	input.SSECustomerAlgorithm = h.customerAlgorithm
+	input.SSECustomerKey = h.customerKey
+}
@@ -0,0 +1,196 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_WithEncryption(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		enc     Encryption
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when mode is unknown",
+			enc:     Encryption{Mode: "unknown"},
+			wantErr: ErrInvalidEncryption,
+		},
+		{
+			desc:    "Should returns error when SSE-C key has wrong length",
+			enc:     Encryption{Mode: SSEC, CustomerKey: []byte("short")},
+			wantErr: ErrInvalidEncryption,
+		},
+		{
+			desc:    "Should returns error when KMS key is set for SSE-S3",
+			enc:     Encryption{Mode: SSES3, KMSKeyID: "key"},
+			wantErr: ErrInvalidEncryption,
+		},
+		{
+			desc:    "Should returns no error for SSE-KMS",
+			enc:     Encryption{Mode: SSEKMS, KMSKeyID: "key", KMSContext: map[string]string{"tenant": "1"}},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			_, gotErr := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl), WithEncryption(c.enc))
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFileEncryption(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	customerKey := bytes.Repeat([]byte("k"), customerKeyLen)
+	fileObj := "name:{img.png},dataUrl:{data:image/png;base64,iVBggg==}"
+
+	// arrange
+	cases := []struct {
+		desc      string
+		connOpts  []Option
+		putOpts   []PutOption
+		svc       func(m *MockiS3Client) *MockiS3Client
+		generator func(m *MockiGenerate) *MockiGenerate
+		wantErr   error
+	}{
+		{
+			desc:      "Should returns error when encryption is required but not set",
+			connOpts:  []Option{WithRequiredEncryption()},
+			svc:       func(m *MockiS3Client) *MockiS3Client { return m },
+			generator: func(m *MockiGenerate) *MockiGenerate { return m },
+			wantErr:   ErrEncryptionRequired,
+		},
+		{
+			desc:     "Should set SSE-KMS headers of connector",
+			connOpts: []Option{WithRequiredEncryption(), WithEncryption(Encryption{Mode: SSEKMS, KMSKeyID: "key", KMSContext: map[string]string{"a": "b"}})},
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.PutObjectInput) error {
+						assert.Equal(t, aws.String("aws:kms"), input.ServerSideEncryption)
+						assert.Equal(t, aws.String("key"), input.SSEKMSKeyId)
+						assert.Equal(t, aws.String("eyJhIjoiYiJ9"), input.SSEKMSEncryptionContext)
+						assert.Nil(t, input.SSECustomerKey)
+						return nil
+					})
+				return m
+			},
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			},
+		},
+		{
+			desc:     "Should override encryption of connector with SSE-C of the call",
+			connOpts: []Option{WithEncryption(Encryption{Mode: SSES3})},
+			putOpts:  []PutOption{WithPutEncryption(Encryption{Mode: SSEC, CustomerKey: customerKey})},
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.PutObjectInput) error {
+						assert.Nil(t, input.ServerSideEncryption)
+						assert.Equal(t, aws.String("AES256"), input.SSECustomerAlgorithm)
+						assert.Equal(t, aws.String(string(customerKey)), input.SSECustomerKey)
+						return nil
+					})
+				return m
+			},
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			},
+		},
+		{
+			desc:      "Should returns error when encryption of the call is invalid",
+			putOpts:   []PutOption{WithPutEncryption(Encryption{Mode: SSEC})},
+			svc:       func(m *MockiS3Client) *MockiS3Client { return m },
+			generator: func(m *MockiGenerate) *MockiGenerate { return m },
+			wantErr:   ErrInvalidEncryption,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc(NewMockiS3Client(ctrl)), c.generator(NewMockiGenerate(ctrl)), c.connOpts...)
+			_, gotErr := aws.PutFile(context.Background(), &fileObj, c.putOpts...)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_GetFileCustomerKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	connKey := bytes.Repeat([]byte("c"), customerKeyLen)
+	callKey := bytes.Repeat([]byte("o"), customerKeyLen)
+
+	// arrange
+	cases := []struct {
+		desc    string
+		opts    []GetOption
+		wantKey *string
+	}{
+		{
+			desc:    "Should use SSE-C key of connector",
+			wantKey: aws.String(string(connKey)),
+		},
+		{
+			desc:    "Should use SSE-C key of the call",
+			opts:    []GetOption{WithCustomerKey(callKey)},
+			wantKey: aws.String(string(callKey)),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			svc := NewMockiS3Client(ctrl)
+			svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+					assert.Equal(t, aws.String("AES256"), input.SSECustomerAlgorithm)
+					assert.Equal(t, c.wantKey, input.SSECustomerKey)
+					return &s3.GetObjectOutput{}, nil
+				})
+
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl), WithEncryption(Encryption{Mode: SSEC, CustomerKey: connKey}))
+			body, _, gotErr := aws.GetFile(context.Background(), "key", c.opts...)
+
+			// assert
+			assert.NoError(t, gotErr)
+			assert.NoError(t, body.Close())
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package aws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/Stanly1995/golibs/params_validator"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// envelopeAlgorithm names format of encrypted objects: AES-256-GCM over chunks of plaintext
+	envelopeAlgorithm = "AES256-GCM-CHUNKED-v1"
+	// envelopeChunkSize is size of plaintext chunk sealed by one GCM call
+	envelopeChunkSize = 64 * 1024
+	dataKeyLen        = 32
+
+	metaEnvelopeAlgorithm = "cse-algorithm"
+	metaEnvelopeKey       = "cse-key"
+	metaEnvelopeKeyID     = "cse-key-id"
+	metaEnvelopeNonce     = "cse-nonce"
+	metaEnvelopeChunkSize = "cse-chunk-size"
+)
+
+const (
+	// ErrNotEnvelopeEncrypted is error, which is returned when object has no client-side encryption metadata
+	ErrNotEnvelopeEncrypted = cerr.New("object is not encrypted on client side")
+
+	// ErrDecryptionFailed is error, which is returned when object is corrupted, truncated or key is wrong
+	ErrDecryptionFailed = cerr.New("failed to decrypt object")
+
+	// ErrUnknownKeyID is error, which is returned when keyring has no key with such id
+	ErrUnknownKeyID = cerr.New("unknown key id")
+)
+
+// KeyWrapper encrypts data keys with key-encryption-key, e.g. KMS or local keyring
+type KeyWrapper interface {
+	// WrapKey encrypts dataKey and returns it with id of key-encryption-key
+	WrapKey(ctx context.Context, dataKey []byte) (wrapped []byte, keyID string, err error)
+	// UnwrapKey decrypts data key wrapped by key-encryption-key with keyID
+	UnwrapKey(ctx context.Context, wrapped []byte, keyID string) ([]byte, error)
+}
+
+// LocalKeyring is KeyWrapper which keeps AES-256 keys in memory.
+// New data keys are wrapped with the current key, the others are used for unwrapping only
+type LocalKeyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+// NewLocalKeyring creates keyring from 32 bytes keys by their ids,
+// currentID is id of key which wraps new data keys
+func NewLocalKeyring(currentID string, keys map[string][]byte) (*LocalKeyring, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, cerr.ErrFuncArg{}.Invalidate("currentID")
+	}
+	kr := &LocalKeyring{currentID: currentID, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, cerr.ErrFuncArg{}.Invalidate("keys")
+		}
+		kr.keys[id] = aead
+	}
+	return kr, nil
+}
+
+// WrapKey encrypts dataKey with the current key, nonce is prepended to result
+func (kr *LocalKeyring) WrapKey(_ context.Context, dataKey []byte) ([]byte, string, error) {
+	aead := kr.keys[kr.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(kr.currentID)), kr.currentID, nil
+}
+
+// UnwrapKey decrypts data key wrapped by WrapKey
+func (kr *LocalKeyring) UnwrapKey(_ context.Context, wrapped []byte, keyID string) ([]byte, error) {
+	aead, ok := kr.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return dataKey, nil
+}
+
+// EnvelopeConnector encrypts objects before they are sent to aws and decrypts them after reading.
+// Every object is encrypted with its own data key, wrapped data key is stored in object metadata.
+// Bodies are encrypted and decrypted by chunks, so they are never buffered as a whole
+type EnvelopeConnector struct {
+	conn    *AWSConnector
+	wrapper KeyWrapper
+}
+
+// NewEnvelopeConnector is constructor, receives connector which stores objects and wrapper of data keys
+func NewEnvelopeConnector(conn *AWSConnector, wrapper KeyWrapper) *EnvelopeConnector {
+	params_validator.ValidateParamsWithPanic(conn, wrapper)
+	return &EnvelopeConnector{
+		conn:    conn,
+		wrapper: wrapper,
+	}
+}
+
+// PutFile encrypts file in the format of AWSConnector.PutFile and puts it to aws
+func (ec *EnvelopeConnector) PutFile(ctx context.Context, fileObj *string, opts ...PutOption) (string, error) {
+	file, err := NewFile(fileObj)
+	if err != nil {
+		return "", err
+	}
+	data, contentType, err := ec.conn.decodeFile(file)
+	if err != nil {
+		return "", err
+	}
+	return ec.PutReader(ctx, file.fileName, contentType, bytes.NewReader(data), int64(len(data)), opts...)
+}
+
+// PutReader encrypts r and streams it to aws like AWSConnector.PutReader.
+// Upload policy is checked against plaintext
+func (ec *EnvelopeConnector) PutReader(ctx context.Context, name, contentType string, r io.Reader, size int64, opts ...PutOption) (string, error) {
+	if name == "" {
+		return "", cerr.ErrFuncArg{}.Invalidate("name")
+	}
+	if r == nil {
+		return "", cerr.ErrFuncArg{}.Invalidate("r")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	putOpts := newPutOptions(opts)
+	enc, err := ec.conn.writeEncryption(putOpts)
+	if err != nil {
+		return "", err
+	}
+	sized := &sizedReader{r: r, left: size}
+	if size >= 0 {
+		if err := ec.conn.policy.checkSize(size); err != nil {
+			return "", err
+		}
+		r = sized
+	}
+	contentType, plaintext, err := ec.conn.policy.checkStream(name, contentType, r)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, dataKeyLen)
+	nonce := make([]byte, 12)
+	if _, err = rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	wrapped, keyID, err := ec.wrapper.WrapKey(ctx, dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	WithMetadata(map[string]string{
+		metaEnvelopeAlgorithm: envelopeAlgorithm,
+		metaEnvelopeKey:       base64.StdEncoding.EncodeToString(wrapped),
+		metaEnvelopeKeyID:     keyID,
+		metaEnvelopeNonce:     base64.StdEncoding.EncodeToString(nonce),
+		metaEnvelopeChunkSize: strconv.Itoa(envelopeChunkSize),
+	})(&putOpts)
+
+	body := &encryptingReader{
+		src:   bufio.NewReaderSize(plaintext, envelopeChunkSize),
+		aead:  aead,
+		nonce: nonce,
+		plain: make([]byte, envelopeChunkSize),
+	}
+	uniqueFileName, err := ec.conn.uploadStream(ctx, name, contentType, body, -1, putOpts, enc)
+	if plaintext.err != nil {
+		return "", plaintext.err
+	}
+	if sized.err != nil {
+		return "", sized.err
+	}
+	return uniqueFileName, err
+}
+
+// GetFile reads object put by EnvelopeConnector and returns decrypted body.
+// Size in info is size of plaintext. Returns ErrNotEnvelopeEncrypted for objects without encryption metadata
+func (ec *EnvelopeConnector) GetFile(ctx context.Context, key string, opts ...GetOption) (io.ReadCloser, ObjectInfo, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	body, info, err := ec.conn.GetFile(ctx, key, opts...)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	meta := func(name string) string {
+		for k, v := range info.Metadata {
+			if strings.EqualFold(k, name) {
+				return v
+			}
+		}
+		return ""
+	}
+	if meta(metaEnvelopeAlgorithm) != envelopeAlgorithm {
+		body.Close()
+		return nil, ObjectInfo{}, ErrNotEnvelopeEncrypted
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(meta(metaEnvelopeKey))
+	if err != nil {
+		body.Close()
+		return nil, ObjectInfo{}, ErrDecryptionFailed
+	}
+	nonce, err := base64.StdEncoding.DecodeString(meta(metaEnvelopeNonce))
+	if err != nil || len(nonce) != 12 {
+		body.Close()
+		return nil, ObjectInfo{}, ErrDecryptionFailed
+	}
+	// chunk size comes from metadata which isn't authenticated, so only size used by PutReader is accepted
+	// instead of allocating buffer of any size
+	chunkSize, err := strconv.Atoi(meta(metaEnvelopeChunkSize))
+	if err != nil || chunkSize != envelopeChunkSize {
+		body.Close()
+		return nil, ObjectInfo{}, ErrDecryptionFailed
+	}
+	dataKey, err := ec.wrapper.UnwrapKey(ctx, wrapped, meta(metaEnvelopeKeyID))
+	if err != nil {
+		body.Close()
+		return nil, ObjectInfo{}, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		body.Close()
+		return nil, ObjectInfo{}, ErrDecryptionFailed
+	}
+
+	info.Size = plaintextSize(info.Size, int64(chunkSize), int64(aead.Overhead()))
+	return &decryptingReader{
+		body:   body,
+		src:    bufio.NewReaderSize(body, chunkSize+aead.Overhead()),
+		aead:   aead,
+		nonce:  nonce,
+		sealed: make([]byte, chunkSize+aead.Overhead()),
+	}, info, nil
+}
+
+// encryptingReader seals chunks of src one by one. Every chunk has own nonce derived from
+// base nonce and chunk number, the last chunk is marked in additional data, so truncation is detected
+type encryptingReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint32
+	plain   []byte
+	sealed  []byte
+	done    bool
+}
+
+func (er *encryptingReader) Read(p []byte) (int, error) {
+	for len(er.sealed) == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, er.sealed)
+	er.sealed = er.sealed[n:]
+	return n, nil
+}
+
+func (er *encryptingReader) sealNext() error {
+	// only clean end of src is sealed as the final chunk, error of cut-off src fails the upload
+	n, err := readPart(er.src, er.plain)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	final := err == io.EOF
+	if !final {
+		if _, peekErr := er.src.Peek(1); peekErr == io.EOF {
+			final = true
+		} else if peekErr != nil {
+			return peekErr
+		}
+	}
+	er.sealed = er.aead.Seal(er.sealed[:0], chunkNonce(er.nonce, er.counter), er.plain[:n], chunkAdditionalData(final))
+	er.counter++
+	er.done = final
+	return nil
+}
+
+// decryptingReader opens chunks sealed by encryptingReader
+type decryptingReader struct {
+	body    io.Closer
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint32
+	sealed  []byte
+	plain   []byte
+	done    bool
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.openNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
+
+func (dr *decryptingReader) openNext() error {
+	n, err := io.ReadFull(dr.src, dr.sealed)
+	if err == io.EOF {
+		// stream ended before the last chunk
+		return ErrDecryptionFailed
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	final := err != nil
+	if !final {
+		if _, peekErr := dr.src.Peek(1); peekErr == io.EOF {
+			final = true
+		} else if peekErr != nil {
+			return peekErr
+		}
+	}
+	plain, err := dr.aead.Open(dr.sealed[:0], chunkNonce(dr.nonce, dr.counter), dr.sealed[:n], chunkAdditionalData(final))
+	if err != nil {
+		return ErrDecryptionFailed
+	}
+	dr.plain = plain
+	dr.counter++
+	dr.done = final
+	return nil
+}
+
+func (dr *decryptingReader) Close() error {
+	return dr.body.Close()
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != dataKeyLen {
+		return nil, fmt.Errorf("key must be %d bytes", dataKeyLen)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce xors the last 4 bytes of base nonce with chunk number
+func chunkNonce(base []byte, counter uint32) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+	last := binary.BigEndian.Uint32(nonce[len(nonce)-4:])
+	binary.BigEndian.PutUint32(nonce[len(nonce)-4:], last^counter)
+	return nonce
+}
+
+func chunkAdditionalData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// plaintextSize calculates size of plaintext by size of encrypted object
+func plaintextSize(size, chunkSize, overhead int64) int64 {
+	sealedChunk := chunkSize + overhead
+	chunks := (size + sealedChunk - 1) / sealedChunk
+	if chunks == 0 {
+		return 0
+	}
+	return size - chunks*overhead
+}
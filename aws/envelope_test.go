@@ -0,0 +1,273 @@
+package aws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestEnvelopeConnector_RoundTrip(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	keyring, err := NewLocalKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte("1"), 32)})
+	assert.NoError(t, err)
+	otherKeyring, err := NewLocalKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte("2"), 32)})
+	assert.NoError(t, err)
+
+	// arrange
+	cases := []struct {
+		desc        string
+		plaintext   []byte
+		corrupt     func(sealed []byte) []byte
+		corruptMeta map[string]string
+		readKeyring *LocalKeyring
+		wantErr     error
+	}{
+		{
+			desc:      "Should decrypt empty file",
+			plaintext: []byte{},
+		},
+		{
+			desc:      "Should decrypt file of several chunks",
+			plaintext: bytes.Repeat([]byte("abc"), envelopeChunkSize),
+		},
+		{
+			desc:      "Should decrypt file of exactly one chunk",
+			plaintext: bytes.Repeat([]byte("a"), envelopeChunkSize),
+		},
+		{
+			desc:      "Should returns error when the last chunk is cut",
+			plaintext: bytes.Repeat([]byte("abc"), envelopeChunkSize),
+			corrupt: func(sealed []byte) []byte {
+				return sealed[:2*(envelopeChunkSize+16)]
+			},
+			wantErr: ErrDecryptionFailed,
+		},
+		{
+			desc:        "Should returns error when chunk size is huge",
+			plaintext:   []byte("secret"),
+			corruptMeta: map[string]string{metaEnvelopeChunkSize: "1099511627776"},
+			wantErr:     ErrDecryptionFailed,
+		},
+		{
+			desc:        "Should returns error when chunk size is negative",
+			plaintext:   []byte("secret"),
+			corruptMeta: map[string]string{metaEnvelopeChunkSize: "-16"},
+			wantErr:     ErrDecryptionFailed,
+		},
+		{
+			desc:      "Should returns error when body is modified",
+			plaintext: []byte("secret"),
+			corrupt: func(sealed []byte) []byte {
+				sealed[0] ^= 1
+				return sealed
+			},
+			wantErr: ErrDecryptionFailed,
+		},
+		{
+			desc:        "Should returns error when key-encryption-key is wrong",
+			plaintext:   []byte("secret"),
+			readKeyring: otherKeyring,
+			wantErr:     ErrDecryptionFailed,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var (
+				stored   []byte
+				metadata map[string]*string
+			)
+			generator := NewMockiGenerate(ctrl)
+			generator.EXPECT().GenerateTime().Return("time")
+			generator.EXPECT().GenerateUUID().Return("111")
+
+			svc := NewMockiS3Client(ctrl)
+			svc.EXPECT().UploadWithContext(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3manager.UploadInput) error {
+					stored, err = ioutil.ReadAll(input.Body)
+					assert.NoError(t, err)
+					assert.Equal(t, "application/octet-stream", *input.ContentType)
+					// aws returns metadata keys in canonical header form
+					metadata = map[string]*string{}
+					for k, v := range input.Metadata {
+						metadata[http.CanonicalHeaderKey(k)] = v
+					}
+					return nil
+				})
+			svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+					assert.Equal(t, "time_111_file.bin", *input.Key)
+					body := stored
+					if c.corrupt != nil {
+						body = c.corrupt(body)
+					}
+					for k, v := range c.corruptMeta {
+						metadata[http.CanonicalHeaderKey(k)] = aws.String(v)
+					}
+					return &s3.GetObjectOutput{
+						Body:          ioutil.NopCloser(bytes.NewReader(body)),
+						ContentLength: aws.Int64(int64(len(body))),
+						Metadata:      metadata,
+					}, nil
+				})
+
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+			readKeyring := keyring
+			if c.readKeyring != nil {
+				readKeyring = c.readKeyring
+			}
+
+			// actual
+			key, putErr := NewEnvelopeConnector(awsConn, keyring).
+				PutReader(context.Background(), "file.bin", "application/octet-stream", bytes.NewReader(c.plaintext), -1)
+			assert.NoError(t, putErr)
+			assert.NotEqual(t, c.plaintext, stored)
+
+			body, info, gotErr := NewEnvelopeConnector(awsConn, readKeyring).GetFile(context.Background(), key)
+			var got []byte
+			if gotErr == nil {
+				got, gotErr = ioutil.ReadAll(body)
+				assert.NoError(t, body.Close())
+			}
+
+			// assert
+			if c.wantErr != nil {
+				assert.Equal(t, c.wantErr, gotErr)
+				return
+			}
+			assert.NoError(t, gotErr)
+			assert.Equal(t, c.plaintext, got)
+			assert.Equal(t, int64(len(c.plaintext)), info.Size)
+		})
+	}
+}
+
+func TestEnvelopeConnector_EncryptCutOffPlaintext(t *testing.T) {
+	// arrange
+	aead, err := newGCM(bytes.Repeat([]byte("1"), dataKeyLen))
+	require.NoError(t, err)
+	plaintext := io.MultiReader(bytes.NewReader(bytes.Repeat([]byte("abc"), envelopeChunkSize)), iotest.ErrReader(io.ErrUnexpectedEOF))
+	body := &encryptingReader{
+		src:   bufio.NewReaderSize(plaintext, envelopeChunkSize),
+		aead:  aead,
+		nonce: make([]byte, aead.NonceSize()),
+		plain: make([]byte, envelopeChunkSize),
+	}
+
+	// actual
+	_, gotErr := ioutil.ReadAll(body)
+
+	// assert
+	assert.Equal(t, io.ErrUnexpectedEOF, gotErr)
+}
+
+func TestEnvelopeConnector_PutReaderShortPlaintext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	keyring, err := NewLocalKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte("1"), 32)})
+	require.NoError(t, err)
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().UploadWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3manager.UploadInput) error {
+			_, err := ioutil.ReadAll(input.Body)
+			return err
+		})
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+
+	// actual
+	key, gotErr := NewEnvelopeConnector(awsConn, keyring).
+		PutReader(context.Background(), "file.bin", "application/octet-stream", bytes.NewReader([]byte("abc")), 10)
+
+	// assert
+	assert.Equal(t, "", key)
+	assert.Equal(t, io.ErrUnexpectedEOF, gotErr)
+}
+
+func TestEnvelopeConnector_GetFileNotEncrypted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	keyring, _ := NewLocalKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte("1"), 32)})
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+		Return(&s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader([]byte("plain")))}, nil)
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	// actual
+	_, _, gotErr := NewEnvelopeConnector(awsConn, keyring).GetFile(context.Background(), "key")
+
+	// assert
+	assert.True(t, errors.Is(gotErr, ErrNotEnvelopeEncrypted))
+}
+
+func TestNewLocalKeyring(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc      string
+		currentID string
+		keys      map[string][]byte
+		wantErr   error
+	}{
+		{
+			desc:      "Should returns error when there is no current key",
+			currentID: "k2",
+			keys:      map[string][]byte{"k1": bytes.Repeat([]byte("1"), 32)},
+			wantErr:   cerr.NewErrFuncArgMock("currentID", "NewLocalKeyring"),
+		},
+		{
+			desc:      "Should returns error when key has wrong length",
+			currentID: "k1",
+			keys:      map[string][]byte{"k1": []byte("short")},
+			wantErr:   cerr.NewErrFuncArgMock("keys", "NewLocalKeyring"),
+		},
+		{
+			desc:      "Should returns no error",
+			currentID: "k1",
+			keys:      map[string][]byte{"k1": bytes.Repeat([]byte("1"), 32)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			_, gotErr := NewLocalKeyring(c.currentID, c.keys)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
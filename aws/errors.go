@@ -0,0 +1,153 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"net"
+	"net/http"
+)
+
+const (
+	// ErrEmptyBucket is error, which is returned when AWSInfo has no bucket
+	ErrEmptyBucket = cerr.New("bucket is empty")
+
+	// ErrEmptyURL is error, which is returned when AWSInfo has no url
+	ErrEmptyURL = cerr.New("aws url is empty")
+
+	// ErrInvalidTimeout is error, which is returned when timeout of AWSConnector is not longer than 5 seconds
+	ErrInvalidTimeout = cerr.New("timeout must be longer than 5 seconds")
+
+	// ErrInvalidFileObject is error, which is returned when file object doesn't match name:{..},dataUrl:{..} format
+	ErrInvalidFileObject = cerr.New("invalid file object")
+
+	// ErrAccessDenied is error, which is returned when aws denies access to bucket or object
+	ErrAccessDenied = cerr.New("access denied")
+
+	// ErrThrottled is error, which is returned when aws asks to reduce request rate
+	ErrThrottled = cerr.New("request rate is throttled")
+
+	// ErrTimeout is error, which is returned when aws call didn't finish in time
+	ErrTimeout = cerr.New("request timed out")
+)
+
+// S3Error is returned when aws call fails. It wraps the original error,
+// errors.Is reports whether it's cerr.ErrNotFound, ErrAccessDenied, ErrThrottled or ErrTimeout
+type S3Error struct {
+	Operation  string
+	Bucket     string
+	Key        string
+	Code       string
+	RequestID  string
+	StatusCode int
+	// Attempts is count of attempts made by retry policy
+	Attempts int
+	Err      error
+}
+
+// newS3Error describes err returned by aws call of the operation
+func newS3Error(operation, bucket, key string, err error) S3Error {
+	s3Err := S3Error{
+		Operation: operation,
+		Bucket:    bucket,
+		Key:       key,
+		Attempts:  1,
+		Err:       err,
+	}
+	var attemptsErr ErrAttempts
+	if errors.As(err, &attemptsErr) {
+		s3Err.Attempts = attemptsErr.Attempts
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		s3Err.Code = awsErr.Code()
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		s3Err.RequestID = reqErr.RequestID()
+		s3Err.StatusCode = reqErr.StatusCode()
+	}
+	return s3Err
+}
+
+// Error returns error's string
+func (e S3Error) Error() string {
+	target := e.Bucket
+	if e.Key != "" {
+		target += "/" + e.Key
+	}
+	return fmt.Sprintf("AWS returned error, %s %s failed: %v", e.Operation, target, e.Err)
+}
+
+// Unwrap returns the original error
+func (e S3Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error belongs to class described by target
+func (e S3Error) Is(target error) bool {
+	switch target {
+	case cerr.ErrNotFound:
+		return e.NotFound()
+	case ErrAccessDenied:
+		return e.AccessDenied()
+	case ErrThrottled:
+		return e.Throttled()
+	case ErrTimeout:
+		return e.Timeout()
+	}
+	return false
+}
+
+// NotFound reports whether there is no such key or bucket
+func (e S3Error) NotFound() bool {
+	switch e.Code {
+	case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket, s3.ErrCodeNoSuchUpload, "NoSuchBucketPolicy", "NotFound":
+		return true
+	}
+	return e.StatusCode == http.StatusNotFound
+}
+
+// AccessDenied reports whether aws denied the call
+func (e S3Error) AccessDenied() bool {
+	switch e.Code {
+	case "AccessDenied", "AllAccessDisabled", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+		return true
+	}
+	return e.StatusCode == http.StatusForbidden
+}
+
+// Throttled reports whether aws asked to reduce request rate
+func (e S3Error) Throttled() bool {
+	switch e.Code {
+	case "SlowDown", "Throttling", "ThrottlingException", "TooManyRequests", "RequestLimitExceeded":
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// Timeout reports whether the call didn't finish in time
+func (e S3Error) Timeout() bool {
+	switch e.Code {
+	case "RequestTimeout", request.ErrCodeResponseTimeout:
+		return true
+	}
+	// awserr doesn't unwrap to the original error
+	var awsErr awserr.Error
+	if errors.As(e.Err, &awsErr) && awsErr.OrigErr() != nil {
+		return isTimeout(awsErr.OrigErr())
+	}
+	return isTimeout(e.Err)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
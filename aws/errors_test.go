@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestS3Error_Is(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc    string
+		err     error
+		want    error
+		notWant []error
+	}{
+		{
+			desc:    "Should be cerr.ErrNotFound when there is no such key",
+			err:     awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchKey, "no such key", nil), http.StatusNotFound, "id"),
+			want:    cerr.ErrNotFound,
+			notWant: []error{ErrAccessDenied, ErrThrottled, ErrTimeout},
+		},
+		{
+			desc:    "Should be ErrAccessDenied when aws returns 403",
+			err:     awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), http.StatusForbidden, "id"),
+			want:    ErrAccessDenied,
+			notWant: []error{cerr.ErrNotFound, ErrThrottled, ErrTimeout},
+		},
+		{
+			desc:    "Should be ErrThrottled when aws returns SlowDown",
+			err:     ErrAttempts{Attempts: 3, Err: awserr.NewRequestFailure(awserr.New("SlowDown", "reduce your request rate", nil), http.StatusServiceUnavailable, "id")},
+			want:    ErrThrottled,
+			notWant: []error{cerr.ErrNotFound, ErrAccessDenied, ErrTimeout},
+		},
+		{
+			desc:    "Should be ErrTimeout when context deadline is exceeded",
+			err:     awserr.New(request.CanceledErrorCode, "request context canceled", context.DeadlineExceeded),
+			want:    ErrTimeout,
+			notWant: []error{cerr.ErrNotFound, ErrAccessDenied, ErrThrottled},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			got := newS3Error("GetObject", "bucket", "key", c.err)
+
+			// assert
+			assert.True(t, errors.Is(got, c.want))
+			for _, notWant := range c.notWant {
+				assert.False(t, errors.Is(got, notWant))
+			}
+		})
+	}
+}
+
+func TestClientStatusUpdater_GetFileS3Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any()).
+		Return(nil, awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), http.StatusForbidden, "req-1"))
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	// actual
+	_, _, gotErr := awsConn.GetFile(context.Background(), "dir/key")
+
+	// assert
+	var s3Err S3Error
+	assert.True(t, errors.As(gotErr, &s3Err))
+	assert.Equal(t, "GetObject", s3Err.Operation)
+	assert.Equal(t, "test bucket", s3Err.Bucket)
+	assert.Equal(t, "dir/key", s3Err.Key)
+	assert.Equal(t, "AccessDenied", s3Err.Code)
+	assert.Equal(t, "req-1", s3Err.RequestID)
+	assert.True(t, errors.Is(gotErr, ErrAccessDenied))
+}
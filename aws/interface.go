@@ -2,13 +2,42 @@ package aws
 
 import (
 	"context"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"time"
 )
 
 //go:generate mockgen -source=interface.go -destination=mocks_test.go -package=aws
 type s3Client interface {
 	PutObjectWithContext(ctx context.Context, input *s3.PutObjectInput) error
-	PutBucketPolicy(input *s3.PutBucketPolicyInput) error
+	PutBucketPolicyWithContext(ctx context.Context, input *s3.PutBucketPolicyInput) error
+	GetBucketPolicyWithContext(ctx context.Context, input *s3.GetBucketPolicyInput) (*s3.GetBucketPolicyOutput, error)
+	DeleteBucketPolicyWithContext(ctx context.Context, input *s3.DeleteBucketPolicyInput) error
+	HeadBucketWithContext(ctx context.Context, input *s3.HeadBucketInput) error
+	CreateBucketWithContext(ctx context.Context, input *s3.CreateBucketInput) error
+	PutBucketVersioningWithContext(ctx context.Context, input *s3.PutBucketVersioningInput) error
+	PutBucketCorsWithContext(ctx context.Context, input *s3.PutBucketCorsInput) error
+	DeleteBucketCorsWithContext(ctx context.Context, input *s3.DeleteBucketCorsInput) error
+	GetBucketLifecycleConfigurationWithContext(ctx context.Context, input *s3.GetBucketLifecycleConfigurationInput) (*s3.GetBucketLifecycleConfigurationOutput, error)
+	PutBucketLifecycleConfigurationWithContext(ctx context.Context, input *s3.PutBucketLifecycleConfigurationInput) error
+	DeleteBucketLifecycleWithContext(ctx context.Context, input *s3.DeleteBucketLifecycleInput) error
+	UploadWithContext(ctx context.Context, input *s3manager.UploadInput) error
+	CreateMultipartUploadWithContext(ctx context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error)
+	UploadPartWithContext(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error)
+	CompleteMultipartUploadWithContext(ctx context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUploadWithContext(ctx context.Context, input *s3.AbortMultipartUploadInput) error
+	GetObjectWithContext(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	PresignPutObject(input *s3.PutObjectInput, expire time.Duration) (string, error)
+	PresignGetObject(input *s3.GetObjectInput, expire time.Duration) (string, error)
+	Credentials() (credentials.Value, error)
+	Endpoint() string
+	ListObjectsV2WithContext(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
+	DeleteObjectWithContext(ctx context.Context, input *s3.DeleteObjectInput) error
+	DeleteObjectsWithContext(ctx context.Context, input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error)
+	CopyObjectWithContext(ctx context.Context, input *s3.CopyObjectInput) error
+	ListObjectVersionsWithContext(ctx context.Context, input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error)
+	HeadObjectWithContext(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
 }
 
 type dataGenerate interface {
@@ -0,0 +1,174 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"path"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ErrKeyNeedsContent is error, which is returned when key strategy needs content
+	// of the file, but the file is streamed
+	ErrKeyNeedsContent = cerr.New("key strategy needs content of the file")
+
+	// ErrNoKeyPrefix is error, which is returned when key strategy needs prefix
+	// from context, but there is no prefix set by WithKeyPrefix
+	ErrNoKeyPrefix = cerr.New("key prefix not found in context")
+)
+
+// defaultFileName replaces file name when nothing is left after sanitizing
+const defaultFileName = "file"
+
+// KeyStrategy builds keys of objects uploaded by AWSConnector
+type KeyStrategy interface {
+	Key(ctx context.Context, src KeySource) (string, error)
+}
+
+// KeySource is everything key strategy may build key from
+type KeySource struct {
+	// FileName is sanitized original name of the file
+	FileName string
+	// Content is the file body, it is nil when the file is streamed
+	Content []byte
+
+	generator dataGenerate
+}
+
+// Unique returns key in format <time>_<uuid>_<FileName>
+func (src KeySource) Unique() string {
+	return fmt.Sprintf("%s_%s_%s", src.generator.GenerateTime(), src.generator.GenerateUUID(), src.FileName)
+}
+
+// KeyStrategyFunc is an adapter to use ordinary function as KeyStrategy
+type KeyStrategyFunc func(ctx context.Context, src KeySource) (string, error)
+
+// Key calls f(ctx, src)
+func (f KeyStrategyFunc) Key(ctx context.Context, src KeySource) (string, error) {
+	return f(ctx, src)
+}
+
+var (
+	// DefaultKeys builds keys in format <time>_<uuid>_<filename>, it is used when no strategy is set
+	DefaultKeys KeyStrategy = KeyStrategyFunc(func(_ context.Context, src KeySource) (string, error) {
+		return src.Unique(), nil
+	})
+
+	// OriginalNameKeys uses sanitized original file name as key,
+	// uploads of files with the same name overwrite each other
+	OriginalNameKeys KeyStrategy = KeyStrategyFunc(func(_ context.Context, src KeySource) (string, error) {
+		return src.FileName, nil
+	})
+
+	// ContentHashKeys builds keys in format <sha256 of content><extension>,
+	// so the same content is always stored by the same key.
+	// Streamed uploads fail with ErrKeyNeedsContent
+	ContentHashKeys KeyStrategy = KeyStrategyFunc(func(_ context.Context, src KeySource) (string, error) {
+		if src.Content == nil {
+			return "", ErrKeyNeedsContent
+		}
+		sum := sha256.Sum256(src.Content)
+		return hex.EncodeToString(sum[:]) + strings.ToLower(path.Ext(src.FileName)), nil
+	})
+)
+
+// DatePartitionedKeys prefixes keys built by inner with upload date, e.g. 2026/10/17/<key>.
+// nil inner means DefaultKeys
+func DatePartitionedKeys(inner KeyStrategy) KeyStrategy {
+	if inner == nil {
+		inner = DefaultKeys
+	}
+	return KeyStrategyFunc(func(ctx context.Context, src KeySource) (string, error) {
+		key, err := inner.Key(ctx, src)
+		if err != nil {
+			return "", err
+		}
+		return timeNow().UTC().Format("2006/01/02") + "/" + key, nil
+	})
+}
+
+// ContextPrefixKeys prefixes keys built by inner with prefix set by WithKeyPrefix,
+// e.g. <tenant>/<user>/<key>. Returns ErrNoKeyPrefix when ctx has no prefix.
+// nil inner means DefaultKeys
+func ContextPrefixKeys(inner KeyStrategy) KeyStrategy {
+	if inner == nil {
+		inner = DefaultKeys
+	}
+	return KeyStrategyFunc(func(ctx context.Context, src KeySource) (string, error) {
+		prefix, ok := ctx.Value(keyPrefixCtxKey{}).(string)
+		if !ok || prefix == "" {
+			return "", ErrNoKeyPrefix
+		}
+		key, err := inner.Key(ctx, src)
+		if err != nil {
+			return "", err
+		}
+		return prefix + "/" + key, nil
+	})
+}
+
+type keyPrefixCtxKey struct{}
+
+// WithKeyPrefix returns context which carries key prefix for ContextPrefixKeys.
+// Every segment is sanitized the same way as file names and segments are joined with "/"
+func WithKeyPrefix(ctx context.Context, segments ...string) context.Context {
+	sanitized := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if strings.TrimSpace(segment) != "" {
+			sanitized = append(sanitized, SanitizeFileName(segment))
+		}
+	}
+	return context.WithValue(ctx, keyPrefixCtxKey{}, strings.Join(sanitized, "/"))
+}
+
+// WithKeyStrategy sets strategy which builds keys of uploaded objects
+func WithKeyStrategy(strategy KeyStrategy) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.keyStrategy = strategy
+	}
+}
+
+// SanitizeFileName makes file name safe to use as a part of a key:
+// directories and path traversal sequences are cut, characters other than
+// letters, digits, '.', '-' and '_' are replaced with '_'
+func SanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return defaultFileName
+	}
+	return name
+}
+
+// objectKey builds key of object by strategy of AWSConnector, key set by WithObjectKey is used as is
+// unless it's reserved for deduplicated content
+func (awsConn *AWSConnector) objectKey(ctx context.Context, fileName string, content []byte, o putOptions) (string, error) {
+	if o.key != "" {
+		if awsConn.isReservedKey(o.key) {
+			return "", ErrReservedKey
+		}
+		return o.key, nil
+	}
+	strategy := awsConn.keyStrategy
+	if strategy == nil {
+		strategy = DefaultKeys
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return strategy.Key(ctx, KeySource{
+		FileName:  SanitizeFileName(fileName),
+		Content:   content,
+		generator: awsConn.generator,
+	})
+}
@@ -0,0 +1,143 @@
+package aws
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc string
+		name string
+		want string
+	}{
+		{
+			desc: "Should keep safe name",
+			name: "img-1_final.png",
+			want: "img-1_final.png",
+		},
+		{
+			desc: "Should cut path traversal sequences",
+			name: "../../etc/passwd",
+			want: "passwd",
+		},
+		{
+			desc: "Should cut windows directories",
+			name: `C:\Users\me\photo.jpg`,
+			want: "photo.jpg",
+		},
+		{
+			desc: "Should replace unsafe characters",
+			name: "my photo?#%.jpg",
+			want: "my_photo___.jpg",
+		},
+		{
+			desc: "Should keep unicode letters",
+			name: "фото.jpg",
+			want: "фото.jpg",
+		},
+		{
+			desc: "Should returns default name when nothing is left",
+			name: "..",
+			want: defaultFileName,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			got := SanitizeFileName(c.name)
+
+			// assert
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestKeyStrategies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	// arrange
+	cases := []struct {
+		desc      string
+		ctx       context.Context
+		strategy  KeyStrategy
+		content   []byte
+		generator *MockiGenerate
+		wantKey   string
+		wantErr   error
+	}{
+		{
+			desc:     "DefaultKeys should build key from time, uuid and file name",
+			ctx:      context.Background(),
+			strategy: DefaultKeys,
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			}(NewMockiGenerate(ctrl)),
+			wantKey: "time_111_my_img.PNG",
+		},
+		{
+			desc:      "OriginalNameKeys should use sanitized file name",
+			ctx:       context.Background(),
+			strategy:  OriginalNameKeys,
+			generator: NewMockiGenerate(ctrl),
+			wantKey:   "my_img.PNG",
+		},
+		{
+			desc:      "ContentHashKeys should returns error when content is streamed",
+			ctx:       context.Background(),
+			strategy:  ContentHashKeys,
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrKeyNeedsContent,
+		},
+		{
+			desc:      "DatePartitionedKeys should prefix content hash with date",
+			ctx:       context.Background(),
+			strategy:  DatePartitionedKeys(ContentHashKeys),
+			content:   []byte("body"),
+			generator: NewMockiGenerate(ctrl),
+			wantKey:   "2026/10/17/230d8358dc8e8890b4c58deeb62912ee2f20357ae92a5cc861b98e68fe31acb5.png",
+		},
+		{
+			desc:      "ContextPrefixKeys should returns error when there is no prefix",
+			ctx:       context.Background(),
+			strategy:  ContextPrefixKeys(OriginalNameKeys),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrNoKeyPrefix,
+		},
+		{
+			desc:      "ContextPrefixKeys should prefix key with sanitized segments",
+			ctx:       WithKeyPrefix(context.Background(), "tenant 1", "", "../user"),
+			strategy:  ContextPrefixKeys(OriginalNameKeys),
+			generator: NewMockiGenerate(ctrl),
+			wantKey:   "tenant_1/user/my_img.PNG",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), c.generator, WithKeyStrategy(c.strategy))
+			gotKey, gotErr := aws.objectKey(c.ctx, "dir/my img.PNG", c.content, putOptions{})
+
+			// assert
+			assert.Equal(t, c.wantKey, gotKey)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
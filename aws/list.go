@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"sort"
+)
+
+// ObjectIterator iterates over objects stored in aws page by page,
+// next page is requested only when current one is over.
+// Usage:
+//
+//	it := awsConn.List(ctx, "prefix/")
+//	for it.Next() {
+//		info := it.Object()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ObjectIterator struct {
+	ctx     context.Context
+	awsConn *AWSConnector
+	input   *s3.ListObjectsV2Input
+	page    []listEntry
+	current listEntry
+	done    bool
+	err     error
+}
+
+type listEntry struct {
+	info     ObjectInfo
+	isPrefix bool
+}
+
+// List returns iterator over all objects which keys start with prefix.
+// Empty prefix means all objects of the bucket
+func (awsConn *AWSConnector) List(ctx context.Context, prefix string) *ObjectIterator {
+	return awsConn.newObjectIterator(ctx, prefix, "")
+}
+
+// ListDir returns iterator over objects and "directories" which are placed
+// right under prefix, where delimiter separates directories in keys, e.g. "/".
+// Every directory is returned once as an entry for which IsPrefix is true
+func (awsConn *AWSConnector) ListDir(ctx context.Context, prefix, delimiter string) *ObjectIterator {
+	return awsConn.newObjectIterator(ctx, prefix, delimiter)
+}
+
+func (awsConn *AWSConnector) newObjectIterator(ctx context.Context, prefix, delimiter string) *ObjectIterator {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket: &awsConn.AWSInfo.Bucket,
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+	return &ObjectIterator{
+		ctx:     ctx,
+		awsConn: awsConn,
+		input:   input,
+	}
+}
+
+// Next moves iterator to the next entry, returns false when there are
+// no more entries or an error occurred
+func (it *ObjectIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetchPage()
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Object returns info of the current entry. Only Key is set for directories
+func (it *ObjectIterator) Object() ObjectInfo {
+	return it.current.info
+}
+
+// IsPrefix reports whether the current entry is a directory returned by ListDir
+func (it *ObjectIterator) IsPrefix() bool {
+	return it.current.isPrefix
+}
+
+// Err returns error which stopped the iteration
+func (it *ObjectIterator) Err() error {
+	return it.err
+}
+
+func (it *ObjectIterator) fetchPage() {
+	ctx, cancelFn := context.WithTimeout(it.ctx, it.awsConn.timeout)
+	defer cancelFn()
+
+	var out *s3.ListObjectsV2Output
+	err := it.awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		out, err = it.awsConn.svc.ListObjectsV2WithContext(ctx, it.input)
+		return err
+	})
+	if err != nil {
+		it.err = newS3Error("ListObjectsV2", it.awsConn.AWSInfo.Bucket, aws.StringValue(it.input.Prefix), err)
+		return
+	}
+
+	page := make([]listEntry, 0, len(out.Contents)+len(out.CommonPrefixes))
+	for _, obj := range out.Contents {
+		page = append(page, listEntry{info: ObjectInfo{
+			Key:          aws.StringValue(obj.Key),
+			Size:         aws.Int64Value(obj.Size),
+			ETag:         aws.StringValue(obj.ETag),
+			LastModified: aws.TimeValue(obj.LastModified),
+		}})
+	}
+	for _, prefix := range out.CommonPrefixes {
+		page = append(page, listEntry{info: ObjectInfo{Key: aws.StringValue(prefix.Prefix)}, isPrefix: true})
+	}
+	// aws returns objects and prefixes in separate lists, both are sorted by key
+	sort.SliceStable(page, func(i, j int) bool {
+		return page[i].info.Key < page[j].info.Key
+	})
+	it.page = page
+
+	if !aws.BoolValue(out.IsTruncated) || out.NextContinuationToken == nil {
+		it.done = true
+		return
+	}
+	it.input.ContinuationToken = out.NextContinuationToken
+}
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_List(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc         string
+		svc          *MockiS3Client
+		wantKeys     []string
+		wantPrefixes []bool
+		wantErr      error
+	}{
+		{
+			desc: "Should returns error when ListObjectsV2WithContext failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().ListObjectsV2WithContext(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "ListObjectsV2",
+				Bucket:    "test bucket",
+				Key:       "dir/",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc: "Should follow continuation token and returns all objects",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				gomock.InOrder(
+					m.EXPECT().ListObjectsV2WithContext(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+							assert.Nil(t, input.ContinuationToken)
+							assert.Equal(t, "dir/", *input.Prefix)
+							return &s3.ListObjectsV2Output{
+								Contents: []*s3.Object{
+									{Key: aws.String("dir/a"), Size: aws.Int64(1)},
+									{Key: aws.String("dir/b"), Size: aws.Int64(2)},
+								},
+								IsTruncated:           aws.Bool(true),
+								NextContinuationToken: aws.String("token"),
+							}, nil
+						}),
+					m.EXPECT().ListObjectsV2WithContext(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+							assert.Equal(t, "token", *input.ContinuationToken)
+							return &s3.ListObjectsV2Output{
+								Contents: []*s3.Object{
+									{Key: aws.String("dir/c"), Size: aws.Int64(3)},
+								},
+								CommonPrefixes: []*s3.CommonPrefix{
+									{Prefix: aws.String("dir/b/")},
+								},
+								IsTruncated: aws.Bool(false),
+							}, nil
+						}),
+				)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantKeys:     []string{"dir/a", "dir/b", "dir/b/", "dir/c"},
+			wantPrefixes: []bool{false, false, true, false},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			it := aws.List(context.Background(), "dir/")
+			var gotKeys []string
+			var gotPrefixes []bool
+			for it.Next() {
+				gotKeys = append(gotKeys, it.Object().Key)
+				gotPrefixes = append(gotPrefixes, it.IsPrefix())
+			}
+
+			// assert
+			assert.Equal(t, c.wantKeys, gotKeys)
+			assert.Equal(t, c.wantPrefixes, gotPrefixes)
+			assert.Equal(t, c.wantErr, it.Err())
+		})
+	}
+}
+
+func TestClientStatusUpdater_ListDir(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().ListObjectsV2WithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+			assert.Equal(t, "/", *input.Delimiter)
+			return &s3.ListObjectsV2Output{
+				CommonPrefixes: []*s3.CommonPrefix{{Prefix: aws.String("2026/")}},
+			}, nil
+		})
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	// actual
+	it := awsConn.ListDir(context.Background(), "", "/")
+
+	// assert
+	assert.True(t, it.Next())
+	assert.True(t, it.IsPrefix())
+	assert.Equal(t, "2026/", it.Object().Key)
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+}
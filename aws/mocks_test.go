@@ -6,9 +6,12 @@ package aws
 
 import (
 	context "context"
+	credentials "github.com/aws/aws-sdk-go/aws/credentials"
 	s3 "github.com/aws/aws-sdk-go/service/s3"
+	s3manager "github.com/aws/aws-sdk-go/service/s3/s3manager"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
+	time "time"
 )
 
 // MockiS3Client is a mock of s3Client interface
@@ -48,18 +51,395 @@ func (mr *MockiS3ClientMockRecorder) PutObjectWithContext(ctx, input interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutObjectWithContext", reflect.TypeOf((*MockiS3Client)(nil).PutObjectWithContext), ctx, input)
 }
 
-// PutBucketPolicy mocks base method
-func (m *MockiS3Client) PutBucketPolicy(input *s3.PutBucketPolicyInput) error {
+// UploadWithContext mocks base method
+func (m *MockiS3Client) UploadWithContext(ctx context.Context, input *s3manager.UploadInput) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PutBucketPolicy", input)
+	ret := m.ctrl.Call(m, "UploadWithContext", ctx, input)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// PutBucketPolicy indicates an expected call of PutBucketPolicy
-func (mr *MockiS3ClientMockRecorder) PutBucketPolicy(input interface{}) *gomock.Call {
+// UploadWithContext indicates an expected call of UploadWithContext
+func (mr *MockiS3ClientMockRecorder) UploadWithContext(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutBucketPolicy", reflect.TypeOf((*MockiS3Client)(nil).PutBucketPolicy), input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadWithContext", reflect.TypeOf((*MockiS3Client)(nil).UploadWithContext), ctx, input)
+}
+
+// CreateMultipartUploadWithContext mocks base method
+func (m *MockiS3Client) CreateMultipartUploadWithContext(ctx context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMultipartUploadWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.CreateMultipartUploadOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMultipartUploadWithContext indicates an expected call of CreateMultipartUploadWithContext
+func (mr *MockiS3ClientMockRecorder) CreateMultipartUploadWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMultipartUploadWithContext", reflect.TypeOf((*MockiS3Client)(nil).CreateMultipartUploadWithContext), ctx, input)
+}
+
+// UploadPartWithContext mocks base method
+func (m *MockiS3Client) UploadPartWithContext(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadPartWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.UploadPartOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadPartWithContext indicates an expected call of UploadPartWithContext
+func (mr *MockiS3ClientMockRecorder) UploadPartWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadPartWithContext", reflect.TypeOf((*MockiS3Client)(nil).UploadPartWithContext), ctx, input)
+}
+
+// CompleteMultipartUploadWithContext mocks base method
+func (m *MockiS3Client) CompleteMultipartUploadWithContext(ctx context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteMultipartUploadWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.CompleteMultipartUploadOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteMultipartUploadWithContext indicates an expected call of CompleteMultipartUploadWithContext
+func (mr *MockiS3ClientMockRecorder) CompleteMultipartUploadWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteMultipartUploadWithContext", reflect.TypeOf((*MockiS3Client)(nil).CompleteMultipartUploadWithContext), ctx, input)
+}
+
+// AbortMultipartUploadWithContext mocks base method
+func (m *MockiS3Client) AbortMultipartUploadWithContext(ctx context.Context, input *s3.AbortMultipartUploadInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AbortMultipartUploadWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AbortMultipartUploadWithContext indicates an expected call of AbortMultipartUploadWithContext
+func (mr *MockiS3ClientMockRecorder) AbortMultipartUploadWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbortMultipartUploadWithContext", reflect.TypeOf((*MockiS3Client)(nil).AbortMultipartUploadWithContext), ctx, input)
+}
+
+// GetObjectWithContext mocks base method
+func (m *MockiS3Client) GetObjectWithContext(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetObjectWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.GetObjectOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetObjectWithContext indicates an expected call of GetObjectWithContext
+func (mr *MockiS3ClientMockRecorder) GetObjectWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetObjectWithContext", reflect.TypeOf((*MockiS3Client)(nil).GetObjectWithContext), ctx, input)
+}
+
+// PresignPutObject mocks base method
+func (m *MockiS3Client) PresignPutObject(input *s3.PutObjectInput, expire time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PresignPutObject", input, expire)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PresignPutObject indicates an expected call of PresignPutObject
+func (mr *MockiS3ClientMockRecorder) PresignPutObject(input, expire interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PresignPutObject", reflect.TypeOf((*MockiS3Client)(nil).PresignPutObject), input, expire)
+}
+
+// PresignGetObject mocks base method
+func (m *MockiS3Client) PresignGetObject(input *s3.GetObjectInput, expire time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PresignGetObject", input, expire)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PresignGetObject indicates an expected call of PresignGetObject
+func (mr *MockiS3ClientMockRecorder) PresignGetObject(input, expire interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PresignGetObject", reflect.TypeOf((*MockiS3Client)(nil).PresignGetObject), input, expire)
+}
+
+// Credentials mocks base method
+func (m *MockiS3Client) Credentials() (credentials.Value, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Credentials")
+	ret0, _ := ret[0].(credentials.Value)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Credentials indicates an expected call of Credentials
+func (mr *MockiS3ClientMockRecorder) Credentials() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Credentials", reflect.TypeOf((*MockiS3Client)(nil).Credentials))
+}
+
+// Endpoint mocks base method
+func (m *MockiS3Client) Endpoint() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Endpoint")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Endpoint indicates an expected call of Endpoint
+func (mr *MockiS3ClientMockRecorder) Endpoint() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Endpoint", reflect.TypeOf((*MockiS3Client)(nil).Endpoint))
+}
+
+// ListObjectsV2WithContext mocks base method
+func (m *MockiS3Client) ListObjectsV2WithContext(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListObjectsV2WithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.ListObjectsV2Output)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListObjectsV2WithContext indicates an expected call of ListObjectsV2WithContext
+func (mr *MockiS3ClientMockRecorder) ListObjectsV2WithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListObjectsV2WithContext", reflect.TypeOf((*MockiS3Client)(nil).ListObjectsV2WithContext), ctx, input)
+}
+
+// DeleteObjectWithContext mocks base method
+func (m *MockiS3Client) DeleteObjectWithContext(ctx context.Context, input *s3.DeleteObjectInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteObjectWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteObjectWithContext indicates an expected call of DeleteObjectWithContext
+func (mr *MockiS3ClientMockRecorder) DeleteObjectWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteObjectWithContext", reflect.TypeOf((*MockiS3Client)(nil).DeleteObjectWithContext), ctx, input)
+}
+
+// DeleteObjectsWithContext mocks base method
+func (m *MockiS3Client) DeleteObjectsWithContext(ctx context.Context, input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteObjectsWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.DeleteObjectsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteObjectsWithContext indicates an expected call of DeleteObjectsWithContext
+func (mr *MockiS3ClientMockRecorder) DeleteObjectsWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteObjectsWithContext", reflect.TypeOf((*MockiS3Client)(nil).DeleteObjectsWithContext), ctx, input)
+}
+
+// CopyObjectWithContext mocks base method
+func (m *MockiS3Client) CopyObjectWithContext(ctx context.Context, input *s3.CopyObjectInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CopyObjectWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CopyObjectWithContext indicates an expected call of CopyObjectWithContext
+func (mr *MockiS3ClientMockRecorder) CopyObjectWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyObjectWithContext", reflect.TypeOf((*MockiS3Client)(nil).CopyObjectWithContext), ctx, input)
+}
+
+// HeadObjectWithContext mocks base method
+func (m *MockiS3Client) HeadObjectWithContext(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HeadObjectWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.HeadObjectOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HeadObjectWithContext indicates an expected call of HeadObjectWithContext
+func (mr *MockiS3ClientMockRecorder) HeadObjectWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HeadObjectWithContext", reflect.TypeOf((*MockiS3Client)(nil).HeadObjectWithContext), ctx, input)
+}
+
+// PutBucketPolicyWithContext mocks base method
+func (m *MockiS3Client) PutBucketPolicyWithContext(ctx context.Context, input *s3.PutBucketPolicyInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutBucketPolicyWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutBucketPolicyWithContext indicates an expected call of PutBucketPolicyWithContext
+func (mr *MockiS3ClientMockRecorder) PutBucketPolicyWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutBucketPolicyWithContext", reflect.TypeOf((*MockiS3Client)(nil).PutBucketPolicyWithContext), ctx, input)
+}
+
+// GetBucketPolicyWithContext mocks base method
+func (m *MockiS3Client) GetBucketPolicyWithContext(ctx context.Context, input *s3.GetBucketPolicyInput) (*s3.GetBucketPolicyOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBucketPolicyWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.GetBucketPolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBucketPolicyWithContext indicates an expected call of GetBucketPolicyWithContext
+func (mr *MockiS3ClientMockRecorder) GetBucketPolicyWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBucketPolicyWithContext", reflect.TypeOf((*MockiS3Client)(nil).GetBucketPolicyWithContext), ctx, input)
+}
+
+// DeleteBucketPolicyWithContext mocks base method
+func (m *MockiS3Client) DeleteBucketPolicyWithContext(ctx context.Context, input *s3.DeleteBucketPolicyInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucketPolicyWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucketPolicyWithContext indicates an expected call of DeleteBucketPolicyWithContext
+func (mr *MockiS3ClientMockRecorder) DeleteBucketPolicyWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketPolicyWithContext", reflect.TypeOf((*MockiS3Client)(nil).DeleteBucketPolicyWithContext), ctx, input)
+}
+
+// HeadBucketWithContext mocks base method
+func (m *MockiS3Client) HeadBucketWithContext(ctx context.Context, input *s3.HeadBucketInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HeadBucketWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HeadBucketWithContext indicates an expected call of HeadBucketWithContext
+func (mr *MockiS3ClientMockRecorder) HeadBucketWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HeadBucketWithContext", reflect.TypeOf((*MockiS3Client)(nil).HeadBucketWithContext), ctx, input)
+}
+
+// CreateBucketWithContext mocks base method
+func (m *MockiS3Client) CreateBucketWithContext(ctx context.Context, input *s3.CreateBucketInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBucketWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateBucketWithContext indicates an expected call of CreateBucketWithContext
+func (mr *MockiS3ClientMockRecorder) CreateBucketWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucketWithContext", reflect.TypeOf((*MockiS3Client)(nil).CreateBucketWithContext), ctx, input)
+}
+
+// PutBucketVersioningWithContext mocks base method
+func (m *MockiS3Client) PutBucketVersioningWithContext(ctx context.Context, input *s3.PutBucketVersioningInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutBucketVersioningWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutBucketVersioningWithContext indicates an expected call of PutBucketVersioningWithContext
+func (mr *MockiS3ClientMockRecorder) PutBucketVersioningWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutBucketVersioningWithContext", reflect.TypeOf((*MockiS3Client)(nil).PutBucketVersioningWithContext), ctx, input)
+}
+
+// PutBucketCorsWithContext mocks base method
+func (m *MockiS3Client) PutBucketCorsWithContext(ctx context.Context, input *s3.PutBucketCorsInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutBucketCorsWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutBucketCorsWithContext indicates an expected call of PutBucketCorsWithContext
+func (mr *MockiS3ClientMockRecorder) PutBucketCorsWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutBucketCorsWithContext", reflect.TypeOf((*MockiS3Client)(nil).PutBucketCorsWithContext), ctx, input)
+}
+
+// DeleteBucketCorsWithContext mocks base method
+func (m *MockiS3Client) DeleteBucketCorsWithContext(ctx context.Context, input *s3.DeleteBucketCorsInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucketCorsWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucketCorsWithContext indicates an expected call of DeleteBucketCorsWithContext
+func (mr *MockiS3ClientMockRecorder) DeleteBucketCorsWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketCorsWithContext", reflect.TypeOf((*MockiS3Client)(nil).DeleteBucketCorsWithContext), ctx, input)
+}
+
+// PutBucketLifecycleConfigurationWithContext mocks base method
+func (m *MockiS3Client) PutBucketLifecycleConfigurationWithContext(ctx context.Context, input *s3.PutBucketLifecycleConfigurationInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutBucketLifecycleConfigurationWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutBucketLifecycleConfigurationWithContext indicates an expected call of PutBucketLifecycleConfigurationWithContext
+func (mr *MockiS3ClientMockRecorder) PutBucketLifecycleConfigurationWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutBucketLifecycleConfigurationWithContext", reflect.TypeOf((*MockiS3Client)(nil).PutBucketLifecycleConfigurationWithContext), ctx, input)
+}
+
+// DeleteBucketLifecycleWithContext mocks base method
+func (m *MockiS3Client) DeleteBucketLifecycleWithContext(ctx context.Context, input *s3.DeleteBucketLifecycleInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucketLifecycleWithContext", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucketLifecycleWithContext indicates an expected call of DeleteBucketLifecycleWithContext
+func (mr *MockiS3ClientMockRecorder) DeleteBucketLifecycleWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketLifecycleWithContext", reflect.TypeOf((*MockiS3Client)(nil).DeleteBucketLifecycleWithContext), ctx, input)
+}
+
+// GetBucketLifecycleConfigurationWithContext mocks base method
+func (m *MockiS3Client) GetBucketLifecycleConfigurationWithContext(ctx context.Context, input *s3.GetBucketLifecycleConfigurationInput) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBucketLifecycleConfigurationWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.GetBucketLifecycleConfigurationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBucketLifecycleConfigurationWithContext indicates an expected call of GetBucketLifecycleConfigurationWithContext
+func (mr *MockiS3ClientMockRecorder) GetBucketLifecycleConfigurationWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBucketLifecycleConfigurationWithContext", reflect.TypeOf((*MockiS3Client)(nil).GetBucketLifecycleConfigurationWithContext), ctx, input)
+}
+
+// ListObjectVersionsWithContext mocks base method
+func (m *MockiS3Client) ListObjectVersionsWithContext(ctx context.Context, input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListObjectVersionsWithContext", ctx, input)
+	ret0, _ := ret[0].(*s3.ListObjectVersionsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListObjectVersionsWithContext indicates an expected call of ListObjectVersionsWithContext
+func (mr *MockiS3ClientMockRecorder) ListObjectVersionsWithContext(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListObjectVersionsWithContext", reflect.TypeOf((*MockiS3Client)(nil).ListObjectVersionsWithContext), ctx, input)
 }
 
 // MockiGenerate is a mock of dataGenerate interface
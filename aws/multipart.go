@@ -0,0 +1,303 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/labstack/gommon/log"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// MinPartSize is the smallest part size accepted by S3 for every part except the last one
+	MinPartSize = 5 * 1024 * 1024
+	// DefaultPartSize is used when MultipartConfig.PartSize is not set
+	DefaultPartSize = MinPartSize
+	// DefaultPartsConcurrency is used when MultipartConfig.Concurrency is not set
+	DefaultPartsConcurrency = 5
+	// maxPartsCount is the max count of parts in one multipart upload
+	maxPartsCount = 10000
+)
+
+const (
+	// ErrInvalidPartSize is error, which is returned when part size is less than MinPartSize
+	ErrInvalidPartSize = cerr.New("part size is less than 5MB")
+
+	// ErrInvalidPartsConcurrency is error, which is returned when parts concurrency is negative
+	ErrInvalidPartsConcurrency = cerr.New("parts concurrency is negative")
+
+	// ErrTooManyParts is error, which is returned when body needs more than 10000 parts
+	ErrTooManyParts = cerr.New("body needs more than 10000 parts, increase part size")
+)
+
+// MultipartConfig configures multipart uploads of AWSConnector,
+// zero values are replaced with defaults
+type MultipartConfig struct {
+	// PartSize is size of each part in bytes, except the last one
+	PartSize int64
+	// Concurrency is count of parts uploaded in parallel
+	Concurrency int
+}
+
+func (cfg MultipartConfig) validate() error {
+	if cfg.PartSize != 0 && cfg.PartSize < MinPartSize {
+		return ErrInvalidPartSize
+	}
+	if cfg.Concurrency < 0 {
+		return ErrInvalidPartsConcurrency
+	}
+	return nil
+}
+
+func (cfg MultipartConfig) withDefaults() MultipartConfig {
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = DefaultPartsConcurrency
+	}
+	return cfg
+}
+
+// WithMultipartConfig sets part size and concurrency of multipart uploads
+func WithMultipartConfig(cfg MultipartConfig) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.multipart = cfg
+	}
+}
+
+// PutMultipart uploads r to aws by parts in parallel and returns key of the object.
+// Key is built the same way as in PutFile.
+// Upload is aborted when any part fails or ctx is cancelled, so no orphaned parts are left.
+// Every part is sent with Content-MD5 and ETag of the completed object is compared with MD5s of the parts,
+// the object is removed and ErrChecksumMismatch is returned when they don't match.
+// Empty contentType is detected by the first bytes of r
+func (awsConn *AWSConnector) PutMultipart(ctx context.Context, name, contentType string, r io.Reader, opts ...PutOption) (string, error) {
+	if name == "" {
+		return "", cerr.ErrFuncArg{}.Invalidate("name")
+	}
+	if r == nil {
+		return "", cerr.ErrFuncArg{}.Invalidate("r")
+	}
+	putOpts := newPutOptions(opts)
+	enc, err := awsConn.writeEncryption(putOpts)
+	if err != nil {
+		return "", err
+	}
+	contentType, body, err := awsConn.policy.checkStream(name, contentType, r)
+	if err != nil {
+		return "", err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	uniqueFileName, err := awsConn.objectKey(ctx, name, nil, putOpts)
+	if err != nil {
+		return "", err
+	}
+	ctx = withTransfer(ctx, awsConn.newTransfer(uniqueFileName, -1, putOpts.progress, putOpts.limiter))
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &uniqueFileName,
+	}
+	putOpts.headers(contentType, name).applyToCreateMultipartUpload(input)
+	sse := enc.headers()
+	sse.applyToCreateMultipartUpload(input)
+	var created *s3.CreateMultipartUploadOutput
+	err = awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		created, err = awsConn.svc.CreateMultipartUploadWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return "", newS3Error("CreateMultipartUpload", awsConn.AWSInfo.Bucket, uniqueFileName, err)
+	}
+
+	parts, partMD5s, err := awsConn.uploadParts(ctx, uniqueFileName, created.UploadId, body, sse)
+	if err != nil {
+		awsConn.abortMultipartUpload(uniqueFileName, created.UploadId)
+		return "", err
+	}
+
+	var completed *s3.CompleteMultipartUploadOutput
+	err = awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		completed, err = awsConn.svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          &awsConn.AWSInfo.Bucket,
+			Key:             &uniqueFileName,
+			UploadId:        created.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+		return err
+	})
+	if err != nil {
+		awsConn.abortMultipartUpload(uniqueFileName, created.UploadId)
+		return "", newS3Error("CompleteMultipartUpload", awsConn.AWSInfo.Bucket, uniqueFileName, err)
+	}
+
+	if etagIsMD5(enc.Mode) && strings.Trim(aws.StringValue(completed.ETag), `"`) != compositeETag(partMD5s) {
+		awsConn.removeDetached(uniqueFileName)
+		return "", ErrChecksumMismatch
+	}
+
+	return uniqueFileName, nil
+}
+
+// uploadParts reads r by parts and uploads them by a pool of workers.
+// Returns completed parts and binary MD5s of them sorted by part number
+func (awsConn *AWSConnector) uploadParts(ctx context.Context, key string, uploadID *string, r io.Reader, sse sseHeaders) ([]*s3.CompletedPart, [][]byte, error) {
+	cfg := awsConn.multipart.withDefaults()
+
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+
+	type partJob struct {
+		number     int64
+		body       []byte
+		contentMD5 *string
+	}
+
+	var (
+		jobs     = make(chan partJob)
+		parts    []*s3.CompletedPart
+		partMD5s [][]byte
+		firstErr error
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancelFn()
+		}
+		mu.Unlock()
+	}
+
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				input := &s3.UploadPartInput{
+					Bucket:        &awsConn.AWSInfo.Bucket,
+					Key:           &key,
+					UploadId:      uploadID,
+					PartNumber:    aws.Int64(job.number),
+					ContentLength: aws.Int64(int64(len(job.body))),
+					ContentMD5:    job.contentMD5,
+				}
+				sse.applyToUploadPart(input)
+				var out *s3.UploadPartOutput
+				err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+					input.Body = bytes.NewReader(job.body)
+					out, err = awsConn.svc.UploadPartWithContext(ctx, input)
+					return err
+				})
+				if err != nil {
+					fail(newS3Error("UploadPart", awsConn.AWSInfo.Bucket, key, err))
+					continue
+				}
+				mu.Lock()
+				parts = append(parts, &s3.CompletedPart{ETag: out.ETag, PartNumber: aws.Int64(job.number)})
+				mu.Unlock()
+			}
+		}()
+	}
+
+readLoop:
+	for number := int64(1); ; number++ {
+		buf := make([]byte, cfg.PartSize)
+		n, err := readPart(r, buf)
+		if err != nil && err != io.EOF {
+			// the part is incomplete, the body is cut off
+			fail(err)
+			break
+		}
+		// the first part is sent even if body is empty, S3 can't complete an upload without parts
+		if n > 0 || number == 1 {
+			if number > maxPartsCount {
+				fail(ErrTooManyParts)
+				break
+			}
+			contentMD5, sum := contentMD5Of(buf[:n])
+			partMD5s = append(partMD5s, sum)
+			select {
+			case jobs <- partJob{number: number, body: buf[:n], contentMD5: contentMD5}:
+			case <-ctx.Done():
+				fail(ctx.Err())
+				break readLoop
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+	return parts, partMD5s, nil
+}
+
+// readPart fills buf from r. Unlike io.ReadFull it returns io.EOF only when r ends cleanly,
+// so io.ErrUnexpectedEOF of a cut-off source is not taken for the end of body
+func readPart(r io.Reader, buf []byte) (int, error) {
+	var (
+		n   int
+		err error
+	)
+	for n < len(buf) && err == nil {
+		var read int
+		read, err = r.Read(buf[n:])
+		n += read
+	}
+	if n == len(buf) && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// abortMultipartUpload removes uploaded parts. It uses own context,
+// because context of the upload may be already cancelled
+func (awsConn *AWSConnector) abortMultipartUpload(key string, uploadID *string) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), awsConn.timeout)
+	defer cancelFn()
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   &awsConn.AWSInfo.Bucket,
+			Key:      &key,
+			UploadId: uploadID,
+		})
+	})
+	if err != nil {
+		log.Errorf("failed to abort multipart upload %s of %s: %v", aws.StringValue(uploadID), key, err)
+	}
+}
+
+// removeDetached removes object left by failed upload, e.g. corrupted object or reference of deduplicated content.
+// It uses own context, because context of the upload may be already expired
+func (awsConn *AWSConnector) removeDetached(key string) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), awsConn.timeout)
+	defer cancelFn()
+
+	if err := awsConn.deleteObject(ctx, key); err != nil {
+		log.Errorf("failed to remove %s left by failed upload: %v", key, err)
+	}
+}
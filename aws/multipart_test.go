@@ -0,0 +1,242 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestClientStatusUpdater_WithMultipartConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		cfg     MultipartConfig
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when part size is too small",
+			cfg:     MultipartConfig{PartSize: 1024},
+			wantErr: ErrInvalidPartSize,
+		},
+		{
+			desc:    "Should returns error when concurrency is negative",
+			cfg:     MultipartConfig{Concurrency: -1},
+			wantErr: ErrInvalidPartsConcurrency,
+		},
+		{
+			desc:    "Should returns no error",
+			cfg:     MultipartConfig{PartSize: MinPartSize * 2, Concurrency: 2},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			_, gotErr := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl), WithMultipartConfig(c.cfg))
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutMultipart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	body := bytes.Repeat([]byte("a"), MinPartSize+10)
+	firstMD5, lastMD5 := md5.Sum(body[:MinPartSize]), md5.Sum(body[MinPartSize:])
+	compositeMD5 := md5.Sum(append(firstMD5[:], lastMD5[:]...))
+	etag := fmt.Sprintf(`"%x-2"`, compositeMD5)
+	generator := func(m *MockiGenerate) *MockiGenerate {
+		m.EXPECT().GenerateTime().Return("time")
+		m.EXPECT().GenerateUUID().Return("111")
+		return m
+	}
+
+	// arrange
+	cases := []struct {
+		desc               string
+		svc                *MockiS3Client
+		generator          *MockiGenerate
+		wantUniqueFileName string
+		wantErr            error
+	}{
+		{
+			desc:      "Should returns error when CreateMultipartUploadWithContext failed",
+			generator: generator(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "",
+			wantErr: S3Error{
+				Operation: "CreateMultipartUpload",
+				Bucket:    "test bucket",
+				Key:       "time_111_img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc:      "Should returns error and aborts upload when UploadPartWithContext failed",
+			generator: generator(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil)
+				m.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("test error")).MinTimes(1).MaxTimes(2)
+				m.EXPECT().AbortMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.AbortMultipartUploadInput) error {
+						assert.Equal(t, "upload", *input.UploadId)
+						return nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "",
+			wantErr: S3Error{
+				Operation: "UploadPart",
+				Bucket:    "test bucket",
+				Key:       "time_111_img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc:      "Should returns error and aborts upload when CompleteMultipartUploadWithContext failed",
+			generator: generator(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil)
+				m.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.UploadPartOutput{ETag: aws.String("etag")}, nil).Times(2)
+				m.EXPECT().CompleteMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("test error"))
+				m.EXPECT().AbortMultipartUploadWithContext(gomock.Any(), gomock.Any()).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "",
+			wantErr: S3Error{
+				Operation: "CompleteMultipartUpload",
+				Bucket:    "test bucket",
+				Key:       "time_111_img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc:      "Should returns no error and completes parts in order",
+			generator: generator(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil)
+				m.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+						if *input.PartNumber == 1 {
+							assert.Equal(t, int64(MinPartSize), *input.ContentLength)
+							assert.Equal(t, base64.StdEncoding.EncodeToString(firstMD5[:]), *input.ContentMD5)
+						} else {
+							assert.Equal(t, int64(10), *input.ContentLength)
+							assert.Equal(t, base64.StdEncoding.EncodeToString(lastMD5[:]), *input.ContentMD5)
+						}
+						return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+					}).Times(2)
+				m.EXPECT().CompleteMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+						assert.Len(t, input.MultipartUpload.Parts, 2)
+						assert.Equal(t, int64(1), *input.MultipartUpload.Parts[0].PartNumber)
+						assert.Equal(t, int64(2), *input.MultipartUpload.Parts[1].PartNumber)
+						return &s3.CompleteMultipartUploadOutput{ETag: aws.String(etag)}, nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "time_111_img.png",
+			wantErr:            nil,
+		},
+		{
+			desc:      "Should returns error and removes object when ETag doesn't match parts",
+			generator: generator(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil)
+				m.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.UploadPartOutput{ETag: aws.String("etag")}, nil).Times(2)
+				m.EXPECT().CompleteMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					Return(&s3.CompleteMultipartUploadOutput{ETag: aws.String(`"0123456789abcdef0123456789abcdef-2"`)}, nil)
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), &s3.DeleteObjectInput{
+					Bucket: aws.String("test bucket"),
+					Key:    aws.String("time_111_img.png"),
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "",
+			wantErr:            ErrChecksumMismatch,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, c.generator, WithMultipartConfig(MultipartConfig{Concurrency: 2}))
+			got, gotErr := aws.PutMultipart(context.Background(), "img.png", "image/png", bytes.NewReader(body))
+
+			// assert
+			assert.Equal(t, c.wantUniqueFileName, got)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutMultipartCutOffBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+		Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil)
+	svc.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).
+		Return(&s3.UploadPartOutput{ETag: aws.String("etag")}, nil)
+	svc.EXPECT().AbortMultipartUploadWithContext(gomock.Any(), gomock.Any()).Return(nil)
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+	body := io.MultiReader(bytes.NewReader(bytes.Repeat([]byte("a"), MinPartSize+10)), iotest.ErrReader(io.ErrUnexpectedEOF))
+
+	// actual
+	got, gotErr := aws.PutMultipart(context.Background(), "img.png", "image/png", body)
+
+	// assert
+	assert.Empty(t, got)
+	assert.Equal(t, io.ErrUnexpectedEOF, gotErr)
+}
@@ -0,0 +1,264 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"net/url"
+	"strings"
+)
+
+// maxDeleteObjects is the max count of keys in one DeleteObjects request
+const maxDeleteObjects = 1000
+
+// KeyError describes failure of an operation with one key
+type KeyError struct {
+	Key string
+	// VersionID is set when the operation was applied to the version of the key
+	VersionID string
+	Code      string
+	Message   string
+}
+
+// ErrBatch aggregates failures of a batch operation by keys
+type ErrBatch struct {
+	Operation string
+	Keys      []KeyError
+}
+
+// Error returns error's string
+func (e ErrBatch) Error() string {
+	var errStr strings.Builder
+	errStr.WriteString(fmt.Sprintf("%s failed for %d keys: ", e.Operation, len(e.Keys)))
+	for i := range e.Keys {
+		errStr.WriteString(fmt.Sprintf("%s (%s: %s)", e.Keys[i].Key, e.Keys[i].Code, e.Keys[i].Message))
+		if i != (len(e.Keys) - 1) {
+			errStr.WriteString(", ")
+		}
+	}
+	return errStr.String()
+}
+
+// ObjectRef points to object in aws. Empty Bucket means bucket of AWSConnector,
+// empty VersionID means current version
+type ObjectRef struct {
+	Bucket    string
+	Key       string
+	VersionID string
+}
+
+func (awsConn *AWSConnector) bucketOf(ref ObjectRef) string {
+	if ref.Bucket == "" {
+		return awsConn.AWSInfo.Bucket
+	}
+	return ref.Bucket
+}
+
+// Delete removes the object stored by key. Deleting a missing key is not an error.
+// Deduplicated content is removed only when its last reference is removed, see DedupConfig
+func (awsConn *AWSConnector) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return cerr.ErrFuncArg{}.Invalidate("key")
+	}
+	if awsConn.isDedupKey(key) {
+		return awsConn.release(ctx, key)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	return awsConn.deleteObject(ctx, key)
+}
+
+func (awsConn *AWSConnector) deleteObject(ctx context.Context, key string) error {
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: &awsConn.AWSInfo.Bucket,
+			Key:    &key,
+		})
+	})
+	if err != nil {
+		return newS3Error("DeleteObject", awsConn.AWSInfo.Bucket, key, err)
+	}
+	return nil
+}
+
+// DeleteMany removes objects by keys in batches of 1000 keys.
+// Deduplicated content is released one by one the same way as by Delete.
+// Returns ErrBatch with every key which was not deleted
+func (awsConn *AWSConnector) DeleteMany(ctx context.Context, keys []string) error {
+	var (
+		objects  = make([]*s3.ObjectIdentifier, 0, len(keys))
+		released []KeyError
+	)
+	for i := range keys {
+		if !awsConn.isDedupKey(keys[i]) {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(keys[i])})
+			continue
+		}
+		if err := awsConn.release(ctx, keys[i]); err != nil {
+			keyErr := KeyError{Key: keys[i], Message: err.Error()}
+			var s3Err S3Error
+			if errors.As(err, &s3Err) {
+				keyErr.Code = s3Err.Code
+			}
+			released = append(released, keyErr)
+		}
+	}
+
+	err := awsConn.deleteObjects(ctx, "delete", objects)
+	if len(released) == 0 {
+		return err
+	}
+	batchErr := ErrBatch{Operation: "delete"}
+	errors.As(err, &batchErr)
+	batchErr.Keys = append(released, batchErr.Keys...)
+	return batchErr
+}
+
+// deleteObjects removes objects in batches of 1000, operation is name of ErrBatch
+func (awsConn *AWSConnector) deleteObjects(ctx context.Context, operation string, objects []*s3.ObjectIdentifier) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	batchErr := ErrBatch{Operation: operation}
+	for start := 0; start < len(objects); start += maxDeleteObjects {
+		end := start + maxDeleteObjects
+		if end > len(objects) {
+			end = len(objects)
+		}
+		batchErr.Keys = append(batchErr.Keys, awsConn.deleteChunk(ctx, objects[start:end])...)
+	}
+
+	if len(batchErr.Keys) != 0 {
+		return batchErr
+	}
+	return nil
+}
+
+func (awsConn *AWSConnector) deleteChunk(ctx context.Context, objects []*s3.ObjectIdentifier) []KeyError {
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	var out *s3.DeleteObjectsOutput
+	err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		out, err = awsConn.svc.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: &awsConn.AWSInfo.Bucket,
+			Delete: &s3.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		return err
+	})
+	if err != nil {
+		s3Err := newS3Error("DeleteObjects", awsConn.AWSInfo.Bucket, "", err)
+		keyErrs := make([]KeyError, len(objects))
+		for i := range objects {
+			keyErrs[i] = KeyError{
+				Key:       aws.StringValue(objects[i].Key),
+				VersionID: aws.StringValue(objects[i].VersionId),
+				Code:      s3Err.Code,
+				Message:   s3Err.Error(),
+			}
+		}
+		return keyErrs
+	}
+
+	keyErrs := make([]KeyError, 0, len(out.Errors))
+	for _, e := range out.Errors {
+		keyErrs = append(keyErrs, KeyError{
+			Key:       aws.StringValue(e.Key),
+			VersionID: aws.StringValue(e.VersionId),
+			Code:      aws.StringValue(e.Code),
+			Message:   aws.StringValue(e.Message),
+		})
+	}
+	return keyErrs
+}
+
+// Copy copies object from src to dst, buckets of src and dst may differ.
+// dst is encrypted the same way as other writes, opts except encryption are ignored.
+// Returns S3Error which is cerr.ErrNotFound when there is no src object and ErrReservedKey
+// when dst is under prefixes of deduplication
+func (awsConn *AWSConnector) Copy(ctx context.Context, src, dst ObjectRef, opts ...PutOption) error {
+	if src.Key == "" {
+		return cerr.ErrFuncArg{}.Invalidate("src")
+	}
+	if dst.Key == "" {
+		return cerr.ErrFuncArg{}.Invalidate("dst")
+	}
+	if awsConn.bucketOf(dst) == awsConn.AWSInfo.Bucket && awsConn.isReservedKey(dst.Key) {
+		return ErrReservedKey
+	}
+	enc, err := awsConn.writeEncryption(newPutOptions(opts))
+	if err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	copySource := url.PathEscape(awsConn.bucketOf(src) + "/" + src.Key)
+	if src.VersionID != "" {
+		copySource += "?versionId=" + url.QueryEscape(src.VersionID)
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(awsConn.bucketOf(dst)),
+		Key:        aws.String(dst.Key),
+		CopySource: aws.String(copySource),
+	}
+	enc.headers().applyToCopyObject(input)
+	input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey = customerKeyHeaders(awsConn.readCustomerKey(nil))
+
+	err = awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.CopyObjectWithContext(ctx, input)
+	})
+	if err != nil {
+		return newS3Error("CopyObject", awsConn.bucketOf(src), src.Key, err)
+	}
+	return nil
+}
+
+// Move copies object from src to dst and then removes src,
+// when src.VersionID is set only that version is removed. Deduplicated content can't be moved
+func (awsConn *AWSConnector) Move(ctx context.Context, src, dst ObjectRef, opts ...PutOption) error {
+	// deduplicated content is shared by references, so it's removed only by Delete
+	if awsConn.bucketOf(src) == awsConn.AWSInfo.Bucket && awsConn.isReservedKey(src.Key) {
+		return ErrReservedKey
+	}
+	if err := awsConn.Copy(ctx, src, dst, opts...); err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(awsConn.bucketOf(src)),
+		Key:    aws.String(src.Key),
+	}
+	if src.VersionID != "" {
+		input.VersionId = aws.String(src.VersionID)
+	}
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.DeleteObjectWithContext(ctx, input)
+	})
+	if err != nil {
+		return newS3Error("DeleteObject", awsConn.bucketOf(src), src.Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_Delete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		key     string
+		svc     *MockiS3Client
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when key is empty",
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: cerr.NewErrFuncArgMock("key", "Delete"),
+		},
+		{
+			desc: "Should returns error when DeleteObjectWithContext failed",
+			key:  "key",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "DeleteObject",
+				Bucket:    "test bucket",
+				Key:       "key",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc: "Should returns no error",
+			key:  "key",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), gomock.Any()).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := aws.Delete(context.Background(), c.key)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_DeleteMany(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	keys := make([]string, maxDeleteObjects+1)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key", i)
+	}
+
+	svc := NewMockiS3Client(ctrl)
+	gomock.InOrder(
+		svc.EXPECT().DeleteObjectsWithContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+				assert.Len(t, input.Delete.Objects, maxDeleteObjects)
+				return &s3.DeleteObjectsOutput{
+					Errors: []*s3.Error{
+						{Key: aws.String("key1"), Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")},
+					},
+				}, nil
+			}),
+		svc.EXPECT().DeleteObjectsWithContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+				assert.Len(t, input.Delete.Objects, 1)
+				return nil, errors.New("test error")
+			}),
+	)
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	// actual
+	gotErr := awsConn.DeleteMany(context.Background(), keys)
+
+	// assert
+	assert.Equal(t, ErrBatch{
+		Operation: "delete",
+		Keys: []KeyError{
+			{Key: "key1", Code: "AccessDenied", Message: "Access Denied"},
+			{Key: "key1000", Message: "AWS returned error, DeleteObjects test bucket failed: test error"},
+		},
+	}, gotErr)
+}
+
+func TestClientStatusUpdater_Move(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc      string
+		versionID string
+		svc       *MockiS3Client
+		wantErr   error
+	}{
+		{
+			desc: "Should returns cerr.ErrNotFound when there is no src object",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CopyObjectWithContext(gomock.Any(), gomock.Any()).
+					Return(awserr.New(s3.ErrCodeNoSuchKey, "no such key", nil))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: cerr.ErrNotFound,
+		},
+		{
+			desc: "Should returns error when DeleteObjectWithContext failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CopyObjectWithContext(gomock.Any(), gomock.Any()).Return(nil)
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "DeleteObject",
+				Bucket:    "test-bucket",
+				Key:       "dir/img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc: "Should copy to other bucket and delete src",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CopyObjectWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput) error {
+						assert.Equal(t, "archive", *input.Bucket)
+						assert.Equal(t, "old/img.png", *input.Key)
+						assert.Equal(t, "test-bucket%2Fdir%2Fimg.png", *input.CopySource)
+						return nil
+					})
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.DeleteObjectInput) error {
+						assert.Equal(t, "test-bucket", *input.Bucket)
+						assert.Equal(t, "dir/img.png", *input.Key)
+						return nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc:      "Should delete only copied version of src",
+			versionID: "v1",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CopyObjectWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput) error {
+						assert.Equal(t, "test-bucket%2Fdir%2Fimg.png?versionId=v1", *input.CopySource)
+						return nil
+					})
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.DeleteObjectInput) error {
+						assert.Equal(t, "dir/img.png", *input.Key)
+						assert.Equal(t, "v1", aws.StringValue(input.VersionId))
+						return nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test-bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := aws.Move(context.Background(), ObjectRef{Key: "dir/img.png", VersionID: c.versionID}, ObjectRef{Bucket: "archive", Key: "old/img.png"})
+
+			// assert
+			if !errors.Is(gotErr, c.wantErr) {
+				assert.Equal(t, c.wantErr, gotErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package aws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxPresignExpire is the longest expiry allowed by aws signature v4
+	MaxPresignExpire = 7 * 24 * time.Hour
+
+	signAlgorithm   = "AWS4-HMAC-SHA256"
+	signDateFormat  = "20060102"
+	signTimeFormat  = "20060102T150405Z"
+	signServiceName = "s3"
+)
+
+const (
+	// ErrInvalidExpire is error, which is returned when expiry is not positive or is longer than MaxPresignExpire
+	ErrInvalidExpire = cerr.New("expire must be positive and not longer than 7 days")
+
+	// ErrInvalidSizeRange is error, which is returned when content length range of post policy is invalid
+	ErrInvalidSizeRange = cerr.New("invalid content length range")
+
+	// ErrEmptyRegion is error, which is returned when AWSInfo.Region is needed but empty
+	ErrEmptyRegion = cerr.New("aws region is empty")
+)
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+// PostPolicyConditions restricts what browser is allowed to upload with post policy
+type PostPolicyConditions struct {
+	// ContentType is exact content type of the file, it is sent as a form field
+	ContentType string
+	// ContentTypePrefix allows any content type with such prefix, e.g. "image/".
+	// Browser has to send Content-Type form field itself
+	ContentTypePrefix string
+	// MinSize and MaxSize are bounds of file size in bytes, MaxSize 0 means no bounds
+	MinSize int64
+	MaxSize int64
+}
+
+// PostPolicy contains everything browser needs for direct upload with html form
+type PostPolicy struct {
+	// URL is form action
+	URL string
+	// Key is key of the object after upload
+	Key string
+	// Fields are form fields which must be sent before the file field
+	Fields  map[string]string
+	Expires time.Time
+}
+
+// PresignPutURL generates key the same way as PutFile and returns it with
+// url which can be used for uploading the file by http PUT until expire passes.
+// contentType, if it's not empty, must be sent with the same value in Content-Type header,
+// the same is true for encryption headers. ctx is passed to key strategy
+func (awsConn *AWSConnector) PresignPutURL(ctx context.Context, name, contentType string, expire time.Duration, opts ...PutOption) (string, string, error) {
+	if name == "" {
+		return "", "", cerr.ErrFuncArg{}.Invalidate("name")
+	}
+	if expire <= 0 || expire > MaxPresignExpire {
+		return "", "", ErrInvalidExpire
+	}
+	if err := awsConn.policy.checkName(name); err != nil {
+		return "", "", err
+	}
+	if contentType != "" {
+		if err := awsConn.policy.checkType(contentType); err != nil {
+			return "", "", err
+		}
+	}
+
+	putOpts := newPutOptions(opts)
+	uniqueFileName, err := awsConn.objectKey(ctx, name, nil, putOpts)
+	if err != nil {
+		return "", "", err
+	}
+	input := &s3.PutObjectInput{
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &uniqueFileName,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	enc, err := awsConn.writeEncryption(putOpts)
+	if err != nil {
+		return "", "", err
+	}
+	enc.headers().applyToPutObject(input)
+
+	url, err := awsConn.svc.PresignPutObject(input, expire)
+	if err != nil {
+		return "", "", newS3Error("PresignPutObject", awsConn.AWSInfo.Bucket, uniqueFileName, err)
+	}
+	return uniqueFileName, url, nil
+}
+
+// PresignGetURL returns url which can be used for downloading the object by http GET until expire passes
+func (awsConn *AWSConnector) PresignGetURL(key string, expire time.Duration) (string, error) {
+	if key == "" {
+		return "", cerr.ErrFuncArg{}.Invalidate("key")
+	}
+	if expire <= 0 || expire > MaxPresignExpire {
+		return "", ErrInvalidExpire
+	}
+
+	url, err := awsConn.svc.PresignGetObject(&s3.GetObjectInput{
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &key,
+	}, expire)
+	if err != nil {
+		return "", newS3Error("PresignGetObject", awsConn.AWSInfo.Bucket, key, err)
+	}
+	return url, nil
+}
+
+// PresignPostPolicy generates key the same way as PutFile and signs post policy
+// which allows browser to upload the file with html form until expire passes.
+// SSEC encryption is not supported, because customer key would be sent to browser. ctx is passed to key strategy.
+// Upload policy is checked against name and conditions: when policy restricts types, cond must restrict content type
+// within them, and MaxSize of cond must not exceed MaxSize of policy, which is used when cond has no bounds
+func (awsConn *AWSConnector) PresignPostPolicy(ctx context.Context, name string, expire time.Duration, cond PostPolicyConditions, opts ...PutOption) (PostPolicy, error) {
+	if name == "" {
+		return PostPolicy{}, cerr.ErrFuncArg{}.Invalidate("name")
+	}
+	if expire <= 0 || expire > MaxPresignExpire {
+		return PostPolicy{}, ErrInvalidExpire
+	}
+	if err := awsConn.checkPostPolicy(name, &cond); err != nil {
+		return PostPolicy{}, err
+	}
+	if cond.MinSize < 0 || cond.MaxSize < 0 || (cond.MaxSize > 0 && cond.MinSize > cond.MaxSize) {
+		return PostPolicy{}, ErrInvalidSizeRange
+	}
+	if awsConn.AWSInfo.Region == "" {
+		return PostPolicy{}, ErrEmptyRegion
+	}
+	putOpts := newPutOptions(opts)
+	enc, err := awsConn.writeEncryption(putOpts)
+	if err != nil {
+		return PostPolicy{}, err
+	}
+	if enc.Mode == SSEC {
+		return PostPolicy{}, ErrInvalidEncryption
+	}
+
+	creds, err := awsConn.svc.Credentials()
+	if err != nil {
+		return PostPolicy{}, newS3Error("Credentials", awsConn.AWSInfo.Bucket, "", err)
+	}
+
+	uniqueFileName, err := awsConn.objectKey(ctx, name, nil, putOpts)
+	if err != nil {
+		return PostPolicy{}, err
+	}
+
+	now := timeNow().UTC()
+	expires := now.Add(expire)
+	credential := fmt.Sprintf("%s/%s/%s/%s/aws4_request", creds.AccessKeyID, now.Format(signDateFormat), awsConn.AWSInfo.Region, signServiceName)
+
+	fields := map[string]string{
+		"key":              uniqueFileName,
+		"x-amz-algorithm":  signAlgorithm,
+		"x-amz-credential": credential,
+		"x-amz-date":       now.Format(signTimeFormat),
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+	if cond.ContentType != "" {
+		fields["Content-Type"] = cond.ContentType
+	}
+	sse := enc.headers()
+	if sse.serverSideEncryption != nil {
+		fields["x-amz-server-side-encryption"] = *sse.serverSideEncryption
+	}
+	if sse.kmsKeyID != nil {
+		fields["x-amz-server-side-encryption-aws-kms-key-id"] = *sse.kmsKeyID
+	}
+	if sse.kmsContext != nil {
+		fields["x-amz-server-side-encryption-context"] = *sse.kmsContext
+	}
+
+	conditions := []interface{}{
+		map[string]string{"bucket": awsConn.AWSInfo.Bucket},
+	}
+	fieldNames := make([]string, 0, len(fields))
+	for field := range fields {
+		fieldNames = append(fieldNames, field)
+	}
+	sort.Strings(fieldNames)
+	for _, field := range fieldNames {
+		conditions = append(conditions, map[string]string{field: fields[field]})
+	}
+	if cond.ContentTypePrefix != "" && cond.ContentType == "" {
+		conditions = append(conditions, []string{"starts-with", "$Content-Type", cond.ContentTypePrefix})
+	}
+	if cond.MaxSize > 0 {
+		conditions = append(conditions, []interface{}{"content-length-range", cond.MinSize, cond.MaxSize})
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": expires.Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return PostPolicy{}, err
+	}
+	encodedPolicy := base64.StdEncoding.EncodeToString(policy)
+
+	fields["policy"] = encodedPolicy
+	fields["x-amz-signature"] = signV4(creds.SecretAccessKey, awsConn.AWSInfo.Region, now, encodedPolicy)
+
+	return PostPolicy{
+		URL:     strings.TrimSuffix(awsConn.svc.Endpoint(), "/") + "/" + awsConn.AWSInfo.Bucket,
+		Key:     uniqueFileName,
+		Fields:  fields,
+		Expires: expires,
+	}, nil
+}
+
+// checkPostPolicy checks name and conditions of post policy by upload policy, cond without bounds gets MaxSize of policy
+func (awsConn *AWSConnector) checkPostPolicy(name string, cond *PostPolicyConditions) error {
+	policy := awsConn.policy
+	if err := policy.checkName(name); err != nil {
+		return err
+	}
+	switch {
+	case cond.ContentType != "":
+		if err := policy.checkType(cond.ContentType); err != nil {
+			return err
+		}
+	case cond.ContentTypePrefix != "":
+		if err := policy.checkTypePrefix(cond.ContentTypePrefix); err != nil {
+			return err
+		}
+	case len(policy.AllowedTypes) != 0:
+		return ErrPolicy{Reason: ErrTypeNotAllowed, Detail: "content type is not restricted"}
+	}
+	if policy.MaxSize > 0 && cond.MaxSize == 0 {
+		cond.MaxSize = policy.MaxSize
+	}
+	return policy.checkSize(cond.MaxSize)
+}
+
+// signV4 signs stringToSign with key derived from secret by aws signature v4 rules
+func signV4(secret, region string, t time.Time, stringToSign string) string {
+	key := hmacSHA256([]byte("AWS4"+secret), t.Format(signDateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, signServiceName)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,319 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_PresignPutURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc               string
+		expire             time.Duration
+		svc                *MockiS3Client
+		generator          *MockiGenerate
+		wantUniqueFileName string
+		wantURL            string
+		wantErr            error
+	}{
+		{
+			desc:      "Should returns error when expire is too long",
+			expire:    MaxPresignExpire + time.Second,
+			svc:       NewMockiS3Client(ctrl),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrInvalidExpire,
+		},
+		{
+			desc:   "Should returns error when PresignPutObject failed",
+			expire: time.Hour,
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			}(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().PresignPutObject(gomock.Any(), time.Hour).Return("", errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{
+				Operation: "PresignPutObject",
+				Bucket:    "test bucket",
+				Key:       "time_111_img.png",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc:   "Should returns no error, key and url",
+			expire: time.Hour,
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			}(NewMockiGenerate(ctrl)),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().PresignPutObject(gomock.Any(), time.Hour).Return("https://signed", nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantUniqueFileName: "time_111_img.png",
+			wantURL:            "https://signed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, c.generator)
+			gotKey, gotURL, gotErr := aws.PresignPutURL(context.Background(), "img.png", "image/png", c.expire)
+
+			// assert
+			assert.Equal(t, c.wantUniqueFileName, gotKey)
+			assert.Equal(t, c.wantURL, gotURL)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PresignGetURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		expire  time.Duration
+		svc     *MockiS3Client
+		wantURL string
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when expire is not positive",
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: ErrInvalidExpire,
+		},
+		{
+			desc:   "Should returns no error and url",
+			expire: time.Minute,
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().PresignGetObject(gomock.Any(), time.Minute).Return("https://signed", nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantURL: "https://signed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotURL, gotErr := aws.PresignGetURL("time_111_img.png", c.expire)
+
+			// assert
+			assert.Equal(t, c.wantURL, gotURL)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PresignPostPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().Credentials().Return(credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret"}, nil)
+	svc.EXPECT().Endpoint().Return("https://s3.eu-west-1.amazonaws.com")
+
+	awsInfo := AWSInfo{
+		Bucket: "bucket",
+		URL:    "test URL",
+		Region: "eu-west-1",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+
+	// actual
+	got, gotErr := awsConn.PresignPostPolicy(context.Background(), "img.png", time.Hour, PostPolicyConditions{
+		ContentTypePrefix: "image/",
+		MaxSize:           1024,
+	})
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Equal(t, "https://s3.eu-west-1.amazonaws.com/bucket", got.URL)
+	assert.Equal(t, "time_111_img.png", got.Key)
+	assert.Equal(t, now.Add(time.Hour), got.Expires)
+	assert.Equal(t, "id/20261017/eu-west-1/s3/aws4_request", got.Fields["x-amz-credential"])
+	assert.Equal(t, signV4("secret", "eu-west-1", now, got.Fields["policy"]), got.Fields["x-amz-signature"])
+
+	rawPolicy, err := base64.StdEncoding.DecodeString(got.Fields["policy"])
+	assert.NoError(t, err)
+	var policy struct {
+		Expiration string        `json:"expiration"`
+		Conditions []interface{} `json:"conditions"`
+	}
+	assert.NoError(t, json.Unmarshal(rawPolicy, &policy))
+	assert.Equal(t, "2026-10-17T13:00:00.000Z", policy.Expiration)
+	assert.Contains(t, policy.Conditions, []interface{}{"starts-with", "$Content-Type", "image/"})
+	assert.Contains(t, policy.Conditions, []interface{}{"content-length-range", float64(0), float64(1024)})
+	assert.Contains(t, policy.Conditions, map[string]interface{}{"key": "time_111_img.png"})
+}
+
+func TestClientStatusUpdater_PresignKeyPrefix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time").Times(2)
+	generator.EXPECT().GenerateUUID().Return("111").Times(2)
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().PresignPutObject(gomock.Any(), time.Hour).Return("https://signed", nil)
+	svc.EXPECT().Credentials().Return(credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret"}, nil)
+	svc.EXPECT().Endpoint().Return("https://s3.eu-west-1.amazonaws.com")
+
+	awsInfo := AWSInfo{
+		Bucket: "bucket",
+		URL:    "test URL",
+		Region: "eu-west-1",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator, WithKeyStrategy(ContextPrefixKeys(DefaultKeys)))
+	ctx := WithKeyPrefix(context.Background(), "tenant")
+
+	// actual
+	putKey, _, putErr := awsConn.PresignPutURL(ctx, "img.png", "image/png", time.Hour)
+	post, postErr := awsConn.PresignPostPolicy(ctx, "img.png", time.Hour, PostPolicyConditions{})
+
+	// assert
+	assert.NoError(t, putErr)
+	assert.NoError(t, postErr)
+	assert.Equal(t, "tenant/time_111_img.png", putKey)
+	assert.Equal(t, "tenant/time_111_img.png", post.Key)
+}
+
+func TestClientStatusUpdater_PresignPostPolicyUploadPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	policy := UploadPolicy{MaxSize: 1024, AllowedTypes: []string{"image/*", "application/pdf"}, AllowedExtensions: []string{".png", ".pdf"}}
+
+	// arrange
+	cases := []struct {
+		desc      string
+		name      string
+		cond      PostPolicyConditions
+		svc       *MockiS3Client
+		generator *MockiGenerate
+		wantRange []interface{}
+		wantErr   error
+	}{
+		{
+			desc:      "Should returns error when extension is not allowed",
+			name:      "script.js",
+			cond:      PostPolicyConditions{ContentType: "image/png"},
+			svc:       NewMockiS3Client(ctrl),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrPolicy{Reason: ErrExtensionNotAllowed, Detail: `".js"`},
+		},
+		{
+			desc:      "Should returns error when content type is not allowed",
+			name:      "img.png",
+			cond:      PostPolicyConditions{ContentType: "text/html"},
+			svc:       NewMockiS3Client(ctrl),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrPolicy{Reason: ErrTypeNotAllowed, Detail: `"text/html"`},
+		},
+		{
+			desc:      "Should returns error when content type prefix is wider than allowed types",
+			name:      "img.png",
+			cond:      PostPolicyConditions{ContentTypePrefix: "application/"},
+			svc:       NewMockiS3Client(ctrl),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrPolicy{Reason: ErrTypeNotAllowed, Detail: `prefix "application/"`},
+		},
+		{
+			desc:      "Should returns error when content type is not restricted",
+			name:      "img.png",
+			svc:       NewMockiS3Client(ctrl),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrPolicy{Reason: ErrTypeNotAllowed, Detail: "content type is not restricted"},
+		},
+		{
+			desc:      "Should returns error when size range is larger than allowed",
+			name:      "img.png",
+			cond:      PostPolicyConditions{ContentTypePrefix: "image/", MaxSize: 2048},
+			svc:       NewMockiS3Client(ctrl),
+			generator: NewMockiGenerate(ctrl),
+			wantErr:   ErrPolicy{Reason: ErrFileTooLarge, Detail: "2048 bytes, max is 1024"},
+		},
+		{
+			desc: "Should limits size by policy when conditions have no bounds",
+			name: "img.png",
+			cond: PostPolicyConditions{ContentTypePrefix: "image/"},
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().Credentials().Return(credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret"}, nil)
+				m.EXPECT().Endpoint().Return("https://s3.eu-west-1.amazonaws.com")
+				return m
+			}(NewMockiS3Client(ctrl)),
+			generator: func(m *MockiGenerate) *MockiGenerate {
+				m.EXPECT().GenerateTime().Return("time")
+				m.EXPECT().GenerateUUID().Return("111")
+				return m
+			}(NewMockiGenerate(ctrl)),
+			wantRange: []interface{}{"content-length-range", float64(0), float64(1024)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			awsInfo := AWSInfo{
+				Bucket: "bucket",
+				URL:    "test URL",
+				Region: "eu-west-1",
+			}
+			awsConn, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, c.generator, WithUploadPolicy(policy))
+
+			// actual
+			got, gotErr := awsConn.PresignPostPolicy(context.Background(), c.name, time.Hour, c.cond)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+			if gotErr != nil {
+				return
+			}
+			rawPolicy, err := base64.StdEncoding.DecodeString(got.Fields["policy"])
+			require.NoError(t, err)
+			var signed struct {
+				Conditions []interface{} `json:"conditions"`
+			}
+			require.NoError(t, json.Unmarshal(rawPolicy, &signed))
+			assert.Contains(t, signed.Conditions, c.wantRange)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// sniffLen is count of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+const (
+	// DispositionInline makes browsers display the file
+	DispositionInline = "inline"
+	// DispositionAttachment makes browsers download the file
+	DispositionAttachment = "attachment"
+)
+
+// PutOption configures one upload
+type PutOption func(opts *putOptions)
+
+type putOptions struct {
+	disposition  string
+	metadata     map[string]string
+	cacheControl string
+	tags         map[string]string
+	encryption   *Encryption
+	key          string
+	progress     ProgressFunc
+	limiter      *BandwidthLimiter
+}
+
+// WithContentDisposition sets Content-Disposition of the object to dispositionType
+// (DispositionInline or DispositionAttachment) with original file name
+func WithContentDisposition(dispositionType string) PutOption {
+	return func(opts *putOptions) {
+		opts.disposition = dispositionType
+	}
+}
+
+// WithMetadata attaches user metadata to the object, aws returns it with x-amz-meta- prefix
+func WithMetadata(metadata map[string]string) PutOption {
+	return func(opts *putOptions) {
+		if opts.metadata == nil {
+			opts.metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			opts.metadata[k] = v
+		}
+	}
+}
+
+// WithCacheControl sets Cache-Control of the object, e.g. "public, max-age=31536000"
+func WithCacheControl(cacheControl string) PutOption {
+	return func(opts *putOptions) {
+		opts.cacheControl = cacheControl
+	}
+}
+
+// WithTags attaches tags to the object
+func WithTags(tags map[string]string) PutOption {
+	return func(opts *putOptions) {
+		if opts.tags == nil {
+			opts.tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			opts.tags[k] = v
+		}
+	}
+}
+
+// WithObjectKey stores the object under key as is, key strategy of AWSConnector is not used
+func WithObjectKey(key string) PutOption {
+	return func(opts *putOptions) {
+		opts.key = key
+	}
+}
+
+func newPutOptions(opts []PutOption) putOptions {
+	var o putOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// objectHeaders are headers shared by all kinds of upload inputs
+type objectHeaders struct {
+	contentType        *string
+	contentDisposition *string
+	cacheControl       *string
+	tagging            *string
+	metadata           map[string]*string
+}
+
+func (o putOptions) headers(contentType, fileName string) objectHeaders {
+	var h objectHeaders
+	if contentType != "" {
+		h.contentType = aws.String(contentType)
+	}
+	if o.disposition != "" {
+		var params map[string]string
+		if fileName != "" {
+			params = map[string]string{"filename": path.Base(strings.ReplaceAll(fileName, `\`, "/"))}
+		}
+		disposition := mime.FormatMediaType(o.disposition, params)
+		if disposition != "" {
+			h.contentDisposition = aws.String(disposition)
+		}
+	}
+	if o.cacheControl != "" {
+		h.cacheControl = aws.String(o.cacheControl)
+	}
+	if len(o.tags) != 0 {
+		tags := url.Values{}
+		for k, v := range o.tags {
+			tags.Set(k, v)
+		}
+		h.tagging = aws.String(tags.Encode())
+	}
+	if len(o.metadata) != 0 {
+		h.metadata = aws.StringMap(o.metadata)
+	}
+	return h
+}
+
+func (h objectHeaders) applyToPutObject(input *s3.PutObjectInput) {
+	input.ContentType = h.contentType
+	input.ContentDisposition = h.contentDisposition
+	input.CacheControl = h.cacheControl
+	input.Tagging = h.tagging
+	input.Metadata = h.metadata
+}
+
+func (h objectHeaders) applyToUpload(input *s3manager.UploadInput) {
+	input.ContentType = h.contentType
+	input.ContentDisposition = h.contentDisposition
+	input.CacheControl = h.cacheControl
+	input.Tagging = h.tagging
+	input.Metadata = h.metadata
+}
+
+func (h objectHeaders) applyToCreateMultipartUpload(input *s3.CreateMultipartUploadInput) {
+	input.ContentType = h.contentType
+	input.ContentDisposition = h.contentDisposition
+	input.CacheControl = h.cacheControl
+	input.Tagging = h.tagging
+	input.Metadata = h.metadata
+}
@@ -0,0 +1,125 @@
+package aws
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_PutFileHeaders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc                   string
+		fileObj                string
+		opts                   []PutOption
+		wantContentType        *string
+		wantContentDisposition *string
+		wantCacheControl       *string
+		wantTagging            *string
+		wantMetadata           map[string]*string
+	}{
+		{
+			desc:            "Should set content type from data url",
+			fileObj:         "name:{img.png},dataUrl:{data:image/png;base64,iVBggg==}",
+			wantContentType: aws.String("image/png"),
+		},
+		{
+			desc:            "Should detect content type when data url has no media type",
+			fileObj:         "name:{img.png},dataUrl:{data:;base64,iVBORw0KGgoAAAANSUhEUg==}",
+			wantContentType: aws.String("image/png"),
+		},
+		{
+			desc:    "Should set disposition, cache control, tags and metadata",
+			fileObj: "name:{мій файл.txt},dataUrl:{data:text/plain;charset=utf-8,hello}",
+			opts: []PutOption{
+				WithContentDisposition(DispositionAttachment),
+				WithCacheControl("max-age=60"),
+				WithTags(map[string]string{"project": "a b", "env": "dev"}),
+				WithMetadata(map[string]string{"owner": "42"}),
+			},
+			wantContentType:        aws.String("text/plain;charset=utf-8"),
+			wantContentDisposition: aws.String("attachment; filename*=utf-8''%D0%BC%D1%96%D0%B9%20%D1%84%D0%B0%D0%B9%D0%BB.txt"),
+			wantCacheControl:       aws.String("max-age=60"),
+			wantTagging:            aws.String("env=dev&project=a+b"),
+			wantMetadata:           map[string]*string{"owner": aws.String("42")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			generator := NewMockiGenerate(ctrl)
+			generator.EXPECT().GenerateTime().Return("time")
+			generator.EXPECT().GenerateUUID().Return("111")
+
+			svc := NewMockiS3Client(ctrl)
+			svc.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3.PutObjectInput) error {
+					assert.Equal(t, c.wantContentType, input.ContentType)
+					assert.Equal(t, c.wantContentDisposition, input.ContentDisposition)
+					assert.Equal(t, c.wantCacheControl, input.CacheControl)
+					assert.Equal(t, c.wantTagging, input.Tagging)
+					assert.NotNil(t, input.ContentMD5)
+					// checksum is added to metadata of every file
+					assert.NotNil(t, input.Metadata[ChecksumMetadataKey])
+					delete(input.Metadata, ChecksumMetadataKey)
+					if len(input.Metadata) == 0 {
+						input.Metadata = nil
+					}
+					assert.Equal(t, c.wantMetadata, input.Metadata)
+					return nil
+				})
+
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+			_, gotErr := aws.PutFile(context.Background(), &c.fileObj, c.opts...)
+
+			// assert
+			assert.NoError(t, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutReaderDetectsContentType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().UploadWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3manager.UploadInput) error {
+			body, err := ioutil.ReadAll(input.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, "%PDF-1.4 body", string(body))
+			assert.Equal(t, "application/pdf", *input.ContentType)
+			return nil
+		})
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+
+	// actual
+	_, gotErr := awsConn.PutReader(context.Background(), "doc.pdf", "", strings.NewReader("%PDF-1.4 body"), -1)
+
+	// assert
+	assert.NoError(t, gotErr)
+}
@@ -0,0 +1,347 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/labstack/gommon/log"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// ErrNoUploadStore is error, which is returned when resumable upload is used without UploadStateStore
+	ErrNoUploadStore = cerr.New("upload state store is not set")
+
+	// ErrIncompleteUpload is error, which is returned when body of resumable upload ends before size of the session
+	ErrIncompleteUpload = cerr.New("upload body is shorter than its size")
+)
+
+// WithUploadStateStore sets store of sessions of resumable uploads
+func WithUploadStateStore(store UploadStateStore) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.uploadStore = store
+	}
+}
+
+// StartUpload starts resumable upload of the object and returns its session, pass session ID to ResumeUpload
+// to upload the body. Key is built the same way as in PutFile, part size is taken from MultipartConfig.
+// size is size of the whole body, the object is completed only when all of it is uploaded.
+// Empty contentType is detected by extension of name.
+// SSE-C key of one write can't be persisted, so only SSE-C key of AWSConnector may be used,
+// ErrInvalidEncryption is returned for WithPutEncryption of SSE-C
+func (awsConn *AWSConnector) StartUpload(ctx context.Context, name, contentType string, size int64, opts ...PutOption) (UploadSession, error) {
+	if awsConn.uploadStore == nil {
+		return UploadSession{}, ErrNoUploadStore
+	}
+	if name == "" {
+		return UploadSession{}, cerr.ErrFuncArg{}.Invalidate("name")
+	}
+	if size < 0 {
+		return UploadSession{}, cerr.ErrFuncArg{}.Invalidate("size")
+	}
+	partSize := awsConn.multipart.withDefaults().PartSize
+	if (size+partSize-1)/partSize > maxPartsCount {
+		return UploadSession{}, ErrTooManyParts
+	}
+	putOpts := newPutOptions(opts)
+	if putOpts.encryption != nil && putOpts.encryption.Mode == SSEC {
+		return UploadSession{}, ErrInvalidEncryption
+	}
+	enc, err := awsConn.writeEncryption(putOpts)
+	if err != nil {
+		return UploadSession{}, err
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err = awsConn.policy.checkName(name); err != nil {
+		return UploadSession{}, err
+	}
+	if err = awsConn.policy.checkType(contentType); err != nil {
+		return UploadSession{}, err
+	}
+	if err = awsConn.policy.checkSize(size); err != nil {
+		return UploadSession{}, err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	uniqueFileName, err := awsConn.objectKey(ctx, name, nil, putOpts)
+	if err != nil {
+		return UploadSession{}, err
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: &awsConn.AWSInfo.Bucket,
+		Key:    &uniqueFileName,
+	}
+	putOpts.headers(contentType, name).applyToCreateMultipartUpload(input)
+	enc.headers().applyToCreateMultipartUpload(input)
+	var created *s3.CreateMultipartUploadOutput
+	err = awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		created, err = awsConn.svc.CreateMultipartUploadWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return UploadSession{}, newS3Error("CreateMultipartUpload", awsConn.AWSInfo.Bucket, uniqueFileName, err)
+	}
+
+	now := timeNow().UTC()
+	session := UploadSession{
+		ID:         awsConn.generator.GenerateUUID(),
+		Key:        uniqueFileName,
+		UploadID:   aws.StringValue(created.UploadId),
+		PartSize:   partSize,
+		Size:       size,
+		Encryption: enc.Mode,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err = awsConn.uploadStore.Save(ctx, session); err != nil {
+		awsConn.abortMultipartUpload(uniqueFileName, created.UploadId)
+		return UploadSession{}, err
+	}
+	return session, nil
+}
+
+// GetUploadSession returns session of resumable upload, error is cerr.ErrNotFound when there is no such session
+func (awsConn *AWSConnector) GetUploadSession(ctx context.Context, id string) (UploadSession, error) {
+	if awsConn.uploadStore == nil {
+		return UploadSession{}, ErrNoUploadStore
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return awsConn.uploadStore.Load(ctx, id)
+}
+
+// ResumeUpload uploads the rest of the body of the session and returns key of the object.
+// r must start at UploadSession.Offset() of the body. Parts are uploaded one by one with Content-MD5
+// and the session is saved after every acknowledged part, so when r or aws fails the upload
+// may be resumed again from the last acknowledged part. r is read up to size of the session, when it ends earlier
+// ErrIncompleteUpload is returned and the session is kept. The session is removed when the object is completed,
+// ETag of the object is verified the same way as in PutMultipart.
+// Timeout of AWSConnector is applied to every part, so the whole body may take longer.
+// Resumes of one session must not overlap, parts of concurrent resumes overwrite each other
+func (awsConn *AWSConnector) ResumeUpload(ctx context.Context, id string, r io.Reader) (string, error) {
+	if awsConn.uploadStore == nil {
+		return "", ErrNoUploadStore
+	}
+	if r == nil {
+		return "", cerr.ErrFuncArg{}.Invalidate("r")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	loadCtx, cancelLoad := context.WithTimeout(ctx, awsConn.timeout)
+	session, err := awsConn.uploadStore.Load(loadCtx, id)
+	cancelLoad()
+	if err != nil {
+		return "", err
+	}
+	var sse sseHeaders
+	if session.Encryption == SSEC {
+		if awsConn.encryption.Mode != SSEC {
+			return "", ErrInvalidEncryption
+		}
+		sse = awsConn.encryption.headers()
+	}
+
+	if err = awsConn.uploadSessionParts(ctx, &session, r, sse); err != nil {
+		return "", err
+	}
+	if session.Offset() != session.Size {
+		return "", ErrIncompleteUpload
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+	return awsConn.completeSession(ctx, session)
+}
+
+// uploadSessionParts reads r by parts of the session up to its size and uploads them one by one,
+// the session is saved after every part. Part which is cut off by the end or failure of r is not uploaded
+func (awsConn *AWSConnector) uploadSessionParts(ctx context.Context, session *UploadSession, r io.Reader, sse sseHeaders) error {
+	// the first part is sent even if body is empty, S3 can't complete an upload without parts
+	for offset := session.Offset(); offset < session.Size || len(session.Parts) == 0; offset = session.Offset() {
+		partLen := session.PartSize
+		if rest := session.Size - offset; rest < partLen {
+			partLen = rest
+		}
+		buf := make([]byte, partLen)
+		n, err := readPart(r, buf)
+		if int64(n) < partLen {
+			if err == io.EOF {
+				return ErrIncompleteUpload
+			}
+			// the part is not complete, the client resumes from the last acknowledged part
+			return err
+		}
+
+		if err = awsConn.uploadSessionPart(ctx, session, buf, sse); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadSessionPart uploads buf as the next part of the session and saves the session within timeout of AWSConnector
+func (awsConn *AWSConnector) uploadSessionPart(ctx context.Context, session *UploadSession, buf []byte, sse sseHeaders) error {
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	number := int64(len(session.Parts)) + 1
+	contentMD5, sum := contentMD5Of(buf)
+	input := &s3.UploadPartInput{
+		Bucket:        &awsConn.AWSInfo.Bucket,
+		Key:           &session.Key,
+		UploadId:      &session.UploadID,
+		PartNumber:    aws.Int64(number),
+		ContentLength: aws.Int64(int64(len(buf))),
+		ContentMD5:    contentMD5,
+	}
+	sse.applyToUploadPart(input)
+	var out *s3.UploadPartOutput
+	err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		input.Body = bytes.NewReader(buf)
+		out, err = awsConn.svc.UploadPartWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return newS3Error("UploadPart", awsConn.AWSInfo.Bucket, session.Key, err)
+	}
+
+	session.Parts = append(session.Parts, SessionPart{
+		Number: number,
+		ETag:   aws.StringValue(out.ETag),
+		Size:   int64(len(buf)),
+		MD5:    hex.EncodeToString(sum),
+	})
+	session.UpdatedAt = timeNow().UTC()
+	return awsConn.uploadStore.Save(ctx, *session)
+}
+
+// completeSession completes multipart upload of the session and removes the session
+func (awsConn *AWSConnector) completeSession(ctx context.Context, session UploadSession) (string, error) {
+	parts := make([]*s3.CompletedPart, len(session.Parts))
+	partMD5s := make([][]byte, len(session.Parts))
+	for i, part := range session.Parts {
+		parts[i] = &s3.CompletedPart{ETag: aws.String(part.ETag), PartNumber: aws.Int64(part.Number)}
+		partMD5s[i], _ = hex.DecodeString(part.MD5)
+	}
+
+	var completed *s3.CompleteMultipartUploadOutput
+	err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+		completed, err = awsConn.svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          &awsConn.AWSInfo.Bucket,
+			Key:             &session.Key,
+			UploadId:        &session.UploadID,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+		return err
+	})
+	if err != nil {
+		return "", newS3Error("CompleteMultipartUpload", awsConn.AWSInfo.Bucket, session.Key, err)
+	}
+
+	if err = awsConn.uploadStore.Delete(ctx, session.ID); err != nil {
+		log.Errorf("failed to remove upload session %s of %s: %v", session.ID, session.Key, err)
+	}
+	if etagIsMD5(session.Encryption) && strings.Trim(aws.StringValue(completed.ETag), `"`) != compositeETag(partMD5s) {
+		awsConn.removeDetached(session.Key)
+		return "", ErrChecksumMismatch
+	}
+	return session.Key, nil
+}
+
+// AbortUpload aborts resumable upload, removes its uploaded parts and the session
+func (awsConn *AWSConnector) AbortUpload(ctx context.Context, id string) error {
+	if awsConn.uploadStore == nil {
+		return ErrNoUploadStore
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	session, err := awsConn.uploadStore.Load(ctx, id)
+	if err != nil {
+		return err
+	}
+	return awsConn.abortSession(ctx, session)
+}
+
+// abortSession aborts multipart upload of the session and removes the session,
+// upload which doesn't exist anymore is not an error
+func (awsConn *AWSConnector) abortSession(ctx context.Context, session UploadSession) error {
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   &awsConn.AWSInfo.Bucket,
+			Key:      &session.Key,
+			UploadId: &session.UploadID,
+		})
+	})
+	if err != nil {
+		s3Err := newS3Error("AbortMultipartUpload", awsConn.AWSInfo.Bucket, session.Key, err)
+		if !errors.Is(s3Err, cerr.ErrNotFound) {
+			return s3Err
+		}
+	}
+	return awsConn.uploadStore.Delete(ctx, session.ID)
+}
+
+// CleanupUploads aborts resumable uploads which sessions were not updated for olderThan and returns count of them.
+// Cleanup continues after failure of one session and returns the first error.
+// Multipart uploads without sessions are not touched, use AbortIncompleteUploadsRule for them
+func (awsConn *AWSConnector) CleanupUploads(ctx context.Context, olderThan time.Duration) (int, error) {
+	if awsConn.uploadStore == nil {
+		return 0, ErrNoUploadStore
+	}
+	if olderThan < 0 {
+		return 0, cerr.ErrFuncArg{}.Invalidate("olderThan")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	sessions, err := awsConn.uploadStore.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		cleaned  int
+		firstErr error
+		cutoff   = timeNow().Add(-olderThan)
+	)
+	for _, session := range sessions {
+		if session.UpdatedAt.After(cutoff) {
+			continue
+		}
+		if err = awsConn.abortSession(ctx, session); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		cleaned++
+	}
+	return cleaned, firstErr
+}
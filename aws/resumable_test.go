@@ -0,0 +1,323 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestClientStatusUpdater_StartUpload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc        string
+		store       UploadStateStore
+		size        int64
+		opts        []PutOption
+		svc         *MockiS3Client
+		wantSession UploadSession
+		wantErr     error
+	}{
+		{
+			desc:    "Should returns error when store is not set",
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: ErrNoUploadStore,
+		},
+		{
+			desc:    "Should returns error when size is negative",
+			store:   NewMemoryUploadStore(),
+			size:    -1,
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: cerr.NewErrFuncArgMock("size", "StartUpload"),
+		},
+		{
+			desc:    "Should returns error when body needs more parts than aws allows",
+			store:   NewMemoryUploadStore(),
+			size:    MinPartSize*maxPartsCount + 1,
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: ErrTooManyParts,
+		},
+		{
+			desc:    "Should returns error when SSE-C key is set for one write",
+			store:   NewMemoryUploadStore(),
+			opts:    []PutOption{WithPutEncryption(Encryption{Mode: SSEC, CustomerKey: bytes.Repeat([]byte("k"), 32)})},
+			svc:     NewMockiS3Client(ctrl),
+			wantErr: ErrInvalidEncryption,
+		},
+		{
+			desc:  "Should returns error when CreateMultipartUpload returns error",
+			store: NewMemoryUploadStore(),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: S3Error{Operation: "CreateMultipartUpload", Bucket: "test bucket", Key: "time_111_video.mp4", Attempts: 1, Err: errors.New("test error")},
+		},
+		{
+			desc:  "Should returns saved session",
+			store: NewMemoryUploadStore(),
+			size:  10,
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CreateMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+						assert.Equal(t, "video/mp4", aws.StringValue(input.ContentType))
+						return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantSession: UploadSession{ID: "111", Key: "time_111_video.mp4", UploadID: "upload", PartSize: MinPartSize, Size: 10},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			generator := NewMockiGenerate(ctrl)
+			generator.EXPECT().GenerateTime().Return("time").AnyTimes()
+			generator.EXPECT().GenerateUUID().Return("111").AnyTimes()
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, generator,
+				WithUploadStateStore(c.store), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+			// actual
+			got, gotErr := aws.StartUpload(context.Background(), "video.mp4", "", c.size, c.opts...)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+			if gotErr != nil {
+				return
+			}
+			stored, err := c.store.Load(context.Background(), got.ID)
+			require.NoError(t, err)
+			assert.Equal(t, got, stored)
+			got.CreatedAt, got.UpdatedAt = time.Time{}, time.Time{}
+			assert.Equal(t, c.wantSession, got)
+		})
+	}
+}
+
+func TestClientStatusUpdater_ResumeUpload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const partSize = 4
+	first, second := []byte("0123"), []byte("45")
+	firstMD5, secondMD5 := md5.Sum(first), md5.Sum(second)
+	session := UploadSession{ID: "id", Key: "key", UploadID: "upload", PartSize: partSize, Size: 6,
+		Parts: []SessionPart{{Number: 1, ETag: `"etag 1"`, Size: partSize, MD5: hex.EncodeToString(firstMD5[:])}}}
+
+	// arrange
+	cases := []struct {
+		desc        string
+		id          string
+		body        io.Reader
+		svc         *MockiS3Client
+		want        string
+		wantErr     error
+		wantParts   int
+		wantRemoved bool
+	}{
+		{
+			desc:      "Should returns error when session doesn't exist",
+			id:        "missing",
+			body:      bytes.NewReader(second),
+			svc:       NewMockiS3Client(ctrl),
+			wantErr:   cerr.ErrNotFound,
+			wantParts: 1,
+		},
+		{
+			desc: "Should keeps acknowledged parts when UploadPart returns error",
+			id:   "id",
+			body: bytes.NewReader(second),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr:   S3Error{Operation: "UploadPart", Bucket: "test bucket", Key: "key", Attempts: 1, Err: errors.New("test error")},
+			wantParts: 1,
+		},
+		{
+			desc:      "Should keeps session when body ends before its size",
+			id:        "id",
+			body:      bytes.NewReader(second[:1]),
+			svc:       NewMockiS3Client(ctrl),
+			wantErr:   ErrIncompleteUpload,
+			wantParts: 1,
+		},
+		{
+			desc:      "Should keeps session when body is cut off",
+			id:        "id",
+			body:      io.MultiReader(bytes.NewReader(second[:1]), iotest.ErrReader(io.ErrUnexpectedEOF)),
+			svc:       NewMockiS3Client(ctrl),
+			wantErr:   io.ErrUnexpectedEOF,
+			wantParts: 1,
+		},
+		{
+			desc: "Should uploads the rest of parts and completes the upload",
+			id:   "id",
+			body: bytes.NewReader(second),
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+						assert.Equal(t, int64(2), aws.Int64Value(input.PartNumber))
+						return &s3.UploadPartOutput{ETag: aws.String(`"etag 2"`)}, nil
+					})
+				m.EXPECT().CompleteMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+						assert.Len(t, input.MultipartUpload.Parts, 2)
+						return &s3.CompleteMultipartUploadOutput{ETag: aws.String(`"` + compositeETag([][]byte{firstMD5[:], secondMD5[:]}) + `"`)}, nil
+					})
+				return m
+			}(NewMockiS3Client(ctrl)),
+			want:        "key",
+			wantRemoved: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			store := NewMemoryUploadStore()
+			require.NoError(t, store.Save(context.Background(), session))
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, c.svc, NewMockiGenerate(ctrl),
+				WithUploadStateStore(store), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+			// actual
+			got, gotErr := aws.ResumeUpload(context.Background(), c.id, c.body)
+
+			// assert
+			assert.Equal(t, c.want, got)
+			if c.wantErr == cerr.ErrNotFound {
+				assert.True(t, errors.Is(gotErr, cerr.ErrNotFound))
+			} else {
+				assert.Equal(t, c.wantErr, gotErr)
+			}
+			stored, err := store.Load(context.Background(), "id")
+			if c.wantRemoved {
+				assert.True(t, errors.Is(err, cerr.ErrNotFound))
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, stored.Parts, c.wantParts)
+		})
+	}
+}
+
+// slowReader waits before every read
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.r.Read(p)
+}
+
+func TestClientStatusUpdater_ResumeUploadTimeoutPerPart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	body := []byte("0123456789ab")
+	var partMD5s [][]byte
+	for i := 0; i < len(body); i += 4 {
+		sum := md5.Sum(body[i : i+4])
+		partMD5s = append(partMD5s, sum[:])
+	}
+	store := NewMemoryUploadStore()
+	require.NoError(t, store.Save(context.Background(), UploadSession{ID: "id", Key: "key", UploadID: "upload", PartSize: 4, Size: int64(len(body))}))
+
+	var deadlines []time.Time
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().UploadPartWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+			deadline, _ := ctx.Deadline()
+			deadlines = append(deadlines, deadline)
+			return &s3.UploadPartOutput{ETag: aws.String(`"etag"`)}, nil
+		}).Times(3)
+	svc.EXPECT().CompleteMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+		Return(&s3.CompleteMultipartUploadOutput{ETag: aws.String(`"` + compositeETag(partMD5s) + `"`)}, nil)
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl),
+		WithUploadStateStore(store), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	// actual
+	got, gotErr := aws.ResumeUpload(context.Background(), "id", slowReader{r: bytes.NewReader(body), delay: 5 * time.Millisecond})
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Equal(t, "key", got)
+	if assert.Len(t, deadlines, 3) {
+		// every part has own timeout, which starts after the part is read
+		assert.True(t, deadlines[1].After(deadlines[0]))
+		assert.True(t, deadlines[2].After(deadlines[1]))
+	}
+}
+
+func TestClientStatusUpdater_CleanupUploads(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	now := time.Date(2021, 4, 2, 10, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	store := NewMemoryUploadStore()
+	require.NoError(t, store.Save(context.Background(), UploadSession{ID: "abandoned", Key: "a", UploadID: "1", UpdatedAt: now.Add(-48 * time.Hour)}))
+	require.NoError(t, store.Save(context.Background(), UploadSession{ID: "failing", Key: "f", UploadID: "2", UpdatedAt: now.Add(-48 * time.Hour)}))
+	require.NoError(t, store.Save(context.Background(), UploadSession{ID: "active", Key: "b", UploadID: "3", UpdatedAt: now.Add(-time.Hour)}))
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().AbortMultipartUploadWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3.AbortMultipartUploadInput) error {
+			if aws.StringValue(input.UploadId) == "2" {
+				return errors.New("test error")
+			}
+			assert.Equal(t, "1", aws.StringValue(input.UploadId))
+			return nil
+		}).Times(2)
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	aws, _ := NewAWSConnector(awsInfo, time.Minute, svc, NewMockiGenerate(ctrl),
+		WithUploadStateStore(store), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	// actual
+	cleaned, gotErr := aws.CleanupUploads(context.Background(), 24*time.Hour)
+	left, _ := store.List(context.Background())
+
+	// assert
+	assert.Equal(t, 1, cleaned)
+	assert.Error(t, gotErr)
+	if assert.Len(t, left, 2) {
+		assert.Equal(t, "active", left[0].ID)
+		assert.Equal(t, "failing", left[1].ID)
+	}
+}
@@ -0,0 +1,181 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts is used when RetryPolicy.MaxAttempts is not set
+	DefaultRetryAttempts = 3
+	// DefaultRetryBaseDelay is used when RetryPolicy.BaseDelay is not set
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+	// DefaultRetryMaxDelay is used when RetryPolicy.MaxDelay is not set
+	DefaultRetryMaxDelay = 5 * time.Second
+)
+
+// ErrInvalidRetryPolicy is error, which is returned when retry policy has negative values or max delay is less than base delay
+const ErrInvalidRetryPolicy = cerr.New("invalid retry policy")
+
+// retryDelay returns random duration in [0, n)
+var retryDelay = func(n time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(n)))
+}
+
+// RetryPolicy configures retries of aws calls of AWSConnector,
+// zero values are replaced with defaults
+type RetryPolicy struct {
+	// MaxAttempts is count of attempts including the first one, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is delay before the first retry, it's doubled for every next retry
+	BaseDelay time.Duration
+	// MaxDelay limits delay between attempts
+	MaxDelay time.Duration
+	// DisableJitter makes delays exact, by default delay is random in [0, delay)
+	DisableJitter bool
+	// Retryable reports whether failed call may be repeated, IsRetryable is used when it's nil
+	Retryable func(err error) bool
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 0 || p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return ErrInvalidRetryPolicy
+	}
+	if p.BaseDelay != 0 && p.MaxDelay != 0 && p.MaxDelay < p.BaseDelay {
+		return ErrInvalidRetryPolicy
+	}
+	return nil
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DefaultRetryAttempts
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = DefaultRetryBaseDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = DefaultRetryMaxDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// backoff returns delay before retry after the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if shift := uint(attempt - 1); shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		delay = p.BaseDelay << shift
+	}
+	if p.DisableJitter || delay <= 0 {
+		return delay
+	}
+	return retryDelay(delay)
+}
+
+// WithRetryPolicy sets retries of aws calls
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.retryPolicy = p
+	}
+}
+
+// ErrAttempts is returned when aws call failed after several attempts
+type ErrAttempts struct {
+	Attempts int
+	Err      error
+}
+
+// Error returns error's string
+func (e ErrAttempts) Error() string {
+	return fmt.Sprintf("%s (%d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap returns error of the last attempt
+func (e ErrAttempts) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is transient: throttling, 5xx responses,
+// connection resets and timeouts of a single request. Cancelled context is never retried
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		if reqErr.StatusCode() == http.StatusTooManyRequests || reqErr.StatusCode() >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestTimeout", "InternalError", "ServiceUnavailable",
+			request.ErrCodeRequestError, request.ErrCodeResponseTimeout:
+			return true
+		case request.CanceledErrorCode:
+			return false
+		}
+		if awsErr.OrigErr() != nil {
+			return IsRetryable(awsErr.OrigErr())
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retry calls call until it succeeds, returns not retryable error or attempts are over.
+// Delay before the next attempt is never longer than deadline of ctx.
+// Returns error of the last attempt wrapped into ErrAttempts when call was repeated
+func (awsConn *AWSConnector) retry(ctx context.Context, call func(ctx context.Context) error) error {
+	p := awsConn.retryPolicy.withDefaults()
+
+	var err error
+	attempt := 1
+attempts:
+	for ; ; attempt++ {
+		err = call(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !p.Retryable(err) {
+			break
+		}
+
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			break
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			break attempts
+		}
+	}
+
+	if err != nil && attempt > 1 {
+		return ErrAttempts{Attempts: attempt, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+package aws
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{
+			desc: "Should retry SlowDown",
+			err:  awserr.NewRequestFailure(awserr.New("SlowDown", "reduce your request rate", nil), http.StatusServiceUnavailable, "id"),
+			want: true,
+		},
+		{
+			desc: "Should retry internal error",
+			err:  awserr.NewRequestFailure(awserr.New("Unknown", "", nil), http.StatusInternalServerError, "id"),
+			want: true,
+		},
+		{
+			desc: "Should retry connection reset",
+			err:  awserr.New(request.ErrCodeRequestError, "send request failed", syscall.ECONNRESET),
+			want: true,
+		},
+		{
+			desc: "Should retry unexpected EOF",
+			err:  io.ErrUnexpectedEOF,
+			want: true,
+		},
+		{
+			desc: "Should not retry access denied",
+			err:  awserr.NewRequestFailure(awserr.New("AccessDenied", "", nil), http.StatusForbidden, "id"),
+			want: false,
+		},
+		{
+			desc: "Should not retry cancelled request",
+			err:  awserr.New(request.CanceledErrorCode, "", context.Canceled),
+			want: false,
+		},
+		{
+			desc: "Should not retry expired context",
+			err:  context.DeadlineExceeded,
+			want: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			got := IsRetryable(c.err)
+
+			// assert
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestClientStatusUpdater_WithRetryPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		policy  RetryPolicy
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when attempts count is negative",
+			policy:  RetryPolicy{MaxAttempts: -1},
+			wantErr: ErrInvalidRetryPolicy,
+		},
+		{
+			desc:    "Should returns error when max delay is less than base delay",
+			policy:  RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Millisecond},
+			wantErr: ErrInvalidRetryPolicy,
+		},
+		{
+			desc:    "Should returns no error",
+			policy:  RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Second},
+			wantErr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			_, gotErr := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl), WithRetryPolicy(c.policy))
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFileRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	slowDown := awserr.NewRequestFailure(awserr.New("SlowDown", "reduce your request rate", nil), http.StatusServiceUnavailable, "id")
+	accessDenied := awserr.NewRequestFailure(awserr.New("AccessDenied", "", nil), http.StatusForbidden, "id")
+	fileObj := "name:{img.png},dataUrl:{data:image/png;base64,iVBggg==}"
+
+	// arrange
+	cases := []struct {
+		desc         string
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			desc:         "Should succeed after transient errors",
+			errs:         []error{slowDown, slowDown, nil},
+			wantAttempts: 3,
+			wantErr:      nil,
+		},
+		{
+			desc:         "Should returns attempts count when attempts are over",
+			errs:         []error{slowDown, slowDown, slowDown},
+			wantAttempts: 3,
+			wantErr: S3Error{
+				Operation:  "PutObject",
+				Bucket:     "test bucket",
+				Key:        "time_111_img.png",
+				Code:       "SlowDown",
+				RequestID:  "id",
+				StatusCode: http.StatusServiceUnavailable,
+				Attempts:   3,
+				Err:        ErrAttempts{Attempts: 3, Err: slowDown},
+			},
+		},
+		{
+			desc:         "Should not retry not retryable error",
+			errs:         []error{accessDenied},
+			wantAttempts: 1,
+			wantErr: S3Error{
+				Operation:  "PutObject",
+				Bucket:     "test bucket",
+				Key:        "time_111_img.png",
+				Code:       "AccessDenied",
+				RequestID:  "id",
+				StatusCode: http.StatusForbidden,
+				Attempts:   1,
+				Err:        accessDenied,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			generator := NewMockiGenerate(ctrl)
+			generator.EXPECT().GenerateTime().Return("time")
+			generator.EXPECT().GenerateUUID().Return("111")
+
+			attempts := 0
+			svc := NewMockiS3Client(ctrl)
+			svc.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3.PutObjectInput) error {
+					// body must be sent from the start by every attempt
+					body, _ := ioutil.ReadAll(input.Body)
+					assert.Len(t, body, 4)
+					attempts++
+					return c.errs[attempts-1]
+				}).Times(len(c.errs))
+
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator, WithRetryPolicy(RetryPolicy{
+				BaseDelay:     time.Millisecond,
+				DisableJitter: true,
+			}))
+
+			// actual
+			_, gotErr := awsConn.PutFile(context.Background(), &fileObj)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+			assert.Equal(t, c.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestClientStatusUpdater_RetryDeadline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl), WithRetryPolicy(RetryPolicy{
+		MaxAttempts:   10,
+		BaseDelay:     time.Hour,
+		DisableJitter: true,
+	}))
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second)
+	defer cancelFn()
+
+	// actual
+	attempts := 0
+	gotErr := awsConn.retry(ctx, func(context.Context) error {
+		attempts++
+		return io.ErrUnexpectedEOF
+	})
+
+	// assert
+	assert.Equal(t, io.ErrUnexpectedEOF, gotErr)
+	assert.Equal(t, 1, attempts)
+}
@@ -2,19 +2,164 @@ package aws
 
 import (
 	"context"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"sync"
+	"time"
 )
 
 type S3Client struct {
 	Svc *s3.S3
+
+	uploaderOnce sync.Once
+	uploader     *s3manager.Uploader
 }
 
+// PutObjectWithContext puts object, progress and bandwidth limit carried by ctx are applied to its body
 func (s3 *S3Client) PutObjectWithContext(ctx context.Context, input *s3.PutObjectInput) error {
-	_, err := s3.Svc.PutObjectWithContext(ctx, input)
+	_, err := s3.Svc.PutObjectWithContext(ctx, input, requestOptions(ctx)...)
+	return err
+}
+
+func (s3 *S3Client) PutBucketPolicyWithContext(ctx context.Context, input *s3.PutBucketPolicyInput) error {
+	_, err := s3.Svc.PutBucketPolicyWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) GetBucketPolicyWithContext(ctx context.Context, input *s3.GetBucketPolicyInput) (*s3.GetBucketPolicyOutput, error) {
+	return s3.Svc.GetBucketPolicyWithContext(ctx, input)
+}
+
+func (s3 *S3Client) DeleteBucketPolicyWithContext(ctx context.Context, input *s3.DeleteBucketPolicyInput) error {
+	_, err := s3.Svc.DeleteBucketPolicyWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) ListObjectVersionsWithContext(ctx context.Context, input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	return s3.Svc.ListObjectVersionsWithContext(ctx, input)
+}
+
+func (s3 *S3Client) HeadBucketWithContext(ctx context.Context, input *s3.HeadBucketInput) error {
+	_, err := s3.Svc.HeadBucketWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) CreateBucketWithContext(ctx context.Context, input *s3.CreateBucketInput) error {
+	_, err := s3.Svc.CreateBucketWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) PutBucketVersioningWithContext(ctx context.Context, input *s3.PutBucketVersioningInput) error {
+	_, err := s3.Svc.PutBucketVersioningWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) PutBucketCorsWithContext(ctx context.Context, input *s3.PutBucketCorsInput) error {
+	_, err := s3.Svc.PutBucketCorsWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) DeleteBucketCorsWithContext(ctx context.Context, input *s3.DeleteBucketCorsInput) error {
+	_, err := s3.Svc.DeleteBucketCorsWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) GetBucketLifecycleConfigurationWithContext(ctx context.Context, input *s3.GetBucketLifecycleConfigurationInput) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	return s3.Svc.GetBucketLifecycleConfigurationWithContext(ctx, input)
+}
+
+func (s3 *S3Client) PutBucketLifecycleConfigurationWithContext(ctx context.Context, input *s3.PutBucketLifecycleConfigurationInput) error {
+	_, err := s3.Svc.PutBucketLifecycleConfigurationWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) DeleteBucketLifecycleWithContext(ctx context.Context, input *s3.DeleteBucketLifecycleInput) error {
+	_, err := s3.Svc.DeleteBucketLifecycleWithContext(ctx, input)
 	return err
 }
 
-func (s3 *S3Client) PutBucketPolicy(input *s3.PutBucketPolicyInput) error {
-	_, err := s3.Svc.PutBucketPolicy(input)
+// UploadWithContext uploads body of input which is not required to be seekable,
+// the body is read and sent by chunks. Progress and bandwidth limit carried by ctx are applied to every chunk.
+// The upload can't be retried as a whole, so failed chunks are retried by aws sdk even when retries of Svc are disabled
+func (s3 *S3Client) UploadWithContext(ctx context.Context, input *s3manager.UploadInput) error {
+	s3.uploaderOnce.Do(func() {
+		s3.uploader = s3manager.NewUploaderWithClient(s3.Svc)
+	})
+	opts := append(requestOptions(ctx), retryChunk)
+	_, err := s3.uploader.UploadWithContext(ctx, input, s3manager.WithUploaderRequestOptions(opts...))
 	return err
 }
+
+// retryChunk turns on default retries of aws sdk for one request
+func retryChunk(r *request.Request) {
+	r.Retryer = client.DefaultRetryer{NumMaxRetries: client.DefaultRetryerMaxNumRetries}
+}
+
+func (s3 *S3Client) CreateMultipartUploadWithContext(ctx context.Context, input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	return s3.Svc.CreateMultipartUploadWithContext(ctx, input)
+}
+
+// UploadPartWithContext uploads part, progress and bandwidth limit carried by ctx are applied to its body
+func (s3 *S3Client) UploadPartWithContext(ctx context.Context, input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	return s3.Svc.UploadPartWithContext(ctx, input, requestOptions(ctx)...)
+}
+
+func (s3 *S3Client) CompleteMultipartUploadWithContext(ctx context.Context, input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	return s3.Svc.CompleteMultipartUploadWithContext(ctx, input)
+}
+
+func (s3 *S3Client) AbortMultipartUploadWithContext(ctx context.Context, input *s3.AbortMultipartUploadInput) error {
+	_, err := s3.Svc.AbortMultipartUploadWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) GetObjectWithContext(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return s3.Svc.GetObjectWithContext(ctx, input)
+}
+
+// PresignPutObject returns url for uploading object described by input without credentials
+func (s3 *S3Client) PresignPutObject(input *s3.PutObjectInput, expire time.Duration) (string, error) {
+	req, _ := s3.Svc.PutObjectRequest(input)
+	return req.Presign(expire)
+}
+
+// PresignGetObject returns url for downloading object described by input without credentials
+func (s3 *S3Client) PresignGetObject(input *s3.GetObjectInput, expire time.Duration) (string, error) {
+	req, _ := s3.Svc.GetObjectRequest(input)
+	return req.Presign(expire)
+}
+
+// Credentials returns credentials the client signs requests with
+func (s3 *S3Client) Credentials() (credentials.Value, error) {
+	return s3.Svc.Config.Credentials.Get()
+}
+
+// Endpoint returns base url of the service
+func (s3 *S3Client) Endpoint() string {
+	return s3.Svc.Endpoint
+}
+
+func (s3 *S3Client) ListObjectsV2WithContext(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	return s3.Svc.ListObjectsV2WithContext(ctx, input)
+}
+
+func (s3 *S3Client) DeleteObjectWithContext(ctx context.Context, input *s3.DeleteObjectInput) error {
+	_, err := s3.Svc.DeleteObjectWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) DeleteObjectsWithContext(ctx context.Context, input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	return s3.Svc.DeleteObjectsWithContext(ctx, input)
+}
+
+func (s3 *S3Client) CopyObjectWithContext(ctx context.Context, input *s3.CopyObjectInput) error {
+	_, err := s3.Svc.CopyObjectWithContext(ctx, input)
+	return err
+}
+
+func (s3 *S3Client) HeadObjectWithContext(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return s3.Svc.HeadObjectWithContext(ctx, input)
+}
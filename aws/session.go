@@ -0,0 +1,127 @@
+package aws
+
+import (
+	"github.com/Stanly1995/golibs/params_validator"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"time"
+)
+
+// CredentialsSource is source of credentials of the session
+type CredentialsSource string
+
+const (
+	// CredentialsDefault uses AWSInfo.ID, Secret and Token when ID is set,
+	// otherwise default chain of aws sdk: environment, shared profile, instance role
+	CredentialsDefault CredentialsSource = ""
+	// CredentialsStatic uses AWSInfo.ID, Secret and Token
+	CredentialsStatic CredentialsSource = "static"
+	// CredentialsEnv uses AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+	CredentialsEnv CredentialsSource = "env"
+	// CredentialsProfile uses SessionConfig.Profile of shared config and credentials files
+	CredentialsProfile CredentialsSource = "profile"
+	// CredentialsAssumeRole assumes SessionConfig.RoleARN with credentials of default chain
+	CredentialsAssumeRole CredentialsSource = "assume_role"
+)
+
+// SessionConfig configures session built from AWSInfo
+type SessionConfig struct {
+	Credentials CredentialsSource `validate:"omitempty,oneof=static env profile assume_role"`
+	// Profile is name of shared profile, empty means default profile
+	Profile string
+	// RoleARN is role assumed by CredentialsAssumeRole
+	RoleARN string
+	// ExternalID is external id required by trust policy of the role
+	ExternalID string
+	// RoleSessionName is name of assumed role session, random name is used when it's empty
+	RoleSessionName string
+	// Endpoint replaces aws endpoint, e.g. http://localhost:9000 for MinIO
+	Endpoint string `validate:"omitempty,url"`
+	// PathStyle makes urls look like endpoint/bucket/key instead of bucket.endpoint/key,
+	// it's usually required by custom endpoints
+	PathStyle bool
+}
+
+// sessionRegion is validated part of AWSInfo
+type sessionRegion struct {
+	Region string `validate:"required"`
+}
+
+type staticCredentials struct {
+	ID     string `validate:"required"`
+	Secret string `validate:"required"`
+}
+
+type assumedRole struct {
+	RoleARN string `validate:"required"`
+}
+
+// NewS3ClientFromInfo builds S3Client with session of AWSInfo.Region and credentials selected by cfg.
+// Returns cerr.ErrInvalidParam with names of invalid fields when AWSInfo or cfg is invalid.
+// Retries of aws sdk are disabled, calls are retried by RetryPolicy of AWSConnector.
+// Chunks of streamed uploads are still retried by aws sdk, see S3Client.UploadWithContext
+func NewS3ClientFromInfo(awsInfo AWSInfo, cfg SessionConfig) (*S3Client, error) {
+	if err := params_validator.ValidateParams(sessionRegion{Region: awsInfo.Region}, cfg); err != nil {
+		return nil, err
+	}
+
+	opts := session.Options{
+		Config: aws.Config{Region: aws.String(awsInfo.Region), MaxRetries: aws.Int(0)},
+	}
+	switch cfg.Credentials {
+	case CredentialsDefault:
+		if awsInfo.ID != "" {
+			opts.Config.Credentials = credentials.NewStaticCredentials(awsInfo.ID, awsInfo.Secret, awsInfo.Token)
+		}
+	case CredentialsStatic:
+		if err := params_validator.ValidateParams(staticCredentials{ID: awsInfo.ID, Secret: awsInfo.Secret}); err != nil {
+			return nil, err
+		}
+		opts.Config.Credentials = credentials.NewStaticCredentials(awsInfo.ID, awsInfo.Secret, awsInfo.Token)
+	case CredentialsEnv:
+		opts.Config.Credentials = credentials.NewEnvCredentials()
+	case CredentialsProfile:
+		opts.Profile = cfg.Profile
+		opts.SharedConfigState = session.SharedConfigEnable
+	case CredentialsAssumeRole:
+		if err := params_validator.ValidateParams(assumedRole{RoleARN: cfg.RoleARN}); err != nil {
+			return nil, err
+		}
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Credentials == CredentialsAssumeRole {
+		sess = sess.Copy(&aws.Config{
+			Credentials: stscreds.NewCredentials(sess, cfg.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+				if cfg.ExternalID != "" {
+					p.ExternalID = aws.String(cfg.ExternalID)
+				}
+				if cfg.RoleSessionName != "" {
+					p.RoleSessionName = cfg.RoleSessionName
+				}
+			}),
+		})
+	}
+
+	// custom endpoint is set on s3 client only, so sts calls of assumed role still go to aws
+	s3Cfg := aws.NewConfig().WithS3ForcePathStyle(cfg.PathStyle)
+	if cfg.Endpoint != "" {
+		s3Cfg = s3Cfg.WithEndpoint(cfg.Endpoint)
+	}
+	return &S3Client{Svc: s3.New(sess, s3Cfg)}, nil
+}
+
+// NewAWSConnectorFromInfo builds S3Client by NewS3ClientFromInfo and AWSConnector using it
+func NewAWSConnectorFromInfo(awsInfo AWSInfo, cfg SessionConfig, timeout time.Duration, g dataGenerate, opts ...Option) (*AWSConnector, error) {
+	svc, err := NewS3ClientFromInfo(awsInfo, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewAWSConnector(awsInfo, timeout, svc, g, opts...)
+}
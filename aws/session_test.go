@@ -0,0 +1,110 @@
+package aws
+
+import (
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestNewS3ClientFromInfo(t *testing.T) {
+	os.Setenv("AWS_ACCESS_KEY_ID", "env id")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "env secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	// arrange
+	cases := []struct {
+		desc          string
+		awsInfo       AWSInfo
+		cfg           SessionConfig
+		wantEndpoint  string
+		wantPathStyle bool
+		wantCreds     credentials.Value
+	}{
+		{
+			desc:          "Should use static credentials and custom endpoint",
+			awsInfo:       AWSInfo{Region: "us-east-1", ID: "id", Secret: "secret", Token: "token"},
+			cfg:           SessionConfig{Endpoint: "http://localhost:9000", PathStyle: true},
+			wantEndpoint:  "http://localhost:9000",
+			wantPathStyle: true,
+			wantCreds:     credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret", SessionToken: "token", ProviderName: credentials.StaticProviderName},
+		},
+		{
+			desc:         "Should use credentials from environment",
+			awsInfo:      AWSInfo{Region: "eu-west-1", ID: "id", Secret: "secret"},
+			cfg:          SessionConfig{Credentials: CredentialsEnv},
+			wantEndpoint: "https://s3.eu-west-1.amazonaws.com",
+			wantCreds:    credentials.Value{AccessKeyID: "env id", SecretAccessKey: "env secret", ProviderName: credentials.EnvProviderName},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			got, gotErr := NewS3ClientFromInfo(c.awsInfo, c.cfg)
+
+			// assert
+			assert.NoError(t, gotErr)
+			assert.Equal(t, c.wantEndpoint, got.Endpoint())
+			assert.Equal(t, c.wantPathStyle, aws.BoolValue(got.Svc.Config.S3ForcePathStyle))
+			assert.Equal(t, 0, got.Svc.MaxRetries())
+			gotCreds, err := got.Credentials()
+			assert.NoError(t, err)
+			assert.Equal(t, c.wantCreds, gotCreds)
+		})
+	}
+}
+
+func TestNewS3ClientFromInfo_InvalidConfig(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc    string
+		awsInfo AWSInfo
+		cfg     SessionConfig
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when region is empty",
+			awsInfo: AWSInfo{ID: "id", Secret: "secret"},
+			wantErr: cerr.ErrInvalidParam{}.AddParam("Region"),
+		},
+		{
+			desc:    "Should returns error when static credentials have no secret",
+			awsInfo: AWSInfo{Region: "us-east-1", ID: "id"},
+			cfg:     SessionConfig{Credentials: CredentialsStatic},
+			wantErr: cerr.ErrInvalidParam{}.AddParam("Secret"),
+		},
+		{
+			desc:    "Should returns error when role is not set",
+			awsInfo: AWSInfo{Region: "us-east-1"},
+			cfg:     SessionConfig{Credentials: CredentialsAssumeRole},
+			wantErr: cerr.ErrInvalidParam{}.AddParam("RoleARN"),
+		},
+		{
+			desc:    "Should returns error when credentials source is unknown",
+			awsInfo: AWSInfo{Region: "us-east-1"},
+			cfg:     SessionConfig{Credentials: "unknown"},
+			wantErr: cerr.ErrInvalidParam{}.AddParam("Credentials"),
+		},
+		{
+			desc:    "Should returns error when endpoint is not url",
+			awsInfo: AWSInfo{Region: "us-east-1"},
+			cfg:     SessionConfig{Endpoint: "not url"},
+			wantErr: cerr.ErrInvalidParam{}.AddParam("Endpoint"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			got, gotErr := NewS3ClientFromInfo(c.awsInfo, c.cfg)
+
+			// assert
+			assert.Nil(t, got)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package aws
+
+import (
+	"context"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrInvalidBandwidthLimit is error, which is returned when bandwidth limit is not positive
+const ErrInvalidBandwidthLimit = cerr.New("bandwidth limit must be positive")
+
+// maxTransferChunk is the max count of bytes read from body at once, so bandwidth is limited smoothly
+const maxTransferChunk = 32 * 1024
+
+// Progress describes transferred part of the object body
+type Progress struct {
+	Key string
+	// Transferred is count of bytes of the object sent or received so far
+	Transferred int64
+	// Total is size of the object, -1 when it's unknown
+	Total int64
+	// PartNumber is number of part of multipart upload, which progress changed. It's 0 for single request
+	PartNumber int64
+	// PartTransferred and PartTotal are progress of the part
+	PartTransferred int64
+	PartTotal       int64
+}
+
+// ProgressFunc receives progress of one transfer. Calls are serialized,
+// progress of a part starts over when the part is retried
+type ProgressFunc func(p Progress)
+
+// WithUploadProgress reports progress of one upload to fn
+func WithUploadProgress(fn ProgressFunc) PutOption {
+	return func(opts *putOptions) {
+		opts.progress = fn
+	}
+}
+
+// WithDownloadProgress reports progress of reading of the object body to fn
+func WithDownloadProgress(fn ProgressFunc) GetOption {
+	return func(opts *getOptions) {
+		opts.progress = fn
+	}
+}
+
+// BandwidthLimiter limits rate of transferred bytes by token bucket.
+// One limiter may be shared by many connectors and calls, they share its bandwidth then
+type BandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBandwidthLimiter returns limiter of bytesPerSecond, which allows bursts of one second of traffic
+func NewBandwidthLimiter(bytesPerSecond int64) (*BandwidthLimiter, error) {
+	if bytesPerSecond <= 0 {
+		return nil, ErrInvalidBandwidthLimit
+	}
+	return &BandwidthLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}, nil
+}
+
+// wait takes n tokens and waits until the bucket is not in debt or ctx is done
+func (l *BandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithBandwidthLimit limits bandwidth of all uploads and downloads of AWSConnector
+func WithBandwidthLimit(l *BandwidthLimiter) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.limiter = l
+	}
+}
+
+// WithPutBandwidthLimit limits bandwidth of one upload, limit of AWSConnector is applied as well
+func WithPutBandwidthLimit(l *BandwidthLimiter) PutOption {
+	return func(opts *putOptions) {
+		opts.limiter = l
+	}
+}
+
+// WithGetBandwidthLimit limits bandwidth of reading of one object body, limit of AWSConnector is applied as well
+func WithGetBandwidthLimit(l *BandwidthLimiter) GetOption {
+	return func(opts *getOptions) {
+		opts.limiter = l
+	}
+}
+
+// transfer reports progress and limits bandwidth of bodies of one call
+type transfer struct {
+	key      string
+	total    int64
+	progress ProgressFunc
+	limiters []*BandwidthLimiter
+
+	mu          sync.Mutex
+	parts       map[int64]int64
+	transferred int64
+}
+
+// newTransfer returns transfer of the object, nil means that bodies are transferred as is
+func (awsConn *AWSConnector) newTransfer(key string, total int64, progress ProgressFunc, limiter *BandwidthLimiter) *transfer {
+	var limiters []*BandwidthLimiter
+	for _, l := range []*BandwidthLimiter{awsConn.limiter, limiter} {
+		if l != nil {
+			limiters = append(limiters, l)
+		}
+	}
+	if progress == nil && len(limiters) == 0 {
+		return nil
+	}
+	return &transfer{
+		key:      key,
+		total:    total,
+		progress: progress,
+		limiters: limiters,
+		parts:    make(map[int64]int64),
+	}
+}
+
+type transferCtxKey struct{}
+
+// withTransfer returns context which carries transfer to S3Client, it's applied to bodies of requests it sends
+func withTransfer(ctx context.Context, t *transfer) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, transferCtxKey{}, t)
+}
+
+// requestOptions returns options which apply transfer carried by ctx to request body
+func requestOptions(ctx context.Context) []request.Option {
+	t, ok := ctx.Value(transferCtxKey{}).(*transfer)
+	if !ok {
+		return nil
+	}
+	return []request.Option{t.requestOption}
+}
+
+// requestOption wraps body of the request when it's sent, so reading of the body for signing
+// is not counted. Body is wrapped again for every attempt
+func (t *transfer) requestOption(r *request.Request) {
+	r.Handlers.Send.PushFront(func(r *request.Request) {
+		if r.HTTPRequest.Body == nil || r.HTTPRequest.Body == http.NoBody {
+			return
+		}
+		var part int64
+		if input, ok := r.Params.(*s3.UploadPartInput); ok && input.PartNumber != nil {
+			part = *input.PartNumber
+		}
+		r.HTTPRequest.Body = t.body(r.Context(), r.HTTPRequest.Body, part, r.HTTPRequest.ContentLength)
+	})
+}
+
+// body wraps body of the part, part 0 is the whole object
+func (t *transfer) body(ctx context.Context, body io.ReadCloser, part, partTotal int64) io.ReadCloser {
+	t.report(part, 0, partTotal)
+	return &transferBody{ReadCloser: body, ctx: ctx, t: t, part: part, partTotal: partTotal}
+}
+
+// report sets count of transferred bytes of the part and reports progress of the object
+func (t *transfer) report(part, partTransferred, partTotal int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.transferred += partTransferred - t.parts[part]
+	t.parts[part] = partTransferred
+	if t.progress != nil {
+		t.progress(Progress{
+			Key:             t.key,
+			Transferred:     t.transferred,
+			Total:           t.total,
+			PartNumber:      part,
+			PartTransferred: partTransferred,
+			PartTotal:       partTotal,
+		})
+	}
+}
+
+type transferBody struct {
+	io.ReadCloser
+	ctx         context.Context
+	t           *transfer
+	part        int64
+	partTotal   int64
+	transferred int64
+}
+
+func (b *transferBody) Read(p []byte) (int, error) {
+	if len(b.t.limiters) != 0 && len(p) > maxTransferChunk {
+		p = p[:maxTransferChunk]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	for _, l := range b.t.limiters {
+		if waitErr := l.wait(b.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	b.transferred += int64(n)
+	b.t.report(b.part, b.transferred, b.partTotal)
+	return n, err
+}
@@ -0,0 +1,112 @@
+package aws
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_NewBandwidthLimiter(t *testing.T) {
+	// arrange
+	cases := []struct {
+		desc           string
+		bytesPerSecond int64
+		wantErr        error
+	}{
+		{
+			desc:           "Should returns error when limit is not positive",
+			bytesPerSecond: 0,
+			wantErr:        ErrInvalidBandwidthLimit,
+		},
+		{
+			desc:           "Should returns no error",
+			bytesPerSecond: 1024,
+			wantErr:        nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			_, gotErr := NewBandwidthLimiter(c.bytesPerSecond)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_BandwidthLimiterWait(t *testing.T) {
+	// arrange
+	limiter, err := NewBandwidthLimiter(1000)
+	require.NoError(t, err)
+	start := time.Now()
+
+	// actual
+	burstErr := limiter.wait(context.Background(), 1000)
+	burstTime := time.Since(start)
+	waitErr := limiter.wait(context.Background(), 100)
+	waitTime := time.Since(start)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+	cancelErr := limiter.wait(ctx, 1000)
+
+	// assert
+	assert.NoError(t, burstErr)
+	assert.Less(t, int64(burstTime), int64(50*time.Millisecond))
+	assert.NoError(t, waitErr)
+	assert.GreaterOrEqual(t, int64(waitTime), int64(90*time.Millisecond))
+	assert.Equal(t, context.Canceled, cancelErr)
+}
+
+func TestClientStatusUpdater_TransferProgress(t *testing.T) {
+	// arrange
+	var got []Progress
+	awsConn := &AWSConnector{}
+	tr := awsConn.newTransfer("key", 10, func(p Progress) {
+		got = append(got, p)
+	}, nil)
+
+	// actual
+	first := tr.body(context.Background(), ioutil.NopCloser(strings.NewReader("01234")), 1, 5)
+	_, firstErr := ioutil.ReadAll(first)
+	// the second part is retried, its progress starts over
+	retried := tr.body(context.Background(), ioutil.NopCloser(strings.NewReader("567")), 2, 5)
+	_, retriedErr := retried.Read(make([]byte, 3))
+	second := tr.body(context.Background(), ioutil.NopCloser(strings.NewReader("56789")), 2, 5)
+	_, secondErr := ioutil.ReadAll(second)
+
+	// assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, retriedErr)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, []Progress{
+		{Key: "key", Transferred: 0, Total: 10, PartNumber: 1, PartTransferred: 0, PartTotal: 5},
+		{Key: "key", Transferred: 5, Total: 10, PartNumber: 1, PartTransferred: 5, PartTotal: 5},
+		{Key: "key", Transferred: 5, Total: 10, PartNumber: 2, PartTransferred: 0, PartTotal: 5},
+		{Key: "key", Transferred: 8, Total: 10, PartNumber: 2, PartTransferred: 3, PartTotal: 5},
+		{Key: "key", Transferred: 5, Total: 10, PartNumber: 2, PartTransferred: 0, PartTotal: 5},
+		{Key: "key", Transferred: 10, Total: 10, PartNumber: 2, PartTransferred: 5, PartTotal: 5},
+	}, got)
+}
+
+func TestClientStatusUpdater_NewTransfer(t *testing.T) {
+	// arrange
+	limiter, err := NewBandwidthLimiter(1024)
+	require.NoError(t, err)
+
+	// actual
+	withoutAnything := (&AWSConnector{}).newTransfer("key", 1, nil, nil)
+	withCallLimit := (&AWSConnector{}).newTransfer("key", 1, nil, limiter)
+	withBothLimits := (&AWSConnector{limiter: limiter}).newTransfer("key", 1, nil, limiter)
+
+	// assert
+	assert.Nil(t, withoutAnything)
+	assert.Len(t, withCallLimit.limiters, 1)
+	assert.Len(t, withBothLimits.limiters, 2)
+}
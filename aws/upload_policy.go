@@ -0,0 +1,230 @@
+package aws
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// ErrFileTooLarge is reason of ErrPolicy, which is returned when file is larger than UploadPolicy.MaxSize
+	ErrFileTooLarge = cerr.New("file is too large")
+
+	// ErrTypeNotAllowed is reason of ErrPolicy, which is returned when content type is not in UploadPolicy.AllowedTypes
+	ErrTypeNotAllowed = cerr.New("file type is not allowed")
+
+	// ErrTypeMismatch is reason of ErrPolicy, which is returned when declared content type doesn't match file content
+	ErrTypeMismatch = cerr.New("file type doesn't match its content")
+
+	// ErrExtensionNotAllowed is reason of ErrPolicy, which is returned when extension is not in UploadPolicy.AllowedExtensions
+	ErrExtensionNotAllowed = cerr.New("file extension is not allowed")
+)
+
+// ErrPolicy represents an upload which violates UploadPolicy,
+// errors.Is(err, ErrFileTooLarge) and others can be used to check the reason
+type ErrPolicy struct {
+	Reason cerr.New
+	Detail string
+}
+
+// Error returns error's string
+func (e ErrPolicy) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Detail)
+}
+
+// Unwrap returns reason of the error
+func (e ErrPolicy) Unwrap() error {
+	return e.Reason
+}
+
+// StatusCode returns http status which suits the reason:
+// 413 for ErrFileTooLarge and 415 for others
+func (e ErrPolicy) StatusCode() int {
+	if e.Reason == ErrFileTooLarge {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusUnsupportedMediaType
+}
+
+// genericTypes are returned by http.DetectContentType when content isn't recognized
+var genericTypes = map[string]bool{
+	"application/octet-stream": true,
+	"text/plain":               true,
+}
+
+// sniffableTypes are recognized by http.DetectContentType,
+// files declared with these types must have matching magic bytes
+var sniffableTypes = map[string]bool{
+	"image/png":                    true,
+	"image/jpeg":                   true,
+	"image/gif":                    true,
+	"image/webp":                   true,
+	"image/bmp":                    true,
+	"image/x-icon":                 true,
+	"application/pdf":              true,
+	"application/zip":              true,
+	"application/x-gzip":           true,
+	"application/x-rar-compressed": true,
+	"application/wasm":             true,
+	"audio/mpeg":                   true,
+	"audio/wave":                   true,
+	"audio/aiff":                   true,
+	"application/ogg":              true,
+	"video/mp4":                    true,
+	"video/webm":                   true,
+	"video/avi":                    true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+	"font/ttf":                     true,
+	"font/otf":                     true,
+}
+
+// UploadPolicy restricts files which are allowed to be uploaded by AWSConnector.
+// Zero value allows everything
+type UploadPolicy struct {
+	// MaxSize is max size of decoded file in bytes, 0 means no limit
+	MaxSize int64
+	// AllowedTypes are allowed media types, e.g. "image/png" or "image/*". Empty means any type
+	AllowedTypes []string
+	// VerifyMagicBytes enables checking that declared media type matches the first bytes of the file
+	VerifyMagicBytes bool
+	// AllowedExtensions are allowed file name extensions, e.g. ".png". Empty means any extension
+	AllowedExtensions []string
+}
+
+// WithUploadPolicy sets policy which is checked by every upload
+func WithUploadPolicy(policy UploadPolicy) Option {
+	return func(awsConn *AWSConnector) {
+		awsConn.policy = policy
+	}
+}
+
+func (p UploadPolicy) checkSize(size int64) error {
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return ErrPolicy{Reason: ErrFileTooLarge, Detail: fmt.Sprintf("%d bytes, max is %d", size, p.MaxSize)}
+	}
+	return nil
+}
+
+func (p UploadPolicy) checkName(name string) error {
+	if len(p.AllowedExtensions) == 0 {
+		return nil
+	}
+	ext := strings.ToLower(path.Ext(SanitizeFileName(name)))
+	for _, allowed := range p.AllowedExtensions {
+		if ext == strings.ToLower(allowed) {
+			return nil
+		}
+	}
+	return ErrPolicy{Reason: ErrExtensionNotAllowed, Detail: fmt.Sprintf("%q", ext)}
+}
+
+func (p UploadPolicy) checkType(contentType string) error {
+	if len(p.AllowedTypes) == 0 {
+		return nil
+	}
+	mediaType := baseMediaType(contentType)
+	for _, allowed := range p.AllowedTypes {
+		allowed = strings.ToLower(allowed)
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return nil
+		}
+	}
+	return ErrPolicy{Reason: ErrTypeNotAllowed, Detail: fmt.Sprintf("%q", mediaType)}
+}
+
+// checkTypePrefix checks that every content type with prefix is allowed, only "type/*" allows a prefix
+func (p UploadPolicy) checkTypePrefix(prefix string) error {
+	if len(p.AllowedTypes) == 0 {
+		return nil
+	}
+	prefix = strings.ToLower(prefix)
+	for _, allowed := range p.AllowedTypes {
+		allowed = strings.ToLower(allowed)
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(prefix, strings.TrimSuffix(allowed, "*")) {
+			return nil
+		}
+	}
+	return ErrPolicy{Reason: ErrTypeNotAllowed, Detail: fmt.Sprintf("prefix %q", prefix)}
+}
+
+// checkContent compares declared content type with type detected by the first bytes of the file
+func (p UploadPolicy) checkContent(contentType string, head []byte) error {
+	if !p.VerifyMagicBytes {
+		return nil
+	}
+	declared := baseMediaType(contentType)
+	detected := baseMediaType(http.DetectContentType(head))
+	if declared == detected || (genericTypes[detected] && !sniffableTypes[declared]) {
+		return nil
+	}
+	return ErrPolicy{Reason: ErrTypeMismatch, Detail: fmt.Sprintf("declared %q, detected %q", declared, detected)}
+}
+
+// checkStream checks everything what may be checked before reading r and returns content type
+// (detected if contentType is empty) and reader which must be used instead of r.
+// The reader fails with ErrPolicy when it reads more than MaxSize, the error is also kept in the reader
+func (p UploadPolicy) checkStream(name, contentType string, r io.Reader) (string, *policyReader, error) {
+	if err := p.checkName(name); err != nil {
+		return "", nil, err
+	}
+
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	} else if err := p.checkContent(contentType, head); err != nil {
+		return "", nil, err
+	}
+	if err := p.checkType(contentType); err != nil {
+		return "", nil, err
+	}
+
+	return contentType, &policyReader{r: br, policy: p}, nil
+}
+
+// policyReader counts read bytes and fails when there are more than UploadPolicy.MaxSize
+type policyReader struct {
+	r      io.Reader
+	policy UploadPolicy
+	read   int64
+	err    error
+}
+
+func (pr *policyReader) Read(p []byte) (int, error) {
+	if pr.err != nil {
+		return 0, pr.err
+	}
+	n, err := pr.r.Read(p)
+	pr.read += int64(n)
+	if sizeErr := pr.policy.checkSize(pr.read); sizeErr != nil {
+		pr.err = sizeErr
+		return n, sizeErr
+	}
+	return n, err
+}
+
+// estimateDecodedSize returns size of decoded body of data url without decoding it,
+// -1 is returned when data url isn't base64 encoded
+func estimateDecodedSize(dataURL string) int64 {
+	comma := strings.IndexByte(dataURL, ',')
+	if comma < 0 || !strings.HasSuffix(strings.ToLower(dataURL[:comma]), ";base64") {
+		return -1
+	}
+	data := strings.TrimRight(dataURL[comma+1:], "=")
+	return int64(len(data)) * 3 / 4
+}
+
+// baseMediaType returns lowercase media type without params
+func baseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	}
+	return mediaType
+}
@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_PutFileUploadPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc           string
+		fileObj        string
+		policy         UploadPolicy
+		wantReason     error
+		wantStatusCode int
+	}{
+		{
+			desc:           "Should returns ErrFileTooLarge before decoding",
+			fileObj:        "name:{img.png},dataUrl:{data:image/png;base64,not base64 but long enough}",
+			policy:         UploadPolicy{MaxSize: 8},
+			wantReason:     ErrFileTooLarge,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:           "Should returns ErrExtensionNotAllowed",
+			fileObj:        "name:{run.exe},dataUrl:{data:image/png;base64,iVBggg==}",
+			policy:         UploadPolicy{AllowedExtensions: []string{".png", ".JPG"}},
+			wantReason:     ErrExtensionNotAllowed,
+			wantStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			desc:           "Should returns ErrTypeNotAllowed",
+			fileObj:        "name:{doc.pdf},dataUrl:{data:application/pdf;base64,JVBERi0xLjQ=}",
+			policy:         UploadPolicy{AllowedTypes: []string{"image/*"}},
+			wantReason:     ErrTypeNotAllowed,
+			wantStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			desc:           "Should returns ErrTypeMismatch when png is declared for pdf",
+			fileObj:        "name:{img.png},dataUrl:{data:image/png;base64,JVBERi0xLjQ=}",
+			policy:         UploadPolicy{VerifyMagicBytes: true},
+			wantReason:     ErrTypeMismatch,
+			wantStatusCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test bucket",
+				URL:    "test URL",
+			}
+			aws, _ := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl), WithUploadPolicy(c.policy))
+			_, gotErr := aws.PutFile(context.Background(), &c.fileObj)
+
+			// assert
+			assert.True(t, errors.Is(gotErr, c.wantReason), gotErr)
+			var policyErr ErrPolicy
+			assert.True(t, errors.As(gotErr, &policyErr))
+			assert.Equal(t, c.wantStatusCode, policyErr.StatusCode())
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFileUploadPolicyAllowed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).Return(nil)
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator, WithUploadPolicy(UploadPolicy{
+		MaxSize:           1024,
+		AllowedTypes:      []string{"application/pdf"},
+		VerifyMagicBytes:  true,
+		AllowedExtensions: []string{".pdf"},
+	}))
+	fileObj := "name:{doc.pdf},dataUrl:{data:application/pdf;base64,JVBERi0xLjQ=}"
+
+	// actual
+	got, gotErr := awsConn.PutFile(context.Background(), &fileObj)
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Equal(t, "time_111_doc.pdf", got)
+}
+
+func TestClientStatusUpdater_PutReaderUploadPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().UploadWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3manager.UploadInput) error {
+			_, err := ioutil.ReadAll(input.Body)
+			return err
+		})
+
+	awsInfo := AWSInfo{
+		Bucket: "test bucket",
+		URL:    "test URL",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator, WithUploadPolicy(UploadPolicy{MaxSize: 4}))
+
+	// actual
+	_, gotErr := awsConn.PutReader(context.Background(), "file.txt", "text/plain", strings.NewReader("more than four bytes"), -1)
+
+	// assert
+	assert.True(t, errors.Is(gotErr, ErrFileTooLarge), gotErr)
+}
@@ -0,0 +1,236 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionFileExt is extension of files of FileUploadStore
+const sessionFileExt = ".json"
+
+// UploadSession is state of resumable upload, it's kept by UploadStateStore between calls
+type UploadSession struct {
+	ID       string `json:"id"`
+	Key      string `json:"key"`
+	UploadID string `json:"uploadId"`
+	PartSize int64  `json:"partSize"`
+	// Size is size of the whole body set by StartUpload, the object is completed only when all of it is acknowledged
+	Size int64 `json:"size"`
+	// Encryption is mode of server-side encryption of the object, ETag is not verified for SSE-KMS
+	Encryption EncryptionMode `json:"encryption,omitempty"`
+	// Parts are acknowledged parts in order of numbers
+	Parts     []SessionPart `json:"parts,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// SessionPart is part of resumable upload acknowledged by aws
+type SessionPart struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+	// MD5 is hex MD5 of the part, it's used to verify ETag of completed object
+	MD5 string `json:"md5"`
+}
+
+// Offset returns count of acknowledged bytes, upload is resumed from this offset of the file
+func (s UploadSession) Offset() int64 {
+	var offset int64
+	for _, part := range s.Parts {
+		offset += part.Size
+	}
+	return offset
+}
+
+// UploadStateStore keeps sessions of resumable uploads. Every implementation returns
+// error which is cerr.ErrNotFound when there is no such session
+type UploadStateStore interface {
+	// Save stores the session, existing session with the same id is replaced
+	Save(ctx context.Context, session UploadSession) error
+	// Load returns the session by id
+	Load(ctx context.Context, id string) (UploadSession, error)
+	// Delete removes the session, deleting a missing session is not an error
+	Delete(ctx context.Context, id string) error
+	// List returns all sessions sorted by id
+	List(ctx context.Context) ([]UploadSession, error)
+}
+
+// MemoryUploadStore keeps sessions in memory, they are lost on restart, so it's intended for tests
+type MemoryUploadStore struct {
+	mu       sync.RWMutex
+	sessions map[string]UploadSession
+}
+
+// NewMemoryUploadStore is constructor
+func NewMemoryUploadStore() *MemoryUploadStore {
+	return &MemoryUploadStore{sessions: make(map[string]UploadSession)}
+}
+
+// Save stores the session
+func (m *MemoryUploadStore) Save(ctx context.Context, session UploadSession) error {
+	if err := validateSessionID(session.ID); err != nil {
+		return err
+	}
+	session.Parts = append([]SessionPart(nil), session.Parts...)
+	m.mu.Lock()
+	m.sessions[session.ID] = session
+	m.mu.Unlock()
+	return nil
+}
+
+// Load returns the session by id
+func (m *MemoryUploadStore) Load(ctx context.Context, id string) (UploadSession, error) {
+	if err := validateSessionID(id); err != nil {
+		return UploadSession{}, err
+	}
+	m.mu.RLock()
+	session, ok := m.sessions[id]
+	m.mu.RUnlock()
+	if !ok {
+		return UploadSession{}, fmt.Errorf("upload session %s: %w", id, cerr.ErrNotFound)
+	}
+	session.Parts = append([]SessionPart(nil), session.Parts...)
+	return session, nil
+}
+
+// Delete removes the session
+func (m *MemoryUploadStore) Delete(ctx context.Context, id string) error {
+	if err := validateSessionID(id); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// List returns all sessions sorted by id
+func (m *MemoryUploadStore) List(ctx context.Context) ([]UploadSession, error) {
+	m.mu.RLock()
+	sessions := make([]UploadSession, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		session.Parts = append([]SessionPart(nil), session.Parts...)
+		sessions = append(sessions, session)
+	}
+	m.mu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+// FileUploadStore keeps every session as json file in directory, so uploads survive restarts of the service
+type FileUploadStore struct {
+	dir string
+}
+
+// NewFileUploadStore is constructor, it creates dir when it doesn't exist
+func NewFileUploadStore(dir string) (*FileUploadStore, error) {
+	if dir == "" {
+		return nil, cerr.ErrFuncArg{}.Invalidate("dir")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileUploadStore{dir: dir}, nil
+}
+
+// Save stores the session. It's written to temporary file and renamed,
+// so readers never see partially written session
+func (f *FileUploadStore) Save(ctx context.Context, session UploadSession) error {
+	if err := validateSessionID(session.ID); err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(f.dir, "session-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(session.ID))
+}
+
+// Load returns the session by id
+func (f *FileUploadStore) Load(ctx context.Context, id string) (UploadSession, error) {
+	if err := validateSessionID(id); err != nil {
+		return UploadSession{}, err
+	}
+	data, err := ioutil.ReadFile(f.path(id))
+	if os.IsNotExist(err) {
+		return UploadSession{}, fmt.Errorf("upload session %s: %w", id, cerr.ErrNotFound)
+	}
+	if err != nil {
+		return UploadSession{}, err
+	}
+	var session UploadSession
+	if err = json.Unmarshal(data, &session); err != nil {
+		return UploadSession{}, err
+	}
+	return session, nil
+}
+
+// Delete removes the session
+func (f *FileUploadStore) Delete(ctx context.Context, id string) error {
+	if err := validateSessionID(id); err != nil {
+		return err
+	}
+	if err := os.Remove(f.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns all sessions sorted by id
+func (f *FileUploadStore) List(ctx context.Context) ([]UploadSession, error) {
+	files, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]UploadSession, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), sessionFileExt) {
+			continue
+		}
+		session, err := f.Load(ctx, strings.TrimSuffix(file.Name(), sessionFileExt))
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (f *FileUploadStore) path(id string) string {
+	return filepath.Join(f.dir, id+sessionFileExt)
+}
+
+// validateSessionID rejects ids which can't be used as file names
+func validateSessionID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return cerr.ErrFuncArg{}.Invalidate("id")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_UploadStateStore(t *testing.T) {
+	fileStore, err := NewFileUploadStore(filepath.Join(t.TempDir(), "sessions"))
+	require.NoError(t, err)
+
+	// arrange
+	cases := []struct {
+		desc  string
+		store UploadStateStore
+	}{
+		{
+			desc:  "Should keeps sessions in memory",
+			store: NewMemoryUploadStore(),
+		},
+		{
+			desc:  "Should keeps sessions in files",
+			store: fileStore,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			ctx := context.Background()
+			created := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+			first := UploadSession{ID: "b", Key: "key", UploadID: "upload", PartSize: MinPartSize, CreatedAt: created, UpdatedAt: created}
+			second := UploadSession{ID: "a", Key: "other", UploadID: "upload 2", PartSize: MinPartSize, Encryption: SSES3, CreatedAt: created, UpdatedAt: created}
+
+			// actual
+			saveErr := c.store.Save(ctx, first)
+			first.Parts = []SessionPart{{Number: 1, ETag: `"etag"`, Size: MinPartSize, MD5: "md5"}}
+			updateErr := c.store.Save(ctx, first)
+			_ = c.store.Save(ctx, second)
+			loaded, loadErr := c.store.Load(ctx, "b")
+			list, listErr := c.store.List(ctx)
+			deleteErr := c.store.Delete(ctx, "b")
+			deleteMissingErr := c.store.Delete(ctx, "b")
+			_, missingErr := c.store.Load(ctx, "b")
+			invalidErr := c.store.Save(ctx, UploadSession{ID: "../b"})
+
+			// assert
+			assert.NoError(t, saveErr)
+			assert.NoError(t, updateErr)
+			assert.NoError(t, loadErr)
+			assert.Equal(t, first, loaded)
+			assert.Equal(t, int64(MinPartSize), loaded.Offset())
+			assert.NoError(t, listErr)
+			assert.Equal(t, []UploadSession{second, first}, list)
+			assert.NoError(t, deleteErr)
+			assert.NoError(t, deleteMissingErr)
+			assert.True(t, errors.Is(missingErr, cerr.ErrNotFound))
+			assert.Equal(t, cerr.NewErrFuncArgMock("id", "validateSessionID"), invalidErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_FileUploadStoreSurvivesRestart(t *testing.T) {
+	// arrange
+	dir := t.TempDir()
+	session := UploadSession{ID: "id", Key: "key", UploadID: "upload", PartSize: MinPartSize,
+		Parts: []SessionPart{{Number: 1, ETag: `"etag"`, Size: MinPartSize, MD5: "md5"}}}
+	store, err := NewFileUploadStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(context.Background(), session))
+
+	// actual
+	restarted, restartErr := NewFileUploadStore(dir)
+	require.NoError(t, restartErr)
+	got, gotErr := restarted.Load(context.Background(), "id")
+	files, _ := ioutil.ReadDir(dir)
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Equal(t, session, got)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"context"
+	"github.com/Stanly1995/golibs/cerr"
+	"net/url"
+	"strings"
+)
+
+// Placeholders of AWSInfo.URL template
+const (
+	urlBucketPlaceholder = "{bucket}"
+	urlRegionPlaceholder = "{region}"
+	urlKeyPlaceholder    = "{key}"
+)
+
+// ErrInvalidURLTemplate is error, which is returned when AWSInfo.URL can't be turned into absolute url
+const ErrInvalidURLTemplate = cerr.New("invalid aws url template")
+
+// PutResult describes stored object
+type PutResult struct {
+	Key string
+	URL string
+	// Checksum is digest of the stored body
+	Checksum Checksum
+}
+
+// ObjectURL returns url for downloading the object stored by key.
+// AWSInfo.URL is a template, {bucket} and {region} are replaced with values of AWSInfo,
+// {key} is replaced with escaped key, escaped key is appended as the last path segment when there is no {key}.
+// Key is escaped for path, so ErrInvalidURLTemplate is returned when it would land in query or fragment:
+//
+//	virtual-hosted: https://{bucket}.s3.{region}.amazonaws.com
+//	path-style:     https://s3.{region}.amazonaws.com/{bucket}
+//	custom domain:  https://files.example.com
+//	CDN:            https://cdn.example.com/{key}?v=1
+func (awsConn *AWSConnector) ObjectURL(key string) (string, error) {
+	if key == "" {
+		return "", cerr.ErrFuncArg{}.Invalidate("key")
+	}
+	tmpl := awsConn.AWSInfo.URL
+	if strings.Contains(tmpl, urlRegionPlaceholder) && awsConn.AWSInfo.Region == "" {
+		return "", ErrEmptyRegion
+	}
+
+	// key lands at {key} or at the end of template
+	keyAt := strings.Index(tmpl, urlKeyPlaceholder)
+	if keyAt < 0 {
+		keyAt = len(tmpl)
+	}
+	if query := strings.IndexAny(tmpl, "?#"); query >= 0 && query < keyAt {
+		return "", ErrInvalidURLTemplate
+	}
+
+	escapedKey := escapeKey(key)
+	rawURL := strings.NewReplacer(
+		urlBucketPlaceholder, url.PathEscape(awsConn.AWSInfo.Bucket),
+		urlRegionPlaceholder, url.PathEscape(awsConn.AWSInfo.Region),
+		urlKeyPlaceholder, escapedKey,
+	).Replace(tmpl)
+	if !strings.Contains(tmpl, urlKeyPlaceholder) {
+		rawURL = strings.TrimSuffix(rawURL, "/") + "/" + escapedKey
+	}
+
+	// unknown placeholders are left as is, escaped key has no braces
+	if strings.ContainsAny(rawURL, "{}") {
+		return "", ErrInvalidURLTemplate
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", ErrInvalidURLTemplate
+	}
+	return rawURL, nil
+}
+
+// PutFileURL puts input file to aws the same way as PutFile and returns key and url for downloading this file.
+// Key and checksum are returned with error, when the file is stored, but url can't be built
+func (awsConn *AWSConnector) PutFileURL(ctx context.Context, fileObj *string, opts ...PutOption) (PutResult, error) {
+	result, err := awsConn.putFile(ctx, fileObj, opts)
+	if err != nil {
+		return PutResult{}, err
+	}
+	result.URL, err = awsConn.ObjectURL(result.Key)
+	return result, err
+}
+
+// escapeKey escapes every segment of key keeping slashes between them.
+// Plus is escaped as well, because S3 may read it as space
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segments[i]), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"context"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_ObjectURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc    string
+		url     string
+		region  string
+		key     string
+		wantURL string
+		wantErr error
+	}{
+		{
+			desc:    "Should returns error when key is empty",
+			url:     "https://files.example.com",
+			wantErr: cerr.NewErrFuncArgMock("key", "ObjectURL"),
+		},
+		{
+			desc:    "Should build virtual-hosted url",
+			url:     "https://{bucket}.s3.{region}.amazonaws.com",
+			region:  "eu-west-1",
+			key:     "dir/time_111_img.png",
+			wantURL: "https://test-bucket.s3.eu-west-1.amazonaws.com/dir/time_111_img.png",
+		},
+		{
+			desc:    "Should build path-style url",
+			url:     "https://s3.{region}.amazonaws.com/{bucket}/",
+			region:  "eu-west-1",
+			key:     "img.png",
+			wantURL: "https://s3.eu-west-1.amazonaws.com/test-bucket/img.png",
+		},
+		{
+			desc:    "Should build CDN url with key placeholder",
+			url:     "https://cdn.example.com/assets/{key}?v=1",
+			key:     "a b/c+d?.png",
+			wantURL: "https://cdn.example.com/assets/a%20b/c%2Bd%3F.png?v=1",
+		},
+		{
+			desc:    "Should returns error when key placeholder is in query",
+			url:     "https://cdn.example.com/{bucket}?x=1/{key}",
+			key:     "img.png",
+			wantErr: ErrInvalidURLTemplate,
+		},
+		{
+			desc:    "Should returns error when key would be appended to query",
+			url:     "https://cdn.example.com/assets?v=1",
+			key:     "img.png",
+			wantErr: ErrInvalidURLTemplate,
+		},
+		{
+			desc:    "Should returns error when region is needed but empty",
+			url:     "https://{bucket}.s3.{region}.amazonaws.com",
+			key:     "img.png",
+			wantErr: ErrEmptyRegion,
+		},
+		{
+			desc:    "Should returns error when template has unknown placeholder",
+			url:     "https://{host}/files",
+			key:     "img.png",
+			wantErr: ErrInvalidURLTemplate,
+		},
+		{
+			desc:    "Should returns error when url is not absolute",
+			url:     "files.example.com",
+			key:     "img.png",
+			wantErr: ErrInvalidURLTemplate,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsInfo := AWSInfo{
+				Bucket: "test-bucket",
+				URL:    c.url,
+				Region: c.region,
+			}
+			awsConn, _ := NewAWSConnector(awsInfo, time.Minute, NewMockiS3Client(ctrl), NewMockiGenerate(ctrl))
+			got, gotErr := awsConn.ObjectURL(c.key)
+
+			// assert
+			assert.Equal(t, c.wantURL, got)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PutFileURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	generator := NewMockiGenerate(ctrl)
+	generator.EXPECT().GenerateTime().Return("time")
+	generator.EXPECT().GenerateUUID().Return("111")
+
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().PutObjectWithContext(gomock.Any(), gomock.Any()).Return(nil)
+
+	awsInfo := AWSInfo{
+		Bucket: "test-bucket",
+		URL:    "https://cdn.example.com",
+	}
+	awsConn, _ := NewAWSConnector(awsInfo, time.Minute, svc, generator)
+	fileObj := "name:{my img.png},dataUrl:{data:image/png;base64,iVBggg==}"
+
+	// actual
+	got, gotErr := awsConn.PutFileURL(context.Background(), &fileObj)
+
+	// assert
+	assert.NoError(t, gotErr)
+	assert.Equal(t, PutResult{
+		Key: "time_111_my_img.png",
+		URL: "https://cdn.example.com/time_111_my_img.png",
+		Checksum: Checksum{
+			MD5:    "4e4074ffb27aa72b1dae41f3ff2af8ab",
+			SHA256: "37f4cdf1125ce2cb592298da3e38b5cf2582cf379d94e1bb4471c06be46dfe09",
+		},
+	}, got)
+}
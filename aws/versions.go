@@ -0,0 +1,162 @@
+package aws
+
+import (
+	"context"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"sort"
+	"time"
+)
+
+// ObjectVersion is version or delete marker of object in versioned bucket
+type ObjectVersion struct {
+	Key       string
+	VersionID string
+	// IsLatest is true for current version of the key
+	IsLatest bool
+	// IsDeleteMarker is true when the version marks the key as deleted, it has no body
+	IsDeleteMarker bool
+	Size           int64
+	ETag           string
+	LastModified   time.Time
+}
+
+// WithVersionID selects version of the object to read
+func WithVersionID(versionID string) GetOption {
+	return func(opts *getOptions) {
+		opts.versionID = versionID
+	}
+}
+
+// readVersionID returns version of one read, nil means current version
+func readVersionID(opts []GetOption) *string {
+	o := newGetOptions(opts)
+	if o.versionID == "" {
+		return nil
+	}
+	return aws.String(o.versionID)
+}
+
+// ListVersions returns versions and delete markers of the key, the newest first
+func (awsConn *AWSConnector) ListVersions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	if key == "" {
+		return nil, cerr.ErrFuncArg{}.Invalidate("key")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	var versions []ObjectVersion
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(awsConn.AWSInfo.Bucket),
+		Prefix: aws.String(key),
+	}
+	for {
+		var out *s3.ListObjectVersionsOutput
+		err := awsConn.retry(ctx, func(ctx context.Context) (err error) {
+			out, err = awsConn.svc.ListObjectVersionsWithContext(ctx, input)
+			return err
+		})
+		if err != nil {
+			return nil, newS3Error("ListObjectVersions", awsConn.AWSInfo.Bucket, key, err)
+		}
+
+		// prefix matches longer keys too, they are skipped
+		for _, v := range out.Versions {
+			if aws.StringValue(v.Key) == key {
+				versions = append(versions, ObjectVersion{
+					Key:          key,
+					VersionID:    aws.StringValue(v.VersionId),
+					IsLatest:     aws.BoolValue(v.IsLatest),
+					Size:         aws.Int64Value(v.Size),
+					ETag:         aws.StringValue(v.ETag),
+					LastModified: aws.TimeValue(v.LastModified),
+				})
+			}
+		}
+		for _, m := range out.DeleteMarkers {
+			if aws.StringValue(m.Key) == key {
+				versions = append(versions, ObjectVersion{
+					Key:            key,
+					VersionID:      aws.StringValue(m.VersionId),
+					IsLatest:       aws.BoolValue(m.IsLatest),
+					IsDeleteMarker: true,
+					LastModified:   aws.TimeValue(m.LastModified),
+				})
+			}
+		}
+
+		// keys are listed in order and key is the first one with such prefix,
+		// so the next page has no versions of key when listing has moved to another key
+		if !aws.BoolValue(out.IsTruncated) || aws.StringValue(out.NextKeyMarker) != key {
+			break
+		}
+		input.KeyMarker, input.VersionIdMarker = out.NextKeyMarker, out.NextVersionIdMarker
+	}
+
+	// versions and delete markers are returned by separate lists, so they are merged by time
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].IsLatest != versions[j].IsLatest {
+			return versions[i].IsLatest
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions, nil
+}
+
+// RestoreVersion makes copy of the version current version of the key, newer versions are kept.
+// It also brings back the key which is deleted by delete marker
+func (awsConn *AWSConnector) RestoreVersion(ctx context.Context, key, versionID string) error {
+	if versionID == "" {
+		return cerr.ErrFuncArg{}.Invalidate("versionID")
+	}
+	return awsConn.Copy(ctx, ObjectRef{Key: key, VersionID: versionID}, ObjectRef{Key: key})
+}
+
+// DeleteVersion permanently removes the version or delete marker of the key.
+// Removing delete marker which is current version brings the key back
+func (awsConn *AWSConnector) DeleteVersion(ctx context.Context, key, versionID string) error {
+	if key == "" {
+		return cerr.ErrFuncArg{}.Invalidate("key")
+	}
+	if versionID == "" {
+		return cerr.ErrFuncArg{}.Invalidate("versionID")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, awsConn.timeout)
+	defer cancelFn()
+
+	err := awsConn.retry(ctx, func(ctx context.Context) error {
+		return awsConn.svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket:    aws.String(awsConn.AWSInfo.Bucket),
+			Key:       aws.String(key),
+			VersionId: aws.String(versionID),
+		})
+	})
+	if err != nil {
+		return newS3Error("DeleteObject", awsConn.AWSInfo.Bucket, key, err)
+	}
+	return nil
+}
+
+// PurgeVersions permanently removes all versions and delete markers of the key.
+// Returns ErrBatch with every version which was not removed
+func (awsConn *AWSConnector) PurgeVersions(ctx context.Context, key string) error {
+	versions, err := awsConn.ListVersions(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	objects := make([]*s3.ObjectIdentifier, len(versions))
+	for i := range versions {
+		objects[i] = &s3.ObjectIdentifier{Key: aws.String(key), VersionId: aws.String(versions[i].VersionID)}
+	}
+	return awsConn.deleteObjects(ctx, "purge", objects)
+}
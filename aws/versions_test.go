@@ -0,0 +1,314 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientStatusUpdater_ListVersions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t1 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2, t3 := t1.Add(time.Hour), t1.Add(2*time.Hour)
+
+	// arrange
+	cases := []struct {
+		desc         string
+		svc          *MockiS3Client
+		key          string
+		wantVersions []ObjectVersion
+		wantErr      error
+	}{
+		{
+			desc: "Should merge versions and delete markers of all pages, the newest first",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().ListObjectVersionsWithContext(gomock.Any(), &s3.ListObjectVersionsInput{
+					Bucket: aws.String("test bucket"),
+					Prefix: aws.String("file.txt"),
+				}).Return(&s3.ListObjectVersionsOutput{
+					IsTruncated:         aws.Bool(true),
+					NextKeyMarker:       aws.String("file.txt"),
+					NextVersionIdMarker: aws.String("v2"),
+					DeleteMarkers: []*s3.DeleteMarkerEntry{
+						{Key: aws.String("file.txt"), VersionId: aws.String("v3"), IsLatest: aws.Bool(true), LastModified: aws.Time(t3)},
+					},
+					Versions: []*s3.ObjectVersion{
+						{Key: aws.String("file.txt"), VersionId: aws.String("v2"), IsLatest: aws.Bool(false), Size: aws.Int64(2), ETag: aws.String(`"2"`), LastModified: aws.Time(t2)},
+					},
+				}, nil)
+				m.EXPECT().ListObjectVersionsWithContext(gomock.Any(), &s3.ListObjectVersionsInput{
+					Bucket:          aws.String("test bucket"),
+					Prefix:          aws.String("file.txt"),
+					KeyMarker:       aws.String("file.txt"),
+					VersionIdMarker: aws.String("v2"),
+				}).Return(&s3.ListObjectVersionsOutput{
+					IsTruncated: aws.Bool(false),
+					Versions: []*s3.ObjectVersion{
+						{Key: aws.String("file.txt"), VersionId: aws.String("v1"), IsLatest: aws.Bool(false), Size: aws.Int64(1), ETag: aws.String(`"1"`), LastModified: aws.Time(t1)},
+						{Key: aws.String("file.txt.bak"), VersionId: aws.String("b1"), IsLatest: aws.Bool(true), LastModified: aws.Time(t1)},
+					},
+				}, nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			key: "file.txt",
+			wantVersions: []ObjectVersion{
+				{Key: "file.txt", VersionID: "v3", IsLatest: true, IsDeleteMarker: true, LastModified: t3},
+				{Key: "file.txt", VersionID: "v2", Size: 2, ETag: `"2"`, LastModified: t2},
+				{Key: "file.txt", VersionID: "v1", Size: 1, ETag: `"1"`, LastModified: t1},
+			},
+			wantErr: nil,
+		},
+		{
+			desc: "Should stop paging when listing has moved past the key",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().ListObjectVersionsWithContext(gomock.Any(), gomock.Any()).Return(&s3.ListObjectVersionsOutput{
+					IsTruncated:         aws.Bool(true),
+					NextKeyMarker:       aws.String("file.txt.bak"),
+					NextVersionIdMarker: aws.String("b1"),
+					Versions: []*s3.ObjectVersion{
+						{Key: aws.String("file.txt"), VersionId: aws.String("v1"), IsLatest: aws.Bool(true), Size: aws.Int64(1), ETag: aws.String(`"1"`), LastModified: aws.Time(t1)},
+						{Key: aws.String("file.txt.bak"), VersionId: aws.String("b1"), IsLatest: aws.Bool(true), LastModified: aws.Time(t1)},
+					},
+				}, nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			key: "file.txt",
+			wantVersions: []ObjectVersion{
+				{Key: "file.txt", VersionID: "v1", IsLatest: true, Size: 1, ETag: `"1"`, LastModified: t1},
+			},
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns error when ListObjectVersions failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().ListObjectVersionsWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			key:          "file.txt",
+			wantVersions: nil,
+			wantErr: S3Error{
+				Operation: "ListObjectVersions",
+				Bucket:    "test bucket",
+				Key:       "file.txt",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{
+			desc:         "Should returns error when key is empty",
+			svc:          NewMockiS3Client(ctrl),
+			key:          "",
+			wantVersions: nil,
+			wantErr:      cerr.NewErrFuncArgMock("key", "ListVersions"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			got, gotErr := awsConn.ListVersions(context.Background(), c.key)
+
+			// assert
+			assert.Equal(t, c.wantVersions, got)
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_GetFileVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	svc := NewMockiS3Client(ctrl)
+	svc.EXPECT().GetObjectWithContext(gomock.Any(), &s3.GetObjectInput{
+		Bucket:    aws.String("test bucket"),
+		Key:       aws.String("file.txt"),
+		VersionId: aws.String("v1"),
+	}).Return(&s3.GetObjectOutput{Body: ioutil.NopCloser(strings.NewReader("old"))}, nil)
+	awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, svc, NewMockiGenerate(ctrl))
+
+	// actual
+	body, _, err := awsConn.GetFile(context.Background(), "file.txt", WithVersionID("v1"))
+
+	// assert
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(body)
+	assert.Equal(t, "old", string(data))
+	assert.NoError(t, body.Close())
+}
+
+func TestClientStatusUpdater_RestoreVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc      string
+		svc       *MockiS3Client
+		versionID string
+		wantErr   error
+	}{
+		{
+			desc: "Should copy version over the key",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().CopyObjectWithContext(gomock.Any(), &s3.CopyObjectInput{
+					Bucket:     aws.String("test bucket"),
+					Key:        aws.String("dir/file.txt"),
+					CopySource: aws.String("test%20bucket%2Fdir%2Ffile.txt?versionId=v%2B1"),
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			versionID: "v+1",
+			wantErr:   nil,
+		},
+		{
+			desc:      "Should returns error when version is empty",
+			svc:       NewMockiS3Client(ctrl),
+			versionID: "",
+			wantErr:   cerr.NewErrFuncArgMock("versionID", "RestoreVersion"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := awsConn.RestoreVersion(context.Background(), "dir/file.txt", c.versionID)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_DeleteVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// arrange
+	cases := []struct {
+		desc      string
+		svc       *MockiS3Client
+		key       string
+		versionID string
+		wantErr   error
+	}{
+		{
+			desc: "Should delete version",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), &s3.DeleteObjectInput{
+					Bucket:    aws.String("test bucket"),
+					Key:       aws.String("file.txt"),
+					VersionId: aws.String("v1"),
+				}).Return(nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			key:       "file.txt",
+			versionID: "v1",
+			wantErr:   nil,
+		},
+		{
+			desc: "Should returns error when DeleteObject failed",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().DeleteObjectWithContext(gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+				return m
+			}(NewMockiS3Client(ctrl)),
+			key:       "file.txt",
+			versionID: "v1",
+			wantErr: S3Error{
+				Operation: "DeleteObject",
+				Bucket:    "test bucket",
+				Key:       "file.txt",
+				Attempts:  1,
+				Err:       errors.New("test error"),
+			},
+		},
+		{desc: "Should returns error when key is empty", svc: NewMockiS3Client(ctrl), versionID: "v1", wantErr: cerr.NewErrFuncArgMock("key", "DeleteVersion")},
+		{desc: "Should returns error when version is empty", svc: NewMockiS3Client(ctrl), key: "file.txt", wantErr: cerr.NewErrFuncArgMock("versionID", "DeleteVersion")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := awsConn.DeleteVersion(context.Background(), c.key, c.versionID)
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
+
+func TestClientStatusUpdater_PurgeVersions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	listed := &s3.ListObjectVersionsOutput{
+		IsTruncated:   aws.Bool(false),
+		DeleteMarkers: []*s3.DeleteMarkerEntry{{Key: aws.String("file.txt"), VersionId: aws.String("v2"), IsLatest: aws.Bool(true)}},
+		Versions:      []*s3.ObjectVersion{{Key: aws.String("file.txt"), VersionId: aws.String("v1")}},
+	}
+
+	// arrange
+	cases := []struct {
+		desc    string
+		svc     *MockiS3Client
+		wantErr error
+	}{
+		{
+			desc: "Should delete all versions and delete markers",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().ListObjectVersionsWithContext(gomock.Any(), gomock.Any()).Return(listed, nil)
+				m.EXPECT().DeleteObjectsWithContext(gomock.Any(), &s3.DeleteObjectsInput{
+					Bucket: aws.String("test bucket"),
+					Delete: &s3.Delete{
+						Objects: []*s3.ObjectIdentifier{
+							{Key: aws.String("file.txt"), VersionId: aws.String("v2")},
+							{Key: aws.String("file.txt"), VersionId: aws.String("v1")},
+						},
+						Quiet: aws.Bool(true),
+					},
+				}).Return(&s3.DeleteObjectsOutput{}, nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: nil,
+		},
+		{
+			desc: "Should returns ErrBatch with failed versions",
+			svc: func(m *MockiS3Client) *MockiS3Client {
+				m.EXPECT().ListObjectVersionsWithContext(gomock.Any(), gomock.Any()).Return(listed, nil)
+				m.EXPECT().DeleteObjectsWithContext(gomock.Any(), gomock.Any()).Return(&s3.DeleteObjectsOutput{
+					Errors: []*s3.Error{{Key: aws.String("file.txt"), VersionId: aws.String("v1"), Code: aws.String("AccessDenied"), Message: aws.String("denied")}},
+				}, nil)
+				return m
+			}(NewMockiS3Client(ctrl)),
+			wantErr: ErrBatch{
+				Operation: "purge",
+				Keys:      []KeyError{{Key: "file.txt", VersionID: "v1", Code: "AccessDenied", Message: "denied"}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			// actual
+			awsConn, _ := NewAWSConnector(AWSInfo{Bucket: "test bucket", URL: "test URL"}, time.Minute, c.svc, NewMockiGenerate(ctrl))
+			gotErr := awsConn.PurgeVersions(context.Background(), "file.txt")
+
+			// assert
+			assert.Equal(t, c.wantErr, gotErr)
+		})
+	}
+}
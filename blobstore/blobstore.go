@@ -0,0 +1,153 @@
+// Package blobstore describes storage of objects by keys, which doesn't depend on the backend:
+// S3 for production, files for local development and memory for tests
+package blobstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/Stanly1995/golibs/cerr"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sniffLen is count of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+const (
+	// ErrInvalidKey is error, which is returned when key is empty, starts with slash or has . or .. segments
+	ErrInvalidKey = cerr.New("invalid object key")
+
+	// ErrInvalidExpire is error, which is returned when expire of presigned url is not positive
+	ErrInvalidExpire = cerr.New("invalid presign expire")
+)
+
+// BlobStore stores objects by slash separated keys.
+// Every backend returns error which is cerr.ErrNotFound when there is no such key
+type BlobStore interface {
+	// Put stores r under key, existing object is replaced
+	Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) error
+	// Get returns body and info of the object, caller must close the body
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Stat returns info of the object without its body
+	Stat(ctx context.Context, key string) (Info, error)
+	// List calls fn for every object which key starts with prefix in order of keys,
+	// stops on the first error of fn and returns it. ContentType and Metadata may be empty.
+	// Returns ErrInvalidKey when prefix fails ValidatePrefix
+	List(ctx context.Context, prefix string, fn func(info Info) error) error
+	// Delete removes the object, deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns url for downloading the object until expire passes
+	PresignGet(ctx context.Context, key string, expire time.Duration) (string, error)
+}
+
+// Info describes stored object
+type Info struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	// Metadata keys are in canonical header form, the same way as S3 returns them
+	Metadata map[string]string
+}
+
+// PutOption configures one Put
+type PutOption func(opts *PutOptions)
+
+// PutOptions are options of one Put, backends build them by NewPutOptions
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
+
+// WithContentType sets content type of the object
+func WithContentType(contentType string) PutOption {
+	return func(opts *PutOptions) {
+		opts.ContentType = contentType
+	}
+}
+
+// WithMetadata attaches user metadata to the object
+func WithMetadata(metadata map[string]string) PutOption {
+	return func(opts *PutOptions) {
+		if opts.Metadata == nil {
+			opts.Metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			opts.Metadata[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+}
+
+// NewPutOptions applies opts
+func NewPutOptions(opts ...PutOption) PutOptions {
+	var o PutOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ValidateKey returns ErrInvalidKey when key can't be stored by every backend
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// ValidatePrefix returns ErrInvalidKey when prefix can't start any valid key.
+// Directories of prefix are validated the same way as keys, the last segment may be partial
+func ValidatePrefix(prefix string) error {
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		return ValidateKey(prefix[:i])
+	}
+	return nil
+}
+
+// copyObject copies r to w and describes copied data. Empty content type is detected by the first bytes,
+// etag is md5 of data the same way as S3 does for single part uploads
+func copyObject(w io.Writer, key string, r io.Reader, o PutOptions) (Info, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	contentType := o.ContentType
+	if contentType == "" {
+		// error of Peek is returned by Copy
+		head, _ := br.Peek(sniffLen)
+		contentType = http.DetectContentType(head)
+	}
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), br)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{
+		Key:          key,
+		Size:         size,
+		ContentType:  contentType,
+		ETag:         `"` + hex.EncodeToString(hash.Sum(nil)) + `"`,
+		LastModified: time.Now().UTC(),
+		Metadata:     o.Metadata,
+	}, nil
+}
+
+// copyInfo copies metadata, so callers can't modify stored info
+func copyInfo(info Info) Info {
+	if info.Metadata != nil {
+		metadata := make(map[string]string, len(info.Metadata))
+		for k, v := range info.Metadata {
+			metadata[k] = v
+		}
+		info.Metadata = metadata
+	}
+	return info
+}
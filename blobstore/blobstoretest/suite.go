@@ -0,0 +1,219 @@
+// Package blobstoretest is conformance test suite, which every blobstore.BlobStore backend must pass
+package blobstoretest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/Stanly1995/golibs/blobstore"
+	"github.com/Stanly1995/golibs/cerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Run runs the suite, newStore must return empty store for every test
+func Run(t *testing.T, newStore func(t *testing.T) blobstore.BlobStore) {
+	tests := []struct {
+		desc string
+		test func(t *testing.T, store blobstore.BlobStore)
+	}{
+		{desc: "Should get stored object", test: testPutGet},
+		{desc: "Should replace object", test: testOverwrite},
+		{desc: "Should stat object", test: testStat},
+		{desc: "Should returns cerr.ErrNotFound for missing object", test: testNotFound},
+		{desc: "Should list objects by prefix in order of keys", test: testList},
+		{desc: "Should stop listing on error of callback", test: testListStop},
+		{desc: "Should delete object", test: testDelete},
+		{desc: "Should returns ErrInvalidKey", test: testInvalidKey},
+		{desc: "Should returns ErrInvalidKey for prefix out of keys", test: testInvalidPrefix},
+		{desc: "Should presign url of object", test: testPresignGet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func put(t *testing.T, store blobstore.BlobStore, key, body string, opts ...blobstore.PutOption) {
+	require.NoError(t, store.Put(context.Background(), key, strings.NewReader(body), opts...))
+}
+
+func testPutGet(t *testing.T, store blobstore.BlobStore) {
+	// arrange
+	put(t, store, "dir/file.txt", "hello",
+		blobstore.WithContentType("text/plain"),
+		blobstore.WithMetadata(map[string]string{"owner": "me"}))
+
+	// actual
+	body, info, err := store.Get(context.Background(), "dir/file.txt")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+
+	// assert
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "dir/file.txt", info.Key)
+	assert.Equal(t, int64(5), info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, "me", info.Metadata["Owner"])
+}
+
+func testOverwrite(t *testing.T, store blobstore.BlobStore) {
+	// arrange
+	put(t, store, "file.txt", "first")
+	put(t, store, "file.txt", "second body")
+
+	// actual
+	body, info, err := store.Get(context.Background(), "file.txt")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+
+	// assert
+	assert.Equal(t, "second body", string(data))
+	assert.Equal(t, int64(len("second body")), info.Size)
+}
+
+func testStat(t *testing.T, store blobstore.BlobStore) {
+	// arrange
+	put(t, store, "a.bin", "aaaa", blobstore.WithContentType("application/octet-stream"))
+	put(t, store, "b.bin", "bbbb", blobstore.WithContentType("application/octet-stream"))
+
+	// actual
+	a, errA := store.Stat(context.Background(), "a.bin")
+	b, errB := store.Stat(context.Background(), "b.bin")
+
+	// assert
+	require.NoError(t, errA)
+	require.NoError(t, errB)
+	assert.Equal(t, "a.bin", a.Key)
+	assert.Equal(t, int64(4), a.Size)
+	assert.Equal(t, "application/octet-stream", a.ContentType)
+	assert.NotEmpty(t, a.ETag)
+	assert.NotEqual(t, a.ETag, b.ETag)
+	assert.WithinDuration(t, time.Now(), a.LastModified, time.Hour)
+}
+
+func testNotFound(t *testing.T, store blobstore.BlobStore) {
+	// actual
+	_, _, getErr := store.Get(context.Background(), "missing")
+	_, statErr := store.Stat(context.Background(), "missing")
+
+	// assert
+	assert.True(t, errors.Is(getErr, cerr.ErrNotFound), getErr)
+	assert.True(t, errors.Is(statErr, cerr.ErrNotFound), statErr)
+}
+
+func testList(t *testing.T, store blobstore.BlobStore) {
+	// arrange
+	for _, key := range []string{"b/1", "a/2", "a.txt", "a/1", "a/sub/3"} {
+		put(t, store, key, key)
+	}
+
+	cases := []struct {
+		prefix   string
+		wantKeys []string
+	}{
+		{prefix: "", wantKeys: []string{"a.txt", "a/1", "a/2", "a/sub/3", "b/1"}},
+		{prefix: "a/", wantKeys: []string{"a/1", "a/2", "a/sub/3"}},
+		{prefix: "a", wantKeys: []string{"a.txt", "a/1", "a/2", "a/sub/3"}},
+		{prefix: "a/s", wantKeys: []string{"a/sub/3"}},
+		{prefix: "c/", wantKeys: nil},
+	}
+
+	for _, c := range cases {
+		// actual
+		var gotKeys []string
+		err := store.List(context.Background(), c.prefix, func(info blobstore.Info) error {
+			assert.Equal(t, int64(len(info.Key)), info.Size)
+			gotKeys = append(gotKeys, info.Key)
+			return nil
+		})
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, c.wantKeys, gotKeys, c.prefix)
+	}
+}
+
+func testListStop(t *testing.T, store blobstore.BlobStore) {
+	// arrange
+	put(t, store, "1", "1")
+	put(t, store, "2", "2")
+	stop := errors.New("stop")
+
+	// actual
+	calls := 0
+	err := store.List(context.Background(), "", func(info blobstore.Info) error {
+		calls++
+		return stop
+	})
+
+	// assert
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func testDelete(t *testing.T, store blobstore.BlobStore) {
+	// arrange
+	put(t, store, "dir/file.txt", "body")
+
+	// actual
+	err := store.Delete(context.Background(), "dir/file.txt")
+	missingErr := store.Delete(context.Background(), "dir/file.txt")
+
+	// assert
+	assert.NoError(t, err)
+	assert.NoError(t, missingErr)
+	_, statErr := store.Stat(context.Background(), "dir/file.txt")
+	assert.True(t, errors.Is(statErr, cerr.ErrNotFound), statErr)
+}
+
+func testInvalidKey(t *testing.T, store blobstore.BlobStore) {
+	for _, key := range []string{"", "/abs", "dir/", "a//b", "../up", "a/./b"} {
+		// actual
+		putErr := store.Put(context.Background(), key, bytes.NewReader(nil))
+		_, statErr := store.Stat(context.Background(), key)
+
+		// assert
+		assert.Equal(t, blobstore.ErrInvalidKey, putErr, key)
+		assert.Equal(t, blobstore.ErrInvalidKey, statErr, key)
+	}
+}
+
+func testInvalidPrefix(t *testing.T, store blobstore.BlobStore) {
+	for _, prefix := range []string{"/abs", "../../x/", "a//b", "a/../b"} {
+		// actual
+		calls := 0
+		err := store.List(context.Background(), prefix, func(info blobstore.Info) error {
+			calls++
+			return nil
+		})
+
+		// assert
+		assert.Equal(t, blobstore.ErrInvalidKey, err, prefix)
+		assert.Equal(t, 0, calls, prefix)
+	}
+}
+
+func testPresignGet(t *testing.T, store blobstore.BlobStore) {
+	// arrange
+	put(t, store, "dir/file.txt", "body")
+
+	// actual
+	got, err := store.PresignGet(context.Background(), "dir/file.txt", time.Hour)
+	_, expireErr := store.PresignGet(context.Background(), "dir/file.txt", 0)
+
+	// assert
+	assert.NoError(t, err)
+	assert.Contains(t, got, "dir/file.txt")
+	assert.Equal(t, blobstore.ErrInvalidExpire, expireErr)
+}
@@ -0,0 +1,258 @@
+package blobstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Directories of FileStore root
+const (
+	fsObjectsDir = "objects"
+	fsMetaDir    = "meta"
+	fsTmpDir     = "tmp"
+)
+
+// FileStore keeps objects as files under root directory, it's intended for local development.
+// Unlike S3 it can't store both "a" and "a/b" keys, because "a" can't be file and directory at once
+type FileStore struct {
+	root string
+}
+
+// fileMeta is stored next to the object, because file system keeps only its body
+type fileMeta struct {
+	ContentType string            `json:"contentType"`
+	ETag        string            `json:"etag"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// NewFileStore is constructor, it creates root directory when it doesn't exist
+func NewFileStore(root string) (*FileStore, error) {
+	if root == "" {
+		return nil, cerr.ErrFuncArg{}.Invalidate("root")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{fsObjectsDir, fsMetaDir, fsTmpDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &FileStore{root: root}, nil
+}
+
+// Put stores r under key. Body is written to temporary file and renamed,
+// so readers never see partially written object
+func (f *FileStore) Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(f.root, fsTmpDir), "object-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	info, err := copyObject(tmp, key, r, NewPutOptions(opts...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(fileMeta{ContentType: info.ContentType, ETag: info.ETag, Metadata: info.Metadata})
+	if err != nil {
+		return err
+	}
+	if err = f.writeFile(f.metaPath(key), meta); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(f.objectPath(key)), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.objectPath(key))
+}
+
+// Get returns body and info of the object
+func (f *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	info, err := f.Stat(ctx, key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	file, err := os.Open(f.objectPath(key))
+	if err != nil {
+		return nil, Info{}, f.pathError(key, err)
+	}
+	return file, info, nil
+}
+
+// Stat returns info of the object
+func (f *FileStore) Stat(ctx context.Context, key string) (Info, error) {
+	if err := ValidateKey(key); err != nil {
+		return Info{}, err
+	}
+	stat, err := os.Stat(f.objectPath(key))
+	if err != nil {
+		return Info{}, f.pathError(key, err)
+	}
+	if stat.IsDir() {
+		return Info{}, fmt.Errorf("object %s: %w", key, cerr.ErrNotFound)
+	}
+
+	info := Info{
+		Key:          key,
+		Size:         stat.Size(),
+		LastModified: stat.ModTime().UTC(),
+	}
+	data, err := ioutil.ReadFile(f.metaPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return Info{}, err
+	}
+	// files copied into root by hand have no meta
+	if err == nil {
+		var meta fileMeta
+		if err = json.Unmarshal(data, &meta); err != nil {
+			return Info{}, err
+		}
+		info.ContentType, info.ETag, info.Metadata = meta.ContentType, meta.ETag, meta.Metadata
+	}
+	return info, nil
+}
+
+// List calls fn for every object which key starts with prefix
+func (f *FileStore) List(ctx context.Context, prefix string, fn func(info Info) error) error {
+	if err := ValidatePrefix(prefix); err != nil {
+		return err
+	}
+	objectsRoot := filepath.Join(f.root, fsObjectsDir)
+	// walking starts from the deepest directory of prefix
+	start := objectsRoot
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		start = filepath.Join(objectsRoot, filepath.FromSlash(prefix[:i]))
+	}
+
+	var keys []string
+	err := filepath.Walk(start, func(p string, stat os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if stat.IsDir() {
+			return ctx.Err()
+		}
+		rel, err := filepath.Rel(objectsRoot, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Walk sorts names of every directory, but "a.txt" goes after "a/b" then
+	sort.Strings(keys)
+	for _, key := range keys {
+		info, err := f.Stat(ctx, key)
+		if err != nil {
+			return err
+		}
+		if err = fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the object and its empty parent directories
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	for _, p := range []string{f.objectPath(key), f.metaPath(key)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	f.removeEmptyDirs(filepath.Dir(f.objectPath(key)), filepath.Join(f.root, fsObjectsDir))
+	f.removeEmptyDirs(filepath.Dir(f.metaPath(key)), filepath.Join(f.root, fsMetaDir))
+	return nil
+}
+
+// PresignGet returns file url of the object, expire is only validated
+func (f *FileStore) PresignGet(ctx context.Context, key string, expire time.Duration) (string, error) {
+	if expire <= 0 {
+		return "", ErrInvalidExpire
+	}
+	if _, err := f.Stat(ctx, key); err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(f.objectPath(key))}).String(), nil
+}
+
+func (f *FileStore) objectPath(key string) string {
+	return filepath.Join(f.root, fsObjectsDir, filepath.FromSlash(key))
+}
+
+func (f *FileStore) metaPath(key string) string {
+	return filepath.Join(f.root, fsMetaDir, filepath.FromSlash(key)+".json")
+}
+
+// writeFile writes data to temporary file and renames it to p
+func (f *FileStore) writeFile(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Join(f.root, fsTmpDir), "meta-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// pathError turns missing file into cerr.ErrNotFound
+func (f *FileStore) pathError(key string, err error) error {
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+		return fmt.Errorf("object %s: %w", key, cerr.ErrNotFound)
+	}
+	return err
+}
+
+// removeEmptyDirs removes dir and its parents until stop while they are empty
+func (f *FileStore) removeEmptyDirs(dir, stop string) {
+	for dir != stop && strings.HasPrefix(dir, stop) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
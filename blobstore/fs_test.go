@@ -0,0 +1,16 @@
+package blobstore_test
+
+import (
+	"github.com/Stanly1995/golibs/blobstore"
+	"github.com/Stanly1995/golibs/blobstore/blobstoretest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	blobstoretest.Run(t, func(t *testing.T) blobstore.BlobStore {
+		store, err := blobstore.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+		return store
+	})
+}
@@ -0,0 +1,127 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/Stanly1995/golibs/cerr"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps objects in memory, it's intended for tests
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	info Info
+}
+
+// NewMemoryStore is constructor
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+// Put stores r under key
+func (m *MemoryStore) Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	info, err := copyObject(&buf, key, r, NewPutOptions(opts...))
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.objects[key] = memoryObject{data: buf.Bytes(), info: info}
+	m.mu.Unlock()
+	return nil
+}
+
+// Get returns body and info of the object
+func (m *MemoryStore) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	obj, err := m.object(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(obj.data)), obj.info, nil
+}
+
+// Stat returns info of the object
+func (m *MemoryStore) Stat(ctx context.Context, key string) (Info, error) {
+	obj, err := m.object(key)
+	if err != nil {
+		return Info{}, err
+	}
+	return obj.info, nil
+}
+
+// List calls fn for every object which key starts with prefix
+func (m *MemoryStore) List(ctx context.Context, prefix string, fn func(info Info) error) error {
+	if err := ValidatePrefix(prefix); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	infos := make([]Info, 0, len(m.objects))
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, copyInfo(obj.info))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the object
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// PresignGet returns memory:///key url, which only identifies the object
+func (m *MemoryStore) PresignGet(ctx context.Context, key string, expire time.Duration) (string, error) {
+	if expire <= 0 {
+		return "", ErrInvalidExpire
+	}
+	if _, err := m.object(key); err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "memory", Path: "/" + key}).String(), nil
+}
+
+func (m *MemoryStore) object(key string) (memoryObject, error) {
+	if err := ValidateKey(key); err != nil {
+		return memoryObject{}, err
+	}
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return memoryObject{}, fmt.Errorf("object %s: %w", key, cerr.ErrNotFound)
+	}
+	obj.info = copyInfo(obj.info)
+	return obj, nil
+}
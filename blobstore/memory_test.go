@@ -0,0 +1,13 @@
+package blobstore_test
+
+import (
+	"github.com/Stanly1995/golibs/blobstore"
+	"github.com/Stanly1995/golibs/blobstore/blobstoretest"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	blobstoretest.Run(t, func(t *testing.T) blobstore.BlobStore {
+		return blobstore.NewMemoryStore()
+	})
+}
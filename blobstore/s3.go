@@ -0,0 +1,89 @@
+package blobstore
+
+import (
+	"context"
+	"github.com/Stanly1995/golibs/aws"
+	"github.com/Stanly1995/golibs/params_validator"
+	"io"
+	"path"
+	"time"
+)
+
+// S3Store stores objects in bucket of AWSConnector. Keys are used as is,
+// key strategy of the connector is not applied
+type S3Store struct {
+	conn *aws.AWSConnector
+}
+
+// NewS3Store is constructor
+func NewS3Store(conn *aws.AWSConnector) *S3Store {
+	params_validator.ValidateParamsWithPanic(conn)
+	return &S3Store{conn: conn}
+}
+
+// Put streams r to aws under key
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	o := NewPutOptions(opts...)
+	_, err := s.conn.PutReader(ctx, path.Base(key), o.ContentType, r, -1, aws.WithObjectKey(key), aws.WithMetadata(o.Metadata))
+	return err
+}
+
+// Get returns body and info of the object
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, Info{}, err
+	}
+	body, info, err := s.conn.GetFile(ctx, key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return body, Info(info), nil
+}
+
+// Stat returns info of the object
+func (s *S3Store) Stat(ctx context.Context, key string) (Info, error) {
+	if err := ValidateKey(key); err != nil {
+		return Info{}, err
+	}
+	info, err := s.conn.Stat(ctx, key)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info(info), nil
+}
+
+// List calls fn for every object which key starts with prefix
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(info Info) error) error {
+	if err := ValidatePrefix(prefix); err != nil {
+		return err
+	}
+	it := s.conn.List(ctx, prefix)
+	for it.Next() {
+		if err := fn(Info(it.Object())); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// Delete removes the object
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	return s.conn.Delete(ctx, key)
+}
+
+// PresignGet returns presigned url of the object, it doesn't check that the object exists
+func (s *S3Store) PresignGet(ctx context.Context, key string, expire time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	if expire <= 0 {
+		return "", ErrInvalidExpire
+	}
+	return s.conn.PresignGetURL(key, expire)
+}
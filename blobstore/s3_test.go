@@ -0,0 +1,20 @@
+package blobstore_test
+
+import (
+	"github.com/Stanly1995/golibs/aws/awstest"
+	"github.com/Stanly1995/golibs/blobstore"
+	"github.com/Stanly1995/golibs/blobstore/blobstoretest"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestS3Store(t *testing.T) {
+	blobstoretest.Run(t, func(t *testing.T) blobstore.BlobStore {
+		srv := awstest.NewServer()
+		t.Cleanup(srv.Close)
+		conn, err := srv.Connector("test-bucket", time.Minute)
+		require.NoError(t, err)
+		return blobstore.NewS3Store(conn)
+	})
+}
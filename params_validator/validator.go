@@ -1,6 +1,7 @@
 package params_validator
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Stanly1995/golibs/cerr"
 	"gopkg.in/go-playground/validator.v9"
@@ -21,6 +22,36 @@ func ValidateParamsWithPanic(params ...interface{}) {
 	}
 }
 
+// ValidateParams returns cerr.ErrInvalidParam with names of invalid fields of struct params
+// and names of nil params
+func ValidateParams(params ...interface{}) error {
+	var (
+		invalid cerr.ErrInvalidParam
+		failed  bool
+	)
+	for i := range params {
+		name, err := validateParam(params[i])
+		var fieldErrs validator.ValidationErrors
+		switch {
+		case errors.As(err, &fieldErrs):
+			for _, fieldErr := range fieldErrs {
+				invalid = invalid.AddParam(fieldErr.Field())
+			}
+		case err == ErrInvalidParam:
+			invalid = invalid.AddParam(name)
+		case err != nil:
+			return err
+		default:
+			continue
+		}
+		failed = true
+	}
+	if !failed {
+		return nil
+	}
+	return invalid
+}
+
 func validateParam(param interface{}) (string, error) {
 	if param == nil {
 		return "nil", ErrInvalidParam